@@ -16,6 +16,111 @@ type Config struct {
 	ServerFolderPath string `json:"server_folder_path"`
 	Port             string `json:"port"`
 	SessionSecret    string `json:"session_secret"`
+	JarLibraryPath   string `json:"jar_library_path"`
+
+	// Extra Java installations searched besides the common locations
+	JavaPaths []string `json:"java_paths"`
+
+	// Memory checked before servers start
+	MemoryBudget MemoryBudget `json:"memory_budget"`
+
+	// Variables of the controller's environment passed on to servers
+	EnvAllowlist []string `json:"env_allowlist"`
+
+	// How long a stop waits before escalating to signals
+	StopEscalation StopEscalation `json:"stop_escalation"`
+
+	// Users and priorities servers may be set to run with
+	ProcessIsolation ProcessIsolation `json:"process_isolation"`
+
+	// Alerting
+	AlertRules    []AlertRule        `json:"alert_rules"`
+	Notifications NotificationConfig `json:"notifications"`
+}
+
+// AlertRule configures one alert rule evaluated by the alert engine
+type AlertRule struct {
+	ID              string  `json:"id"`
+	Type            string  `json:"type"`             // host_memory, host_cpu, disk, server_offline, restart_loop
+	Threshold       float64 `json:"threshold"`        // percent, or number of crashes for restart_loop
+	ForSeconds      int     `json:"for_seconds"`      // how long the condition must hold before firing
+	WindowSeconds   int     `json:"window_seconds"`   // restart_loop: window the crashes are counted in
+	Severity        string  `json:"severity"`         // info, warning, critical
+	CooldownSeconds int     `json:"cooldown_seconds"` // minimum time between notifications for the same subject
+	Enabled         bool    `json:"enabled"`
+}
+
+// NotificationConfig holds the alert notification channels
+type NotificationConfig struct {
+	WebhookURL string     `json:"webhook_url"`
+	SMTP       SMTPConfig `json:"smtp"`
+}
+
+// SMTPConfig holds settings for e-mail notifications
+type SMTPConfig struct {
+	Host     string   `json:"host"`
+	Port     int      `json:"port"`
+	Username string   `json:"username"`
+	Password string   `json:"password"`
+	From     string   `json:"from"`
+	To       []string `json:"to"`
+}
+
+// Memory budget policies
+const (
+	MemoryPolicyOff    = "off"    // no check
+	MemoryPolicyWarn   = "warn"   // log a warning and start anyway
+	MemoryPolicyRefuse = "refuse" // refuse to start
+)
+
+// MemoryBudget configures the memory check done before a server starts
+type MemoryBudget struct {
+	Policy          string `json:"policy"`           // off, warn, refuse
+	OverheadPercent int    `json:"overhead_percent"` // added to -Xmx for metaspace, threads and native memory
+	OverheadMB      int    `json:"overhead_mb"`      // added on top of the percentage
+	TotalMB         int    `json:"total_mb"`         // budget for all servers together, 0 for none
+}
+
+// DefaultMemoryBudget returns the memory budget used when none is configured
+func DefaultMemoryBudget() MemoryBudget {
+	return MemoryBudget{Policy: MemoryPolicyWarn, OverheadPercent: 15, OverheadMB: 256}
+}
+
+// StopEscalation configures the steps of stopping a server: the stop
+// command, then SIGTERM to its process group, then SIGKILL
+type StopEscalation struct {
+	StopTimeoutSeconds int `json:"stop_timeout_seconds"` // wait after the stop command before SIGTERM
+	TermTimeoutSeconds int `json:"term_timeout_seconds"` // wait after SIGTERM before SIGKILL
+}
+
+// ProcessIsolation limits the process options of servers. It is only set in
+// the config file, so users of the web interface cannot widen it.
+type ProcessIsolation struct {
+	RunAsUsers        []string `json:"run_as_users"`        // user names or uids servers may run as
+	RunAsGroups       []string `json:"run_as_groups"`       // group names or gids servers may run as besides the user's own group
+	AllowNegativeNice bool     `json:"allow_negative_nice"` // whether servers may raise their priority
+}
+
+// DefaultStopEscalation returns the stop timeouts used when none are configured
+func DefaultStopEscalation() StopEscalation {
+	return StopEscalation{StopTimeoutSeconds: 30, TermTimeoutSeconds: 15}
+}
+
+// DefaultEnvAllowlist returns the environment variables servers get from
+// the controller when none are configured. A trailing * matches a prefix.
+func DefaultEnvAllowlist() []string {
+	return []string{"PATH", "HOME", "USER", "LANG", "LANGUAGE", "LC_*", "TZ", "TERM", "JAVA_HOME", "TMPDIR"}
+}
+
+// DefaultAlertRules returns the rules used when none are configured
+func DefaultAlertRules() []AlertRule {
+	return []AlertRule{
+		{ID: "host-memory-high", Type: "host_memory", Threshold: 90, ForSeconds: 300, Severity: "critical", CooldownSeconds: 1800, Enabled: true},
+		{ID: "host-cpu-high", Type: "host_cpu", Threshold: 95, ForSeconds: 600, Severity: "warning", CooldownSeconds: 1800, Enabled: true},
+		{ID: "disk-full", Type: "disk", Threshold: 85, Severity: "warning", CooldownSeconds: 3600, Enabled: true},
+		{ID: "server-offline", Type: "server_offline", Severity: "critical", CooldownSeconds: 600, Enabled: true},
+		{ID: "restart-loop", Type: "restart_loop", Threshold: 3, WindowSeconds: 600, Severity: "critical", CooldownSeconds: 1800, Enabled: true},
+	}
 }
 
 var (
@@ -51,6 +156,10 @@ func loadConfig() *Config {
 			ServerFolderPath: "",
 			Port:             "6767",
 			SessionSecret:    generateRandomSecret(),
+			AlertRules:       DefaultAlertRules(),
+			MemoryBudget:     DefaultMemoryBudget(),
+			EnvAllowlist:     DefaultEnvAllowlist(),
+			StopEscalation:   DefaultStopEscalation(),
 		}
 
 		// Save default config
@@ -70,6 +179,24 @@ func loadConfig() *Config {
 		log.Fatal("Failed to parse config file:", err)
 	}
 
+	// Fill in settings added after the config file was created
+	if config.AlertRules == nil {
+		config.AlertRules = DefaultAlertRules()
+		saveConfig(&config)
+	}
+	if config.MemoryBudget.Policy == "" {
+		config.MemoryBudget = DefaultMemoryBudget()
+		saveConfig(&config)
+	}
+	if config.EnvAllowlist == nil {
+		config.EnvAllowlist = DefaultEnvAllowlist()
+		saveConfig(&config)
+	}
+	if config.StopEscalation.StopTimeoutSeconds == 0 {
+		config.StopEscalation = DefaultStopEscalation()
+		saveConfig(&config)
+	}
+
 	return &config
 }
 
@@ -94,6 +221,93 @@ func GetServerPath() string {
 	return AppConfig.ServerFolderPath
 }
 
+// DefaultJarLibraryPath is where uploaded server jars are kept unless configured otherwise
+const DefaultJarLibraryPath = "./database/jars"
+
+// GetJarLibraryPath returns the folder of the server jar library
+func GetJarLibraryPath() string {
+	if AppConfig.JarLibraryPath != "" {
+		return AppConfig.JarLibraryPath
+	}
+	return DefaultJarLibraryPath
+}
+
+// GetJavaPaths returns the configured extra Java installation paths
+func GetJavaPaths() []string {
+	return append([]string(nil), AppConfig.JavaPaths...)
+}
+
+// UpdateJavaPaths replaces the extra Java installation paths
+func UpdateJavaPaths(paths []string) error {
+	AppConfig.JavaPaths = paths
+	return saveConfig(AppConfig)
+}
+
+// GetMemoryBudget returns the memory budget settings
+func GetMemoryBudget() MemoryBudget {
+	return AppConfig.MemoryBudget
+}
+
+// UpdateMemoryBudget updates the memory budget settings
+func UpdateMemoryBudget(budget MemoryBudget) error {
+	AppConfig.MemoryBudget = budget
+	return saveConfig(AppConfig)
+}
+
+// GetEnvAllowlist returns the environment variables passed on to servers
+func GetEnvAllowlist() []string {
+	return append([]string(nil), AppConfig.EnvAllowlist...)
+}
+
+// UpdateEnvAllowlist replaces the environment variables passed on to servers
+func UpdateEnvAllowlist(names []string) error {
+	AppConfig.EnvAllowlist = names
+	return saveConfig(AppConfig)
+}
+
+// GetStopEscalation returns the stop timeouts
+func GetStopEscalation() StopEscalation {
+	return AppConfig.StopEscalation
+}
+
+// UpdateStopEscalation updates the stop timeouts
+func UpdateStopEscalation(escalation StopEscalation) error {
+	AppConfig.StopEscalation = escalation
+	return saveConfig(AppConfig)
+}
+
+// GetProcessIsolation returns the limits of the process options of servers
+func GetProcessIsolation() ProcessIsolation {
+	isolation := AppConfig.ProcessIsolation
+	isolation.RunAsUsers = append([]string(nil), AppConfig.ProcessIsolation.RunAsUsers...)
+	isolation.RunAsGroups = append([]string(nil), AppConfig.ProcessIsolation.RunAsGroups...)
+	return isolation
+}
+
+// GetAlertRules returns a copy of the configured alert rules
+func GetAlertRules() []AlertRule {
+	return append([]AlertRule(nil), AppConfig.AlertRules...)
+}
+
+// UpdateAlertRules replaces the alert rules
+func UpdateAlertRules(rules []AlertRule) error {
+	AppConfig.AlertRules = rules
+	return saveConfig(AppConfig)
+}
+
+// GetNotificationConfig returns the notification channel settings
+func GetNotificationConfig() NotificationConfig {
+	notifications := AppConfig.Notifications
+	notifications.SMTP.To = append([]string(nil), AppConfig.Notifications.SMTP.To...)
+	return notifications
+}
+
+// UpdateNotificationConfig updates the notification channel settings
+func UpdateNotificationConfig(notifications NotificationConfig) error {
+	AppConfig.Notifications = notifications
+	return saveConfig(AppConfig)
+}
+
 // generateRandomSecret generates a random session secret
 func generateRandomSecret() string {
 	b := make([]byte, 32)
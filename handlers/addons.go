@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+
+	"minecraft-server-controller/config"
+	"minecraft-server-controller/middleware"
+	"minecraft-server-controller/models"
+	"minecraft-server-controller/services"
+
+	"github.com/gorilla/mux"
+)
+
+// AddonsPage renders the plugin and mod inventory of a server
+func AddonsPage(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	serverName := vars["name"]
+	userID := middleware.GetUserID(r)
+
+	user, err := models.GetUserByID(userID)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	server, err := models.GetServerByName(serverName, userID)
+	if err != nil {
+		http.Error(w, "Server not found", http.StatusNotFound)
+		return
+	}
+
+	session, _ := config.GetSessionStore().Get(r, "auth-session")
+
+	tmpl, err := template.ParseFiles("templates/addons.html")
+	if err != nil {
+		http.Error(w, "Error loading template", http.StatusInternalServerError)
+		return
+	}
+
+	addons, listErr := services.ListAddons(server)
+
+	data := map[string]interface{}{
+		"User":        user,
+		"Server":      server,
+		"Addons":      addons,
+		"DefaultKind": services.DefaultAddonKind(server),
+		"Success":     session.Flashes("success"),
+		"Error":       session.Flashes("error"),
+	}
+	if listErr != nil {
+		data["ListError"] = listErr.Error()
+	}
+	session.Save(r, w)
+
+	tmpl.Execute(w, data)
+}
+
+// GetAddons returns the plugin and mod inventory of a server as JSON
+func GetAddons(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	server, err := models.GetServerByName(mux.Vars(r)["name"], middleware.GetUserID(r))
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Server not found"})
+		return
+	}
+
+	addons, err := services.ListAddons(server)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(addons)
+}
+
+// UploadAddon handles uploading a jar into the plugins or mods folder
+func UploadAddon(w http.ResponseWriter, r *http.Request) {
+	serverName := mux.Vars(r)["name"]
+	session, _ := config.GetSessionStore().Get(r, "auth-session")
+
+	server, err := models.GetServerByName(serverName, middleware.GetUserID(r))
+	if err != nil {
+		http.Error(w, "Server not found", http.StatusNotFound)
+		return
+	}
+
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		session.AddFlash("Error reading upload: "+err.Error(), "error")
+		session.Save(r, w)
+		http.Redirect(w, r, "/server/"+serverName+"/addons", http.StatusSeeOther)
+		return
+	}
+
+	file, header, err := r.FormFile("jar")
+	if err != nil {
+		session.AddFlash("No jar file selected", "error")
+		session.Save(r, w)
+		http.Redirect(w, r, "/server/"+serverName+"/addons", http.StatusSeeOther)
+		return
+	}
+	defer file.Close()
+
+	addon, err := services.UploadAddon(server, r.FormValue("kind"), header.Filename, file)
+	if err != nil {
+		session.AddFlash("Error uploading jar: "+err.Error(), "error")
+		session.Save(r, w)
+		http.Redirect(w, r, "/server/"+serverName+"/addons", http.StatusSeeOther)
+		return
+	}
+
+	session.AddFlash("'"+addon.DisplayName()+"' uploaded; restart the server to load it", "success")
+	session.Save(r, w)
+
+	http.Redirect(w, r, "/server/"+serverName+"/addons", http.StatusSeeOther)
+}
+
+// ToggleAddon handles enabling (enabled=true) or disabling an addon
+func ToggleAddon(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	server, ok := serverForAction(w, r)
+	if !ok {
+		return
+	}
+
+	enabled := r.FormValue("enabled") == "true"
+	file, err := services.SetAddonEnabled(server, r.FormValue("kind"), r.FormValue("file"), enabled)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{"status": "Addon updated", "file": file})
+}
+
+// RemoveAddon handles deleting an addon jar
+func RemoveAddon(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	server, ok := serverForAction(w, r)
+	if !ok {
+		return
+	}
+
+	if err := services.RemoveAddon(server, r.FormValue("kind"), r.FormValue("file")); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{"status": "Addon removed"})
+}
@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"minecraft-server-controller/config"
+	"minecraft-server-controller/middleware"
+	"minecraft-server-controller/models"
+	"minecraft-server-controller/services"
+)
+
+// recentAlertLimit is how many alerts the history shows
+const recentAlertLimit = 50
+
+// AlertsPage renders the alerts page
+func AlertsPage(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
+	user, err := models.GetUserByID(userID)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	session, _ := config.GetSessionStore().Get(r, "auth-session")
+
+	tmpl, err := template.ParseFiles("templates/alerts.html")
+	if err != nil {
+		http.Error(w, "Error loading template", http.StatusInternalServerError)
+		return
+	}
+
+	firing, _ := models.GetFiringAlertsByUserID(userID)
+	recent, _ := models.GetRecentAlertsByUserID(userID, recentAlertLimit)
+	notifications := config.GetNotificationConfig()
+
+	data := map[string]interface{}{
+		"User":          user,
+		"Firing":        firing,
+		"Recent":        recent,
+		"Rules":         config.GetAlertRules(),
+		"Notifications": notifications,
+		"SMTPTo":        strings.Join(notifications.SMTP.To, ", "),
+		"Success":       session.Flashes("success"),
+		"Error":         session.Flashes("error"),
+	}
+	session.Save(r, w)
+
+	tmpl.Execute(w, data)
+}
+
+// GetAlerts returns the firing alerts and the recent alert history as JSON
+func GetAlerts(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID := middleware.GetUserID(r)
+
+	firing, err := models.GetFiringAlertsByUserID(userID)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	recent, err := models.GetRecentAlertsByUserID(userID, recentAlertLimit)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"firing": firing,
+		"recent": recent,
+	})
+}
+
+// UpdateAlertRules handles the alert rule form: threshold, duration,
+// cooldown and enabled state of every rule
+func UpdateAlertRules(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Error parsing form", http.StatusBadRequest)
+		return
+	}
+
+	session, _ := config.GetSessionStore().Get(r, "auth-session")
+
+	rules := config.GetAlertRules()
+	for i := range rules {
+		rule := &rules[i]
+		rule.Enabled = r.FormValue("enabled_"+rule.ID) == "on"
+
+		fields := []struct {
+			name  string
+			value *float64
+			whole *int
+		}{
+			{name: "threshold_", value: &rule.Threshold},
+			{name: "for_", whole: &rule.ForSeconds},
+			{name: "window_", whole: &rule.WindowSeconds},
+			{name: "cooldown_", whole: &rule.CooldownSeconds},
+		}
+		for _, field := range fields {
+			raw := strings.TrimSpace(r.FormValue(field.name + rule.ID))
+			if raw == "" {
+				continue
+			}
+
+			number, err := strconv.ParseFloat(raw, 64)
+			if err != nil || number < 0 {
+				session.AddFlash("Invalid value for rule "+rule.ID, "error")
+				session.Save(r, w)
+				http.Redirect(w, r, "/alerts", http.StatusSeeOther)
+				return
+			}
+
+			if field.value != nil {
+				*field.value = number
+			} else {
+				*field.whole = int(number)
+			}
+		}
+	}
+
+	if err := config.UpdateAlertRules(rules); err != nil {
+		session.AddFlash("Error updating alert rules: "+err.Error(), "error")
+		session.Save(r, w)
+		http.Redirect(w, r, "/alerts", http.StatusSeeOther)
+		return
+	}
+
+	session.AddFlash("Alert rules updated successfully", "success")
+	session.Save(r, w)
+
+	http.Redirect(w, r, "/alerts", http.StatusSeeOther)
+}
+
+// UpdateNotifications handles the notification channel form
+func UpdateNotifications(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Error parsing form", http.StatusBadRequest)
+		return
+	}
+
+	session, _ := config.GetSessionStore().Get(r, "auth-session")
+
+	notifications := config.GetNotificationConfig()
+	notifications.WebhookURL = strings.TrimSpace(r.FormValue("webhook_url"))
+	notifications.SMTP.Host = strings.TrimSpace(r.FormValue("smtp_host"))
+	notifications.SMTP.Username = strings.TrimSpace(r.FormValue("smtp_username"))
+	notifications.SMTP.From = strings.TrimSpace(r.FormValue("smtp_from"))
+
+	// An empty password field keeps the stored password
+	if password := r.FormValue("smtp_password"); password != "" {
+		notifications.SMTP.Password = password
+	}
+	if r.FormValue("smtp_clear_password") == "on" {
+		notifications.SMTP.Password = ""
+	}
+
+	notifications.SMTP.Port = 0
+	if port := strings.TrimSpace(r.FormValue("smtp_port")); port != "" {
+		value, err := strconv.Atoi(port)
+		if err != nil || value < 1 || value > 65535 {
+			session.AddFlash("SMTP port must be between 1 and 65535", "error")
+			session.Save(r, w)
+			http.Redirect(w, r, "/alerts", http.StatusSeeOther)
+			return
+		}
+		notifications.SMTP.Port = value
+	}
+
+	notifications.SMTP.To = []string{}
+	for _, address := range strings.Split(r.FormValue("smtp_to"), ",") {
+		if address = strings.TrimSpace(address); address != "" {
+			notifications.SMTP.To = append(notifications.SMTP.To, address)
+		}
+	}
+
+	if notifications.WebhookURL != "" && !strings.HasPrefix(notifications.WebhookURL, "http://") && !strings.HasPrefix(notifications.WebhookURL, "https://") {
+		session.AddFlash("Webhook URL must start with http:// or https://", "error")
+		session.Save(r, w)
+		http.Redirect(w, r, "/alerts", http.StatusSeeOther)
+		return
+	}
+
+	if err := config.UpdateNotificationConfig(notifications); err != nil {
+		session.AddFlash("Error updating notifications: "+err.Error(), "error")
+		session.Save(r, w)
+		http.Redirect(w, r, "/alerts", http.StatusSeeOther)
+		return
+	}
+
+	session.AddFlash("Notification settings updated successfully", "success")
+	session.Save(r, w)
+
+	http.Redirect(w, r, "/alerts", http.StatusSeeOther)
+}
+
+// TestAlert sends a test notification through every configured channel
+func TestAlert(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	results := services.SendTestAlert()
+	if len(results) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "No notification channels configured"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{"results": results})
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"html/template"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+
+	"minecraft-server-controller/config"
+	"minecraft-server-controller/middleware"
+	"minecraft-server-controller/models"
+	"minecraft-server-controller/services"
+
+	"github.com/gorilla/mux"
+)
+
+// maxCrashFileDisplay is how much of a crash file is shown on the page
+const maxCrashFileDisplay = 1 << 20
+
+// CrashesPage renders the crash history of a server. With an {id} the
+// report is shown along with the file it was parsed from.
+func CrashesPage(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	serverName := vars["name"]
+	userID := middleware.GetUserID(r)
+
+	user, err := models.GetUserByID(userID)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	server, err := models.GetServerByName(serverName, userID)
+	if err != nil {
+		http.Error(w, "Server not found", http.StatusNotFound)
+		return
+	}
+
+	session, _ := config.GetSessionStore().Get(r, "auth-session")
+
+	tmpl, err := template.ParseFiles("templates/crashes.html")
+	if err != nil {
+		http.Error(w, "Error loading template", http.StatusInternalServerError)
+		return
+	}
+
+	crashes, err := models.GetCrashReports(server.ID, 50)
+	if err != nil {
+		crashes = []models.CrashReport{}
+	}
+
+	data := map[string]interface{}{
+		"User":    user,
+		"Server":  server,
+		"Crashes": crashes,
+		"Success": session.Flashes("success"),
+		"Error":   session.Flashes("error"),
+	}
+
+	if vars["id"] != "" {
+		id, _ := strconv.ParseUint(vars["id"], 10, 64)
+		crash, err := models.GetCrashReport(uint(id), server.ID)
+		if err != nil {
+			http.Error(w, "Crash report not found", http.StatusNotFound)
+			return
+		}
+		data["Selected"] = crash
+
+		if path := services.CrashReportFile(server, crash); path != "" {
+			if file, err := os.Open(path); err == nil {
+				content, _ := io.ReadAll(io.LimitReader(file, maxCrashFileDisplay))
+				file.Close()
+				data["FileContent"] = string(content)
+			} else {
+				data["FileError"] = "The file is no longer available"
+			}
+		}
+	}
+	session.Save(r, w)
+
+	tmpl.Execute(w, data)
+}
+
+// CrashReportFile serves the full file a crash report was parsed from
+func CrashReportFile(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	server, err := models.GetServerByName(vars["name"], middleware.GetUserID(r))
+	if err != nil {
+		http.Error(w, "Server not found", http.StatusNotFound)
+		return
+	}
+
+	id, _ := strconv.ParseUint(vars["id"], 10, 64)
+	crash, err := models.GetCrashReport(uint(id), server.ID)
+	if err != nil {
+		http.Error(w, "Crash report not found", http.StatusNotFound)
+		return
+	}
+
+	path := services.CrashReportFile(server, crash)
+	if path == "" {
+		http.Error(w, "Crash report has no file", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	http.ServeFile(w, r, path)
+}
@@ -0,0 +1,271 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"minecraft-server-controller/config"
+	"minecraft-server-controller/middleware"
+	"minecraft-server-controller/models"
+	"minecraft-server-controller/services"
+
+	"github.com/gorilla/mux"
+)
+
+// maxBulkParallelism bounds how many servers a bulk action acts on at once
+const maxBulkParallelism = 16
+
+// groupView is a server group with its members for the dashboard
+type groupView struct {
+	models.ServerGroup
+	Members []models.Server
+}
+
+// MemberNames returns the member names in start order, as edited in the form
+func (v groupView) MemberNames() string {
+	names := make([]string, len(v.Members))
+	for i, server := range v.Members {
+		names[i] = server.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+// groupViews loads the server groups of a user for the dashboard
+func groupViews(userID uint) []groupView {
+	groups, _ := models.GetServerGroupsByUserID(userID)
+	views := make([]groupView, 0, len(groups))
+	for _, group := range groups {
+		views = append(views, groupView{ServerGroup: group, Members: group.Servers()})
+	}
+	return views
+}
+
+// groupFromForm reads and validates the settings of a server group form
+func groupFromForm(r *http.Request, userID uint) (name string, serverIDs []uint, parallelism, startDelay int, err error) {
+	name = strings.TrimSpace(r.FormValue("name"))
+	if name == "" {
+		return "", nil, 0, 0, errors.New("name cannot be empty")
+	}
+
+	// Members are names separated by commas or whitespace, in start order
+	names := strings.FieldsFunc(r.FormValue("servers"), func(c rune) bool {
+		return c == ',' || unicode.IsSpace(c)
+	})
+	seen := make(map[uint]bool)
+	for _, serverName := range names {
+		server, err := models.GetServerByName(serverName, userID)
+		if err != nil {
+			return "", nil, 0, 0, fmt.Errorf("unknown server: %s", serverName)
+		}
+		if !seen[server.ID] {
+			seen[server.ID] = true
+			serverIDs = append(serverIDs, server.ID)
+		}
+	}
+	if len(serverIDs) == 0 {
+		return "", nil, 0, 0, errors.New("add at least one server")
+	}
+
+	parallelism, err = strconv.Atoi(r.FormValue("parallelism"))
+	if err != nil || parallelism < 1 || parallelism > maxBulkParallelism {
+		return "", nil, 0, 0, fmt.Errorf("parallelism must be between 1 and %d", maxBulkParallelism)
+	}
+
+	startDelay = 0
+	if value := strings.TrimSpace(r.FormValue("start_delay")); value != "" {
+		startDelay, err = strconv.Atoi(value)
+		if err != nil || startDelay < 0 || startDelay > 600 {
+			return "", nil, 0, 0, errors.New("start delay must be between 0 and 600 seconds")
+		}
+	}
+
+	return name, serverIDs, parallelism, startDelay, nil
+}
+
+// CreateServerGroup handles adding a server group
+func CreateServerGroup(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Error parsing form", http.StatusBadRequest)
+		return
+	}
+
+	userID := middleware.GetUserID(r)
+	session, _ := config.GetSessionStore().Get(r, "auth-session")
+
+	name, serverIDs, parallelism, startDelay, err := groupFromForm(r, userID)
+	if err != nil {
+		session.AddFlash("Invalid group: "+err.Error(), "error")
+		session.Save(r, w)
+		http.Redirect(w, r, "/dashboard", http.StatusSeeOther)
+		return
+	}
+
+	if _, err := models.CreateServerGroup(name, serverIDs, parallelism, startDelay, userID); err != nil {
+		session.AddFlash("Error creating group: "+err.Error(), "error")
+		session.Save(r, w)
+		http.Redirect(w, r, "/dashboard", http.StatusSeeOther)
+		return
+	}
+
+	session.AddFlash("Group created", "success")
+	session.Save(r, w)
+
+	http.Redirect(w, r, "/dashboard", http.StatusSeeOther)
+}
+
+// UpdateServerGroup handles changing the members and settings of a group
+func UpdateServerGroup(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Error parsing form", http.StatusBadRequest)
+		return
+	}
+
+	userID := middleware.GetUserID(r)
+	session, _ := config.GetSessionStore().Get(r, "auth-session")
+
+	group, err := groupFromRequest(r)
+	if err != nil {
+		session.AddFlash("Group not found", "error")
+		session.Save(r, w)
+		http.Redirect(w, r, "/dashboard", http.StatusSeeOther)
+		return
+	}
+
+	name, serverIDs, parallelism, startDelay, err := groupFromForm(r, userID)
+	if err != nil {
+		session.AddFlash("Invalid group: "+err.Error(), "error")
+		session.Save(r, w)
+		http.Redirect(w, r, "/dashboard", http.StatusSeeOther)
+		return
+	}
+
+	if err := group.Update(name, serverIDs, parallelism, startDelay); err != nil {
+		session.AddFlash("Error updating group: "+err.Error(), "error")
+		session.Save(r, w)
+		http.Redirect(w, r, "/dashboard", http.StatusSeeOther)
+		return
+	}
+
+	session.AddFlash("Group updated", "success")
+	session.Save(r, w)
+
+	http.Redirect(w, r, "/dashboard", http.StatusSeeOther)
+}
+
+// DeleteServerGroup handles removing a server group
+func DeleteServerGroup(w http.ResponseWriter, r *http.Request) {
+	session, _ := config.GetSessionStore().Get(r, "auth-session")
+
+	group, err := groupFromRequest(r)
+	if err != nil {
+		session.AddFlash("Group not found", "error")
+		session.Save(r, w)
+		http.Redirect(w, r, "/dashboard", http.StatusSeeOther)
+		return
+	}
+
+	if err := group.Delete(); err != nil {
+		session.AddFlash("Error deleting group: "+err.Error(), "error")
+		session.Save(r, w)
+		http.Redirect(w, r, "/dashboard", http.StatusSeeOther)
+		return
+	}
+
+	session.AddFlash("Group deleted", "success")
+	session.Save(r, w)
+
+	http.Redirect(w, r, "/dashboard", http.StatusSeeOther)
+}
+
+// BulkAction starts an action on the servers of a group, or on the servers
+// selected on the dashboard, and returns the operation of each server
+func BulkAction(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := r.ParseForm(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Error parsing form"})
+		return
+	}
+
+	userID := middleware.GetUserID(r)
+	req := services.BulkRequest{
+		Action:      r.FormValue("action"),
+		Command:     strings.TrimSpace(r.FormValue("command")),
+		Parallelism: 1,
+	}
+	if !services.IsBulkAction(req.Action) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Unknown action"})
+		return
+	}
+	if req.Action == services.BulkCommand && req.Command == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Command cannot be empty"})
+		return
+	}
+	if user, err := models.GetUserByID(userID); err == nil {
+		req.User = user.Username
+	}
+
+	var servers []models.Server
+	if id := r.FormValue("group"); id != "" {
+		groupID, _ := strconv.ParseUint(id, 10, 64)
+		group, err := models.GetServerGroupByID(uint(groupID), userID)
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Group not found"})
+			return
+		}
+		servers = group.Servers()
+		req.Parallelism = group.Parallelism
+		req.StartDelay = time.Duration(group.StartDelaySeconds) * time.Second
+	} else {
+		seen := make(map[uint]bool)
+		for _, name := range r.Form["servers"] {
+			server, err := models.GetServerByName(name, userID)
+			if err != nil {
+				w.WriteHeader(http.StatusNotFound)
+				json.NewEncoder(w).Encode(map[string]string{"error": "Server not found: " + name})
+				return
+			}
+			if !seen[server.ID] {
+				seen[server.ID] = true
+				servers = append(servers, *server)
+			}
+		}
+		if n, err := strconv.Atoi(r.FormValue("parallelism")); err == nil && n >= 1 && n <= maxBulkParallelism {
+			req.Parallelism = n
+		}
+	}
+	if len(servers) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "No servers selected"})
+		return
+	}
+
+	ops, err := services.StartBulkAction(req, servers)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{"operations": ops})
+}
+
+// groupFromRequest loads the server group named in the URL for the user
+func groupFromRequest(r *http.Request) (*models.ServerGroup, error) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	return models.GetServerGroupByID(uint(id), middleware.GetUserID(r))
+}
@@ -0,0 +1,287 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"minecraft-server-controller/config"
+	"minecraft-server-controller/middleware"
+	"minecraft-server-controller/models"
+	"minecraft-server-controller/services"
+
+	"github.com/gorilla/mux"
+)
+
+// ManagePage renders the rename/clone/archive/delete page of a server
+func ManagePage(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	serverName := vars["name"]
+	userID := middleware.GetUserID(r)
+
+	user, err := models.GetUserByID(userID)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	server, err := models.GetServerByName(serverName, userID)
+	if err != nil {
+		http.Error(w, "Server not found", http.StatusNotFound)
+		return
+	}
+
+	session, _ := config.GetSessionStore().Get(r, "auth-session")
+
+	tmpl, err := template.ParseFiles("templates/manage.html")
+	if err != nil {
+		http.Error(w, "Error loading template", http.StatusInternalServerError)
+		return
+	}
+
+	conflicts, _ := services.PortConflicts(server)
+	suggested, _ := services.SuggestPort(services.DefaultServerPort)
+
+	limits := models.ResourceLimits{}
+	if server.ResourceLimits != nil {
+		limits = *server.ResourceLimits
+	}
+	cgroupError := ""
+	if _, err := services.CgroupSlice(); err != nil {
+		cgroupError = err.Error()
+	}
+
+	data := map[string]interface{}{
+		"User":          user,
+		"Server":        server,
+		"IsRunning":     services.IsServerRunning(server),
+		"Ports":         services.ServerPorts(server),
+		"PortConflicts": conflicts,
+		"SuggestedPort": suggested,
+		"Limits":        limits,
+		"CgroupError":   cgroupError,
+		"Success":       session.Flashes("success"),
+		"Error":         session.Flashes("error"),
+	}
+	session.Save(r, w)
+
+	tmpl.Execute(w, data)
+}
+
+// RenameServer handles renaming a server and its folder
+func RenameServer(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	server, ok := serverForAction(w, r)
+	if !ok {
+		return
+	}
+
+	newName := strings.TrimSpace(r.FormValue("name"))
+	if err := services.RenameServer(server, newName); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{"status": "Server renamed successfully", "name": newName})
+}
+
+// DeleteServer handles deleting a server. mode is "trash" (default) or "purge".
+func DeleteServer(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	server, ok := serverForAction(w, r)
+	if !ok {
+		return
+	}
+
+	mode := r.FormValue("mode")
+	if mode == "" {
+		mode = services.DeleteToTrash
+	}
+
+	if err := services.DeleteServer(server, mode); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{"status": "Server deleted successfully"})
+}
+
+// CloneServer handles copying a server into a new one on another port
+func CloneServer(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	server, ok := serverForAction(w, r)
+	if !ok {
+		return
+	}
+
+	newName := strings.TrimSpace(r.FormValue("name"))
+	port, err := strconv.Atoi(r.FormValue("port"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid port"})
+		return
+	}
+
+	clone, err := services.CloneServer(server, newName, port)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{"status": "Server cloned successfully", "name": clone.Name})
+}
+
+// ArchiveServer handles archiving (archived=true) or unarchiving a server
+func ArchiveServer(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	server, ok := serverForAction(w, r)
+	if !ok {
+		return
+	}
+
+	archived := r.FormValue("archived") != "false"
+	if err := services.ArchiveServer(server, archived); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	if archived {
+		json.NewEncoder(w).Encode(map[string]string{"status": "Server archived"})
+	} else {
+		json.NewEncoder(w).Encode(map[string]string{"status": "Server unarchived"})
+	}
+}
+
+// DetectSoftware handles detecting the software and version of a server again
+func DetectSoftware(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	server, ok := serverForAction(w, r)
+	if !ok {
+		return
+	}
+
+	if server.Missing {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "server folder is missing"})
+		return
+	}
+
+	info := services.RefreshServerSoftware(server)
+	json.NewEncoder(w).Encode(map[string]string{
+		"software": info.Software,
+		"version":  info.Version,
+		"label":    server.SoftwareLabel(),
+	})
+}
+
+// AssignFreePort moves a stopped server to a free port, along with any
+// other of its ports that conflict
+func AssignFreePort(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	server, ok := serverForAction(w, r)
+	if !ok {
+		return
+	}
+
+	assigned, err := services.AssignFreePort(server)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	port := strconv.Itoa(assigned[0].Port)
+	status := "Server moved to port " + port
+	for _, moved := range assigned[1:] {
+		status += fmt.Sprintf("; %s moved to %d", moved.Source, moved.Port)
+	}
+	json.NewEncoder(w).Encode(map[string]string{"status": status, "port": port})
+}
+
+// UpdateResourceLimits stores the cgroup limits of a server and applies
+// them right away when it is running in a cgroup
+func UpdateResourceLimits(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	server, ok := serverForAction(w, r)
+	if !ok {
+		return
+	}
+
+	values := map[string]int{}
+	for _, field := range []string{"cpu_percent", "memory_max_mb", "memory_high_mb", "io_weight", "pids_max"} {
+		value := 0
+		if text := strings.TrimSpace(r.FormValue(field)); text != "" {
+			n, err := strconv.Atoi(text)
+			if err != nil || n < 0 {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]string{"error": field + " must be a whole number of zero or more"})
+				return
+			}
+			value = n
+		}
+		values[field] = value
+	}
+	if values["io_weight"] > 10000 {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "io_weight must be between 1 and 10000"})
+		return
+	}
+
+	limits := &models.ResourceLimits{
+		CPUPercent:   values["cpu_percent"],
+		MemoryMaxMB:  values["memory_max_mb"],
+		MemoryHighMB: values["memory_high_mb"],
+		IOWeight:     values["io_weight"],
+		PidsMax:      values["pids_max"],
+	}
+	if err := server.UpdateResourceLimits(limits); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	applied, err := services.ApplyResourceLimits(server)
+	switch {
+	case err != nil:
+		json.NewEncoder(w).Encode(map[string]string{"status": "Limits saved, but not all could be applied: " + err.Error()})
+	case applied:
+		json.NewEncoder(w).Encode(map[string]string{"status": "Limits saved and applied"})
+	case services.IsServerRunning(server):
+		json.NewEncoder(w).Encode(map[string]string{"status": "Limits saved; they apply from the next start"})
+	default:
+		json.NewEncoder(w).Encode(map[string]string{"status": "Limits saved"})
+	}
+}
+
+// serverForAction parses the form and loads the {name} server of the
+// current user, writing a JSON error when either fails
+func serverForAction(w http.ResponseWriter, r *http.Request) (*models.Server, bool) {
+	if err := r.ParseForm(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Error parsing form"})
+		return nil, false
+	}
+
+	server, err := models.GetServerByName(mux.Vars(r)["name"], middleware.GetUserID(r))
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Server not found"})
+		return nil, false
+	}
+
+	return server, true
+}
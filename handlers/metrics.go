@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"minecraft-server-controller/middleware"
+	"minecraft-server-controller/models"
+	"minecraft-server-controller/services"
+)
+
+// maxMetricPoints caps the number of points returned per series
+const maxMetricPoints = 2000
+
+// GetMetrics returns historical metrics for charts.
+// Query: series (comma separated), from/to (unix seconds), step (seconds).
+// Without series it lists the available series names.
+func GetMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID := middleware.GetUserID(r)
+	query := r.URL.Query()
+	if query.Get("series") == "" {
+		series, err := models.ListMetricSeries()
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"series": services.VisibleMetricSeries(userID, series)})
+		return
+	}
+
+	// Defaults: the last hour
+	to := time.Now()
+	from := to.Add(-time.Hour)
+
+	if value := query.Get("from"); value != "" {
+		unix, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid from timestamp"})
+			return
+		}
+		from = time.Unix(unix, 0)
+	}
+
+	if value := query.Get("to"); value != "" {
+		unix, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid to timestamp"})
+			return
+		}
+		to = time.Unix(unix, 0)
+	}
+
+	if !from.Before(to) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "from must be before to"})
+		return
+	}
+
+	// Default step gives about 300 points over the range
+	step := to.Sub(from) / 300
+	if value := query.Get("step"); value != "" {
+		seconds, err := strconv.Atoi(value)
+		if err != nil || seconds <= 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid step"})
+			return
+		}
+		step = time.Duration(seconds) * time.Second
+	}
+
+	// Never return more points than the chart can use
+	if minStep := to.Sub(from) / maxMetricPoints; step < minStep {
+		step = minStep
+	}
+
+	tier := services.SelectMetricsTier(from, step)
+	if minStep := time.Duration(tier.Resolution) * time.Second; step < minStep {
+		step = minStep
+	}
+	stepSeconds := int64(step / time.Second)
+
+	names := []string{}
+	for _, name := range strings.Split(query.Get("series"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	if visible := services.VisibleMetricSeries(userID, names); len(visible) != len(names) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Unknown series"})
+		return
+	}
+
+	results := []map[string]interface{}{}
+	for _, name := range names {
+
+		points, err := models.QueryMetrics(name, tier.Resolution, from.Unix(), to.Unix(), stepSeconds)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+
+		results = append(results, map[string]interface{}{
+			"name":   name,
+			"points": points,
+		})
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"from":       from.Unix(),
+		"to":         to.Unix(),
+		"step":       stepSeconds,
+		"resolution": tier.Resolution,
+		"series":     results,
+	})
+}
+
+// PrometheusMetrics exposes host metrics and the metrics of the token's
+// user's servers in the Prometheus text format
+func PrometheusMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := services.WritePrometheusMetrics(w, middleware.GetUserID(r)); err != nil {
+		log.Printf("⚠️  Failed to write metrics: %v", err)
+	}
+}
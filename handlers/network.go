@@ -0,0 +1,18 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"minecraft-server-controller/middleware"
+	"minecraft-server-controller/services"
+)
+
+// GetNetwork returns the proxies of the user with their backends and the
+// problems found in their settings. Backends outside the panel are only
+// checked with ?check=1.
+func GetNetwork(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	checkOutside := r.URL.Query().Get("check") == "1"
+	json.NewEncoder(w).Encode(services.ProxyNetworks(middleware.GetUserID(r), checkOutside))
+}
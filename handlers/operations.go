@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"minecraft-server-controller/middleware"
+	"minecraft-server-controller/models"
+	"minecraft-server-controller/services"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+)
+
+// startOperation runs a lifecycle action of a server in the background and
+// answers with the ID to follow its progress by
+func startOperation(w http.ResponseWriter, kind string, server *models.Server, status string) {
+	w.Header().Set("Content-Type", "application/json")
+
+	op, err := services.StartOperation(kind, server)
+	if err != nil {
+		if errors.Is(err, services.ErrServerBusy) {
+			w.WriteHeader(http.StatusConflict)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{"status": status, "operation": op.ID})
+}
+
+// GetOperations lists the lifecycle operations of the user, optionally only
+// those of the server named by the server query parameter
+func GetOperations(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
+	w.Header().Set("Content-Type", "application/json")
+
+	var serverID uint
+	if name := r.URL.Query().Get("server"); name != "" {
+		server, err := models.GetServerByName(name, userID)
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Server not found"})
+			return
+		}
+		serverID = server.ID
+	}
+
+	json.NewEncoder(w).Encode(services.ListOperations(userID, serverID))
+}
+
+// GetOperation returns the progress of a lifecycle operation
+func GetOperation(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	op, found := services.GetOperation(mux.Vars(r)["id"], middleware.GetUserID(r))
+	if !found {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Operation not found"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(op)
+}
+
+// OperationsWebSocket streams the updates of the user's lifecycle operations
+func OperationsWebSocket(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	updates, unsubscribe := services.SubscribeOperations(userID)
+	defer unsubscribe()
+
+	// The read loop notices when the client goes away and hands pings over,
+	// since only the loop below may write to the connection
+	pings := make(chan struct{}, 1)
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			messageType, message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if messageType == websocket.TextMessage && string(message) == "ping" {
+				select {
+				case pings <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+
+	for {
+		var err error
+		select {
+		case op := <-updates:
+			err = conn.WriteJSON(op)
+		case <-pings:
+			err = conn.WriteMessage(websocket.TextMessage, []byte("pong"))
+		case <-closed:
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
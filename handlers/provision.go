@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"minecraft-server-controller/config"
+	"minecraft-server-controller/middleware"
+	"minecraft-server-controller/models"
+	"minecraft-server-controller/services"
+
+	"github.com/gorilla/mux"
+)
+
+// NewServerPage renders the server creation wizard with the jar library
+func NewServerPage(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
+	user, err := models.GetUserByID(userID)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	session, _ := config.GetSessionStore().Get(r, "auth-session")
+
+	tmpl, err := template.ParseFiles("templates/new_server.html")
+	if err != nil {
+		http.Error(w, "Error loading template", http.StatusInternalServerError)
+		return
+	}
+
+	jars, _ := models.GetAllJarFiles()
+
+	port, err := services.SuggestPort(services.DefaultServerPort)
+	if err != nil {
+		port = services.DefaultServerPort
+	}
+
+	data := map[string]interface{}{
+		"User":       user,
+		"Jars":       jars,
+		"Gamemodes":  services.Gamemodes,
+		"ServerPath": config.GetServerPath(),
+		"Port":       port,
+		"Success":    session.Flashes("success"),
+		"Error":      session.Flashes("error"),
+	}
+	session.Save(r, w)
+
+	tmpl.Execute(w, data)
+}
+
+// ProvisionServer handles the server creation wizard form
+func ProvisionServer(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Error parsing form", http.StatusBadRequest)
+		return
+	}
+
+	userID := middleware.GetUserID(r)
+	session, _ := config.GetSessionStore().Get(r, "auth-session")
+
+	jarID, _ := strconv.ParseUint(r.FormValue("jar"), 10, 64)
+	port, _ := strconv.Atoi(r.FormValue("port"))
+	memory, _ := strconv.Atoi(r.FormValue("memory"))
+
+	opts := services.ProvisionOptions{
+		Name:       strings.TrimSpace(r.FormValue("name")),
+		JarID:      uint(jarID),
+		Port:       port,
+		MOTD:       r.FormValue("motd"),
+		Seed:       strings.TrimSpace(r.FormValue("seed")),
+		Gamemode:   r.FormValue("gamemode"),
+		MemoryMB:   memory,
+		AcceptEULA: r.FormValue("eula") == "on",
+	}
+
+	server, err := services.ProvisionServer(opts, userID)
+	if err != nil {
+		session.AddFlash("Error creating server: "+err.Error(), "error")
+		session.Save(r, w)
+		http.Redirect(w, r, "/servers/new", http.StatusSeeOther)
+		return
+	}
+
+	session.AddFlash("Server '"+server.Name+"' created successfully", "success")
+	session.Save(r, w)
+
+	http.Redirect(w, r, "/server/"+server.Name, http.StatusSeeOther)
+}
+
+// UploadJar handles adding a server jar to the library
+func UploadJar(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
+	session, _ := config.GetSessionStore().Get(r, "auth-session")
+
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		session.AddFlash("Error reading upload: "+err.Error(), "error")
+		session.Save(r, w)
+		http.Redirect(w, r, "/servers/new", http.StatusSeeOther)
+		return
+	}
+
+	file, header, err := r.FormFile("jar")
+	if err != nil {
+		session.AddFlash("No jar file selected", "error")
+		session.Save(r, w)
+		http.Redirect(w, r, "/servers/new", http.StatusSeeOther)
+		return
+	}
+	defer file.Close()
+
+	if !strings.HasSuffix(strings.ToLower(header.Filename), ".jar") {
+		session.AddFlash("Only .jar files can be uploaded", "error")
+		session.Save(r, w)
+		http.Redirect(w, r, "/servers/new", http.StatusSeeOther)
+		return
+	}
+
+	label := strings.TrimSpace(r.FormValue("label"))
+	jar, existing, err := services.AddJarToLibrary(file, header.Filename, label, userID)
+	if err != nil {
+		session.AddFlash("Error adding jar: "+err.Error(), "error")
+		session.Save(r, w)
+		http.Redirect(w, r, "/servers/new", http.StatusSeeOther)
+		return
+	}
+
+	if existing {
+		session.AddFlash("This jar is already in the library as '"+jar.DisplayName()+"'", "success")
+	} else {
+		session.AddFlash("Jar '"+jar.DisplayName()+"' added to the library", "success")
+	}
+	session.Save(r, w)
+
+	http.Redirect(w, r, "/servers/new", http.StatusSeeOther)
+}
+
+// DeleteJar handles removing a jar from the library
+func DeleteJar(w http.ResponseWriter, r *http.Request) {
+	session, _ := config.GetSessionStore().Get(r, "auth-session")
+
+	id, _ := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	jar, err := models.GetJarFileByID(uint(id))
+	if err != nil {
+		session.AddFlash("Jar not found", "error")
+		session.Save(r, w)
+		http.Redirect(w, r, "/servers/new", http.StatusSeeOther)
+		return
+	}
+
+	if err := services.RemoveJarFromLibrary(jar, middleware.GetUserID(r)); err != nil {
+		session.AddFlash("Error removing jar: "+err.Error(), "error")
+		session.Save(r, w)
+		http.Redirect(w, r, "/servers/new", http.StatusSeeOther)
+		return
+	}
+
+	session.AddFlash("Jar removed from the library", "success")
+	session.Save(r, w)
+
+	http.Redirect(w, r, "/servers/new", http.StatusSeeOther)
+}
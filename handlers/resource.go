@@ -40,38 +40,15 @@ func ResourcePage(w http.ResponseWriter, r *http.Request) {
 
 // GetSystemStats returns current system statistics as JSON
 func GetSystemStats(w http.ResponseWriter, r *http.Request) {
-	// Get CPU stats
-	cpuUsage, err := services.GetCPUUsage()
-	if err != nil {
-		cpuUsage = 0
-	}
-
-	// Get memory stats
-	memStats, err := services.GetMemoryStats()
-	if err != nil {
-		memStats = &services.MemoryStats{
-			Total:       0,
-			Used:        0,
-			Free:        0,
-			UsedPercent: 0,
-		}
-	}
-
-	// Get disk stats
-	diskStats, err := services.GetDiskStats()
-	if err != nil {
-		diskStats = &services.DiskStats{
-			Total:       0,
-			Used:        0,
-			Free:        0,
-			UsedPercent: 0,
-		}
-	}
+	// Latest sample from the background collector
+	snapshot := services.GetSystemSnapshot()
+	memStats := snapshot.Memory
+	swapStats := snapshot.Swap
+	diskStats := snapshot.Disk
+	sysInfo := snapshot.Info
 
-	// Get system info
-	sysInfo, err := services.GetSystemInfo()
-	if err != nil {
-		sysInfo = &services.SystemInfo{
+	if sysInfo.CPUModel == "" {
+		sysInfo = services.SystemInfo{
 			CPUModel: "Unknown",
 			CPUCores: 0,
 			CPUSpeed: "Unknown",
@@ -91,21 +68,46 @@ func GetSystemStats(w http.ResponseWriter, r *http.Request) {
 	// Prepare response
 	response := map[string]interface{}{
 		"cpu": map[string]interface{}{
-			"usage":   cpuUsage,
-			"model":   sysInfo.CPUModel,
-			"cores":   sysInfo.CPUCores,
-			"speed":   sysInfo.CPUSpeed,
-			"percent": cpuUsage,
+			"usage":    snapshot.CPUPercent,
+			"model":    sysInfo.CPUModel,
+			"cores":    sysInfo.CPUCores,
+			"speed":    sysInfo.CPUSpeed,
+			"percent":  snapshot.CPUPercent,
+			"per_core": snapshot.PerCore,
+			"breakdown": map[string]interface{}{
+				"user":    snapshot.Breakdown.User,
+				"nice":    snapshot.Breakdown.Nice,
+				"system":  snapshot.Breakdown.System,
+				"idle":    snapshot.Breakdown.Idle,
+				"iowait":  snapshot.Breakdown.IOWait,
+				"irq":     snapshot.Breakdown.IRQ,
+				"softirq": snapshot.Breakdown.SoftIRQ,
+				"steal":   snapshot.Breakdown.Steal,
+			},
+		},
+		"load": map[string]interface{}{
+			"load1":  snapshot.Load.Load1,
+			"load5":  snapshot.Load.Load5,
+			"load15": snapshot.Load.Load15,
 		},
 		"memory": map[string]interface{}{
-			"total":         memStats.Total,
-			"used":          memStats.Used,
-			"free":          memStats.Free,
-			"used_percent":  memStats.UsedPercent,
-			"total_gb":      float64(memStats.Total) / (1024 * 1024 * 1024),
-			"used_gb":       float64(memStats.Used) / (1024 * 1024 * 1024),
+			"total":        memStats.Total,
+			"used":         memStats.Used,
+			"free":         memStats.Free,
+			"used_percent": memStats.UsedPercent,
+			"total_gb":     float64(memStats.Total) / (1024 * 1024 * 1024),
+			"used_gb":      float64(memStats.Used) / (1024 * 1024 * 1024),
+		},
+		"swap": map[string]interface{}{
+			"total":        swapStats.Total,
+			"used":         swapStats.Used,
+			"free":         swapStats.Free,
+			"used_percent": swapStats.UsedPercent,
+			"total_gb":     float64(swapStats.Total) / (1024 * 1024 * 1024),
+			"used_gb":      float64(swapStats.Used) / (1024 * 1024 * 1024),
 		},
 		"disk": map[string]interface{}{
+			"path":         services.DiskStatsPath(),
 			"total":        diskStats.Total,
 			"used":         diskStats.Used,
 			"free":         diskStats.Free,
@@ -117,8 +119,49 @@ func GetSystemStats(w http.ResponseWriter, r *http.Request) {
 			"total":  len(servers),
 			"active": activeServers,
 		},
+		"updated_at": snapshot.UpdatedAt.Unix(),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
-}
\ No newline at end of file
+}
+
+// GetDiskUsage returns usage of every mount, the server folder filesystem
+// and the cached folder size breakdown of the user's servers
+func GetDiskUsage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	mounts, err := services.GetMountStats()
+	if err != nil {
+		mounts = []services.MountStats{}
+	}
+
+	response := map[string]interface{}{
+		"mounts":  mounts,
+		"servers": services.GetFolderUsage(middleware.GetUserID(r)),
+	}
+
+	if path := config.GetServerPath(); path != "" {
+		if serverFS, err := services.GetDiskStatsForPath(path); err == nil {
+			response["server_filesystem"] = serverFS
+		}
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// RefreshDiskUsage queues a rescan of a server folder's size breakdown
+func RefreshDiskUsage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID := middleware.GetUserID(r)
+	server, err := models.GetServerByName(r.URL.Query().Get("server"), userID)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Server not found"})
+		return
+	}
+
+	services.RequestFolderUsageRefresh(server)
+	json.NewEncoder(w).Encode(map[string]string{"status": "Refresh queued"})
+}
@@ -2,11 +2,15 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"html/template"
 	"io/ioutil"
 	"net/http"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strconv"
 	"strings"
 
 	"minecraft-server-controller/config"
@@ -55,11 +59,25 @@ func Dashboard(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	archivedCount := 0
+	hasProxies := false
+	for _, server := range servers {
+		if server.Archived {
+			archivedCount++
+		} else if models.IsProxySoftware(server.Software) {
+			hasProxies = true
+		}
+	}
+
 	data := map[string]interface{}{
-		"User":    user,
-		"Servers": servers,
-		"Success": session.Flashes("success"),
-		"Error":   session.Flashes("error"),
+		"User":          user,
+		"Servers":       servers,
+		"ArchivedCount": archivedCount,
+		"Groups":        groupViews(userID),
+		"HasProxies":    hasProxies,
+		"SoftwareNames": models.SoftwareNames,
+		"Success":       session.Flashes("success"),
+		"Error":         session.Flashes("error"),
 	}
 	session.Save(r, w)
 
@@ -87,51 +105,87 @@ func scanAndSyncServers(userID uint, serverPath string) ([]models.Server, error)
 	}
 
 	for _, entry := range entries {
-		if entry.IsDir() {
+		// Skip hidden folders such as .trash
+		if entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
 			serverName := entry.Name()
 			fullPath := filepath.Join(serverPath, serverName)
 
 			// Check if server already exists
 			if _, exists := serverMap[serverName]; !exists {
 				// Find startup script
-				startupCmd := findStartupCommand(fullPath)
+				startupCmd, execMode := findStartupCommand(fullPath)
 				if startupCmd != "" {
 					// Create new server entry
-					models.CreateServer(serverName, fullPath, startupCmd, userID)
+					if server, err := models.CreateServer(serverName, fullPath, startupCmd, userID); err == nil {
+						if execMode != services.ExecDirect {
+							server.UpdateStartup(startupCmd, execMode, nil)
+						}
+						services.RefreshServerSoftware(server)
+					}
 				}
 			}
 		}
 	}
 
+	// Flag servers whose folder vanished, and clear the flag when it is back
+	for i := range existingServers {
+		server := &existingServers[i]
+		_, statErr := os.Stat(server.FolderPath)
+		missing := os.IsNotExist(statErr)
+		if missing != server.Missing {
+			server.SetMissing(missing)
+		}
+		// Detect the software again once the jar changes, such as after a
+		// Paper update or a switch to Purpur
+		if !missing {
+			services.RefreshStaleServerSoftware(server)
+		}
+	}
+
 	// Return updated server list
 	return models.GetServersByUserID(userID)
 }
 
-// findStartupCommand looks for common startup scripts/commands
-func findStartupCommand(serverPath string) string {
-	// Check for common script files
-	scripts := []string{"start.sh", "start.bat", "run.sh", "run.bat"}
+// findStartupCommand looks for common startup scripts/commands and returns
+// the command along with the exec mode it needs
+func findStartupCommand(serverPath string) (string, string) {
+	// Check for common script files; batch files only work on Windows
+	scripts := []string{"start.sh", "run.sh"}
+	if runtime.GOOS == "windows" {
+		scripts = []string{"start.bat", "run.bat"}
+	}
 	for _, script := range scripts {
 		scriptPath := filepath.Join(serverPath, script)
 		if _, err := os.Stat(scriptPath); err == nil {
-			return "./" + script
+			// Run through /bin/sh so the script does not need to be executable
+			if runtime.GOOS != "windows" {
+				return "./" + script, services.ExecShell
+			}
+			return script, services.ExecDirect
 		}
 	}
 
+	// Fabric starts through its launcher, which runs the vanilla jar
+	if _, err := os.Stat(filepath.Join(serverPath, "fabric-server-launch.jar")); err == nil {
+		java := services.JavaForJar(filepath.Join(serverPath, "server.jar"))
+		return services.QuoteArg(java) + " -Xmx2G -Xms2G -jar fabric-server-launch.jar", services.ExecDirect
+	}
+
 	// Look for server JAR files
 	entries, err := ioutil.ReadDir(serverPath)
 	if err != nil {
-		return ""
+		return "", ""
 	}
 
 	for _, entry := range entries {
 		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".jar") {
 			// Default startup command without --nogui
-			return "java -Xmx2G -Xms2G -jar " + entry.Name()
+			java := services.JavaForJar(filepath.Join(serverPath, entry.Name()))
+			return services.QuoteArg(java) + " -Xmx2G -Xms2G -jar " + services.QuoteArg(entry.Name()), services.ExecDirect
 		}
 	}
 
-	return ""
+	return "", ""
 }
 
 // ServerConsolePage renders the server console page
@@ -166,6 +220,9 @@ func ServerConsolePage(w http.ResponseWriter, r *http.Request) {
 		"Success": session.Flashes("success"),
 		"Error":   session.Flashes("error"),
 	}
+	if crashes, err := models.GetCrashReports(server.ID, 1); err == nil && len(crashes) > 0 {
+		data["LastCrash"] = &crashes[0]
+	}
 	session.Save(r, w)
 
 	tmpl.Execute(w, data)
@@ -183,14 +240,7 @@ func StartServer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := services.StartServer(server); err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
-		return
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "Server started successfully"})
+	startOperation(w, services.OperationStart, server, "Server is starting")
 }
 
 // StopServer handles stopping a server
@@ -213,14 +263,7 @@ func StopServer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := services.StopServer(server); err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
-		return
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "Server stopped successfully"})
+	startOperation(w, services.OperationStop, server, "Server is stopping")
 }
 
 // RestartServer handles restarting a server
@@ -243,14 +286,7 @@ func RestartServer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := services.RestartServer(server); err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
-		return
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "Server restarted successfully"})
+	startOperation(w, services.OperationRestart, server, "Server is restarting")
 }
 
 // SendCommand sends a command to the server console
@@ -282,6 +318,12 @@ func SendCommand(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	data := map[string]interface{}{"command": command}
+	if user, err := models.GetUserByID(userID); err == nil {
+		data["user"] = user.Username
+	}
+	services.PublishEvent(services.EventCommandExecuted, server, data)
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"status": "Command sent successfully"})
 }
@@ -393,17 +435,148 @@ func StartupPage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	execMode := server.ExecMode
+	if execMode == "" {
+		execMode = services.ExecDirect
+	}
+
+	commandMode := models.CommandRaw
+	if server.UsesLaunchProfile() {
+		commandMode = models.CommandProfile
+	}
+
+	// Servers without a profile start the editor from their current command
+	profile := server.LaunchProfile
+	if profile == nil {
+		profile = services.ProfileFromCommand(server.StartupCommand, server.Env)
+	}
+
+	serverArgs := make([]string, len(profile.ServerArgs))
+	for i, arg := range profile.ServerArgs {
+		serverArgs[i] = services.QuoteArg(arg)
+	}
+
+	process := models.ProcessOptions{}
+	if server.ProcessOptions != nil {
+		process = *server.ProcessOptions
+	}
+	stop := models.StopSettings{}
+	if server.StopSettings != nil {
+		stop = *server.StopSettings
+	}
+
 	data := map[string]interface{}{
-		"User":    user,
-		"Server":  server,
-		"Success": session.Flashes("success"),
-		"Error":   session.Flashes("error"),
+		"User":        user,
+		"Server":      server,
+		"ExecMode":    execMode,
+		"CommandMode": commandMode,
+		"Profile":     profile,
+		"JVMArgs":     strings.Join(profile.JVMArgs, "\n"),
+		"ServerArgs":  strings.Join(serverArgs, " "),
+		"GCPresets":   services.GCPresets,
+		"Runtimes":    services.DiscoverJavaRuntimes(),
+		"EnvText":     services.FormatEnvLines(server.Env),
+		"Process":     process,
+		"Stop":        stop,
+		"StopDefault": models.DefaultStopCommand(server.Software),
+		"StopTimeout": config.GetStopEscalation().StopTimeoutSeconds,
+		"EnvAllowed":  strings.Join(config.GetEnvAllowlist(), " "),
+		"Dependencies": append(append([]models.ServerDependency{}, server.Dependencies...), models.ServerDependency{}),
+		"Others":       otherServers(server),
+		"ReadyPattern": services.DefaultReadyPattern,
+		"ReadyTimeout": int(services.DefaultReadyTimeout.Seconds()),
+		"Success":     session.Flashes("success"),
+		"Error":       session.Flashes("error"),
 	}
 	session.Save(r, w)
 
 	tmpl.Execute(w, data)
 }
 
+// startupForm is the submitted startup configuration of a server
+type startupForm struct {
+	commandMode string
+	profile     *models.LaunchProfile
+	command     string
+	execMode    string
+	env         map[string]string
+}
+
+// parseStartupForm reads the startup page form. In profile mode the command
+// is rendered from the submitted profile.
+func parseStartupForm(r *http.Request) (*startupForm, error) {
+	env, err := services.ParseEnvLines(r.FormValue("env"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid environment: %w", err)
+	}
+
+	form := &startupForm{
+		commandMode: r.FormValue("command_mode"),
+		command:     strings.TrimSpace(r.FormValue("command")),
+		execMode:    r.FormValue("exec_mode"),
+		env:         env,
+	}
+	if form.execMode == "" {
+		form.execMode = services.ExecDirect
+	}
+
+	if form.commandMode != models.CommandProfile {
+		form.commandMode = models.CommandRaw
+		if form.command == "" {
+			return nil, errors.New("startup command cannot be empty")
+		}
+		return form, nil
+	}
+
+	minHeap, _ := strconv.Atoi(r.FormValue("min_heap"))
+	maxHeap, _ := strconv.Atoi(r.FormValue("max_heap"))
+	port, _ := strconv.Atoi(r.FormValue("port"))
+
+	serverArgs, err := services.SplitCommand(r.FormValue("server_args"), nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid server arguments: %w", err)
+	}
+
+	jvmArgs := []string{}
+	for _, line := range strings.Split(r.FormValue("jvm_args"), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			jvmArgs = append(jvmArgs, line)
+		}
+	}
+
+	form.profile = &models.LaunchProfile{
+		JavaPath:   strings.TrimSpace(r.FormValue("java_path")),
+		MinHeapMB:  minHeap,
+		MaxHeapMB:  maxHeap,
+		GCPreset:   r.FormValue("gc_preset"),
+		JVMArgs:    jvmArgs,
+		JarPath:    strings.TrimSpace(r.FormValue("jar_path")),
+		NoGUI:      r.FormValue("nogui") == "on",
+		Port:       port,
+		ServerArgs: serverArgs,
+		WorkingDir: strings.TrimSpace(r.FormValue("working_dir")),
+	}
+	if err := services.ValidateLaunchProfile(form.profile); err != nil {
+		return nil, err
+	}
+
+	form.command = services.RenderLaunchProfile(form.profile)
+	form.execMode = services.ExecDirect
+	return form, nil
+}
+
+// check validates the startup configuration against the server folder
+func (f *startupForm) check(server *models.Server) services.StartupCheck {
+	folder := server.FolderPath
+	if f.profile != nil && f.profile.WorkingDir != "" {
+		folder = filepath.Join(server.FolderPath, f.profile.WorkingDir)
+	}
+
+	check := services.ValidateStartup(f.command, f.execMode, f.env, folder)
+	check.Command = f.command
+	return check
+}
+
 // UpdateStartup handles updating the startup command
 func UpdateStartup(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -421,30 +594,267 @@ func UpdateStartup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	command := r.FormValue("command")
-
 	session, _ := config.GetSessionStore().Get(r, "auth-session")
 
-	if command == "" {
-		session.AddFlash("Startup command cannot be empty", "error")
+	form, err := parseStartupForm(r)
+	if err != nil {
+		session.AddFlash(err.Error(), "error")
 		session.Save(r, w)
 		http.Redirect(w, r, "/server/"+serverName+"/startup", http.StatusSeeOther)
 		return
 	}
 
-	if err := server.UpdateStartupCommand(command); err != nil {
+	// Refuse commands that would not start
+	if check := form.check(server); check.Error != "" {
+		session.AddFlash("Invalid startup command: "+check.Error, "error")
+		session.Save(r, w)
+		http.Redirect(w, r, "/server/"+serverName+"/startup", http.StatusSeeOther)
+		return
+	}
+
+	if form.commandMode == models.CommandProfile {
+		err = server.UpdateLaunchProfile(form.profile, form.command, form.env)
+	} else {
+		err = server.UpdateStartup(form.command, form.execMode, form.env)
+	}
+	if err != nil {
 		session.AddFlash("Error updating startup command: "+err.Error(), "error")
 		session.Save(r, w)
 		http.Redirect(w, r, "/server/"+serverName+"/startup", http.StatusSeeOther)
 		return
 	}
 
+	// The command may now start another jar
+	services.RefreshServerSoftware(server)
+
 	session.AddFlash("Startup command updated successfully", "success")
 	session.Save(r, w)
 
 	http.Redirect(w, r, "/server/"+serverName+"/startup", http.StatusSeeOther)
 }
 
+// ValidateStartup checks a startup configuration without saving it and
+// returns the command line it renders to
+func ValidateStartup(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	serverName := vars["name"]
+	userID := middleware.GetUserID(r)
+
+	w.Header().Set("Content-Type", "application/json")
+
+	server, err := models.GetServerByName(serverName, userID)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Server not found"})
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Error parsing form"})
+		return
+	}
+
+	form, err := parseStartupForm(r)
+	if err != nil {
+		json.NewEncoder(w).Encode(services.StartupCheck{Args: []string{}, Warnings: []string{}, Error: err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(form.check(server))
+}
+
+// UpdateProcessOptions handles the user, umask and priority a server runs with
+func UpdateProcessOptions(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	serverName := vars["name"]
+	userID := middleware.GetUserID(r)
+
+	server, err := models.GetServerByName(serverName, userID)
+	if err != nil {
+		http.Error(w, "Server not found", http.StatusNotFound)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Error parsing form", http.StatusBadRequest)
+		return
+	}
+
+	session, _ := config.GetSessionStore().Get(r, "auth-session")
+
+	options := &models.ProcessOptions{
+		User:    strings.TrimSpace(r.FormValue("run_user")),
+		Group:   strings.TrimSpace(r.FormValue("run_group")),
+		Umask:   strings.TrimSpace(r.FormValue("umask")),
+		IOClass: r.FormValue("io_class"),
+	}
+	options.Nice, _ = strconv.Atoi(r.FormValue("nice"))
+	options.IOLevel, _ = strconv.Atoi(r.FormValue("io_level"))
+	if options.IOClass != models.IOClassBestEffort {
+		options.IOLevel = 0
+	}
+
+	if err := services.ValidateProcessOptions(options); err != nil {
+		session.AddFlash("Invalid process settings: "+err.Error(), "error")
+		session.Save(r, w)
+		http.Redirect(w, r, "/server/"+serverName+"/startup", http.StatusSeeOther)
+		return
+	}
+
+	if err := server.UpdateProcessOptions(options); err != nil {
+		session.AddFlash("Error updating process settings: "+err.Error(), "error")
+		session.Save(r, w)
+		http.Redirect(w, r, "/server/"+serverName+"/startup", http.StatusSeeOther)
+		return
+	}
+
+	session.AddFlash("Process settings updated; they apply from the next start", "success")
+	session.Save(r, w)
+
+	http.Redirect(w, r, "/server/"+serverName+"/startup", http.StatusSeeOther)
+}
+
+// UpdateStopSettings handles the stop command, timeout and countdown of a server
+func UpdateStopSettings(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	serverName := vars["name"]
+	userID := middleware.GetUserID(r)
+
+	server, err := models.GetServerByName(serverName, userID)
+	if err != nil {
+		http.Error(w, "Server not found", http.StatusNotFound)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Error parsing form", http.StatusBadRequest)
+		return
+	}
+
+	session, _ := config.GetSessionStore().Get(r, "auth-session")
+
+	settings := &models.StopSettings{Command: strings.TrimSpace(r.FormValue("stop_command"))}
+	timeout, err1 := strconv.Atoi(strings.TrimSpace(r.FormValue("stop_timeout")))
+	countdown, err2 := strconv.Atoi(strings.TrimSpace(r.FormValue("countdown")))
+	if r.FormValue("stop_timeout") == "" {
+		timeout, err1 = 0, nil
+	}
+	if r.FormValue("countdown") == "" {
+		countdown, err2 = 0, nil
+	}
+	if err1 != nil || err2 != nil || timeout < 0 || countdown < 0 {
+		session.AddFlash("Stop timeout and countdown must be whole numbers of seconds", "error")
+		session.Save(r, w)
+		http.Redirect(w, r, "/server/"+serverName+"/startup", http.StatusSeeOther)
+		return
+	}
+	if strings.Contains(settings.Command, "\n") {
+		session.AddFlash("The stop command must be a single line", "error")
+		session.Save(r, w)
+		http.Redirect(w, r, "/server/"+serverName+"/startup", http.StatusSeeOther)
+		return
+	}
+	settings.TimeoutSeconds, settings.CountdownSeconds = timeout, countdown
+
+	if err := server.UpdateStopSettings(settings); err != nil {
+		session.AddFlash("Error updating stop settings: "+err.Error(), "error")
+		session.Save(r, w)
+		http.Redirect(w, r, "/server/"+serverName+"/startup", http.StatusSeeOther)
+		return
+	}
+	services.ApplyStopSettings(server)
+
+	session.AddFlash("Stop settings updated successfully", "success")
+	session.Save(r, w)
+
+	http.Redirect(w, r, "/server/"+serverName+"/startup", http.StatusSeeOther)
+}
+
+// UpdateDependencies handles what a server waits for before it starts
+func UpdateDependencies(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	serverName := vars["name"]
+	userID := middleware.GetUserID(r)
+
+	server, err := models.GetServerByName(serverName, userID)
+	if err != nil {
+		http.Error(w, "Server not found", http.StatusNotFound)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Error parsing form", http.StatusBadRequest)
+		return
+	}
+
+	session, _ := config.GetSessionStore().Get(r, "auth-session")
+
+	// Each row of the table submits one value of every field; rows without
+	// a condition are left out
+	conditions := r.Form["dep_condition"]
+	field := func(name string, i int) string {
+		if values := r.Form[name]; i < len(values) {
+			return strings.TrimSpace(values[i])
+		}
+		return ""
+	}
+	dependencies := []models.ServerDependency{}
+	for i, condition := range conditions {
+		if condition == "" {
+			continue
+		}
+		serverID, _ := strconv.ParseUint(field("dep_server", i), 10, 64)
+		timeout := 0
+		if value := field("dep_timeout", i); value != "" {
+			if timeout, err = strconv.Atoi(value); err != nil {
+				session.AddFlash("Timeouts must be whole numbers of seconds", "error")
+				session.Save(r, w)
+				http.Redirect(w, r, "/server/"+serverName+"/startup", http.StatusSeeOther)
+				return
+			}
+		}
+		dependencies = append(dependencies, models.ServerDependency{
+			ServerID:       uint(serverID),
+			Condition:      condition,
+			Address:        field("dep_address", i),
+			Pattern:        field("dep_pattern", i),
+			TimeoutSeconds: timeout,
+		})
+	}
+
+	if err := services.ValidateDependencies(server, dependencies); err != nil {
+		session.AddFlash("Invalid dependencies: "+err.Error(), "error")
+		session.Save(r, w)
+		http.Redirect(w, r, "/server/"+serverName+"/startup", http.StatusSeeOther)
+		return
+	}
+
+	if err := server.UpdateDependencies(dependencies); err != nil {
+		session.AddFlash("Error updating dependencies: "+err.Error(), "error")
+		session.Save(r, w)
+		http.Redirect(w, r, "/server/"+serverName+"/startup", http.StatusSeeOther)
+		return
+	}
+
+	session.AddFlash("Dependencies updated successfully", "success")
+	session.Save(r, w)
+
+	http.Redirect(w, r, "/server/"+serverName+"/startup", http.StatusSeeOther)
+}
+
+// otherServers returns the servers of the owner a server can depend on
+func otherServers(server *models.Server) []models.Server {
+	servers, _ := models.GetServersByUserID(server.UserID)
+	others := []models.Server{}
+	for _, other := range servers {
+		if other.ID != server.ID && !other.Archived {
+			others = append(others, other)
+		}
+	}
+	return others
+}
+
 // FilesPage renders the file manager page (Coming Soon)
 func FilesPage(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -4,10 +4,14 @@ import (
 	"html/template"
 	"net/http"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 
 	"minecraft-server-controller/config"
 	"minecraft-server-controller/middleware"
 	"minecraft-server-controller/models"
+	"minecraft-server-controller/services"
 )
 
 // SettingsPage renders the settings page
@@ -28,10 +32,16 @@ func SettingsPage(w http.ResponseWriter, r *http.Request) {
 	}
 
 	data := map[string]interface{}{
-		"User":        user,
-		"CurrentPath": config.GetServerPath(),
-		"Success":     session.Flashes("success"),
-		"Error":       session.Flashes("error"),
+		"User":         user,
+		"CurrentPath":  config.GetServerPath(),
+		"MetricsToken": user.MetricsToken,
+		"JavaRuntimes": services.DiscoverJavaRuntimes(),
+		"JavaPaths":    strings.Join(config.GetJavaPaths(), "\n"),
+		"MemoryBudget": config.GetMemoryBudget(),
+		"EnvAllowlist": strings.Join(config.GetEnvAllowlist(), "\n"),
+		"StopTimeouts": config.GetStopEscalation(),
+		"Success":      session.Flashes("success"),
+		"Error":        session.Flashes("error"),
 	}
 	session.Save(r, w)
 
@@ -95,4 +105,183 @@ func UpdateServerPath(w http.ResponseWriter, r *http.Request) {
 	session.Save(r, w)
 
 	http.Redirect(w, r, "/settings", http.StatusSeeOther)
-}
\ No newline at end of file
+}
+
+// UpdateMetricsToken generates or disables the /metrics token of the user
+func UpdateMetricsToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Error parsing form", http.StatusBadRequest)
+		return
+	}
+
+	user, err := models.GetUserByID(middleware.GetUserID(r))
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	session, _ := config.GetSessionStore().Get(r, "auth-session")
+
+	var message string
+	switch r.FormValue("action") {
+	case "generate":
+		err = user.RegenerateMetricsToken()
+		message = "Metrics token generated"
+	case "disable":
+		err = user.DisableMetricsToken()
+		message = "Metrics endpoint disabled"
+	default:
+		session.AddFlash("Unknown action", "error")
+		session.Save(r, w)
+		http.Redirect(w, r, "/settings", http.StatusSeeOther)
+		return
+	}
+
+	if err != nil {
+		session.AddFlash("Error updating metrics token: "+err.Error(), "error")
+		session.Save(r, w)
+		http.Redirect(w, r, "/settings", http.StatusSeeOther)
+		return
+	}
+
+	session.AddFlash(message, "success")
+	session.Save(r, w)
+
+	http.Redirect(w, r, "/settings", http.StatusSeeOther)
+}
+
+
+// UpdateJavaPaths updates the extra paths searched for Java runtimes
+func UpdateJavaPaths(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Error parsing form", http.StatusBadRequest)
+		return
+	}
+
+	session, _ := config.GetSessionStore().Get(r, "auth-session")
+
+	paths := []string{}
+	for _, line := range strings.Split(r.FormValue("java_paths"), "\n") {
+		path := strings.TrimSpace(line)
+		if path == "" {
+			continue
+		}
+		if !filepath.IsAbs(path) {
+			session.AddFlash("Java path must be absolute: "+path, "error")
+			session.Save(r, w)
+			http.Redirect(w, r, "/settings", http.StatusSeeOther)
+			return
+		}
+		paths = append(paths, path)
+	}
+
+	if err := config.UpdateJavaPaths(paths); err != nil {
+		session.AddFlash("Error updating Java paths: "+err.Error(), "error")
+		session.Save(r, w)
+		http.Redirect(w, r, "/settings", http.StatusSeeOther)
+		return
+	}
+
+	session.AddFlash("Java paths updated successfully", "success")
+	session.Save(r, w)
+
+	http.Redirect(w, r, "/settings", http.StatusSeeOther)
+}
+
+// UpdateMemoryBudget updates the memory check done before servers start
+func UpdateMemoryBudget(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Error parsing form", http.StatusBadRequest)
+		return
+	}
+
+	session, _ := config.GetSessionStore().Get(r, "auth-session")
+
+	budget := config.MemoryBudget{Policy: r.FormValue("policy")}
+	overheadPercent, err1 := strconv.Atoi(r.FormValue("overhead_percent"))
+	overheadMB, err2 := strconv.Atoi(r.FormValue("overhead_mb"))
+	totalMB, err3 := strconv.Atoi(r.FormValue("total_mb"))
+	budget.OverheadPercent, budget.OverheadMB, budget.TotalMB = overheadPercent, overheadMB, totalMB
+
+	switch {
+	case budget.Policy != config.MemoryPolicyOff && budget.Policy != config.MemoryPolicyWarn && budget.Policy != config.MemoryPolicyRefuse:
+		session.AddFlash("Unknown memory policy: "+budget.Policy, "error")
+	case err1 != nil || err2 != nil || err3 != nil || overheadPercent < 0 || overheadMB < 0 || totalMB < 0:
+		session.AddFlash("Memory overhead and budget must be whole numbers of zero or more", "error")
+	default:
+		if err := config.UpdateMemoryBudget(budget); err != nil {
+			session.AddFlash("Error updating memory budget: "+err.Error(), "error")
+		} else {
+			session.AddFlash("Memory budget updated successfully", "success")
+		}
+	}
+	session.Save(r, w)
+
+	http.Redirect(w, r, "/settings", http.StatusSeeOther)
+}
+
+// UpdateEnvAllowlist updates the controller environment variables servers get
+func UpdateEnvAllowlist(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Error parsing form", http.StatusBadRequest)
+		return
+	}
+
+	session, _ := config.GetSessionStore().Get(r, "auth-session")
+
+	names := []string{}
+	for _, name := range strings.Fields(r.FormValue("env_allowlist")) {
+		if strings.Contains(name, "=") || strings.Contains(strings.TrimSuffix(name, "*"), "*") {
+			session.AddFlash("Invalid variable name: "+name, "error")
+			session.Save(r, w)
+			http.Redirect(w, r, "/settings", http.StatusSeeOther)
+			return
+		}
+		names = append(names, name)
+	}
+
+	if err := config.UpdateEnvAllowlist(names); err != nil {
+		session.AddFlash("Error updating environment allowlist: "+err.Error(), "error")
+		session.Save(r, w)
+		http.Redirect(w, r, "/settings", http.StatusSeeOther)
+		return
+	}
+
+	session.AddFlash("Environment allowlist updated successfully", "success")
+	session.Save(r, w)
+
+	http.Redirect(w, r, "/settings", http.StatusSeeOther)
+}
+
+// UpdateStopEscalation updates how long stopping a server waits before
+// sending SIGTERM and SIGKILL
+func UpdateStopEscalation(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Error parsing form", http.StatusBadRequest)
+		return
+	}
+
+	session, _ := config.GetSessionStore().Get(r, "auth-session")
+
+	stopTimeout, err1 := strconv.Atoi(r.FormValue("stop_timeout"))
+	termTimeout, err2 := strconv.Atoi(r.FormValue("term_timeout"))
+	if err1 != nil || err2 != nil || stopTimeout < 1 || termTimeout < 1 {
+		session.AddFlash("Stop timeouts must be at least one second", "error")
+		session.Save(r, w)
+		http.Redirect(w, r, "/settings", http.StatusSeeOther)
+		return
+	}
+
+	escalation := config.StopEscalation{StopTimeoutSeconds: stopTimeout, TermTimeoutSeconds: termTimeout}
+	if err := config.UpdateStopEscalation(escalation); err != nil {
+		session.AddFlash("Error updating stop timeouts: "+err.Error(), "error")
+		session.Save(r, w)
+		http.Redirect(w, r, "/settings", http.StatusSeeOther)
+		return
+	}
+
+	session.AddFlash("Stop timeouts updated successfully", "success")
+	session.Save(r, w)
+
+	http.Redirect(w, r, "/settings", http.StatusSeeOther)
+}
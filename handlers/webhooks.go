@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"minecraft-server-controller/config"
+	"minecraft-server-controller/middleware"
+	"minecraft-server-controller/models"
+	"minecraft-server-controller/services"
+
+	"github.com/gorilla/mux"
+)
+
+// webhookDeliveryLimit is how many deliveries are shown per webhook
+const webhookDeliveryLimit = 10
+
+// webhookView is a webhook together with its latest deliveries
+type webhookView struct {
+	models.Webhook
+	Deliveries []models.WebhookDelivery
+}
+
+// WebhooksPage renders the webhooks page
+func WebhooksPage(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
+	user, err := models.GetUserByID(userID)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	session, _ := config.GetSessionStore().Get(r, "auth-session")
+
+	tmpl, err := template.ParseFiles("templates/webhooks.html")
+	if err != nil {
+		http.Error(w, "Error loading template", http.StatusInternalServerError)
+		return
+	}
+
+	webhooks, _ := models.GetWebhooksByUserID(userID)
+	views := make([]webhookView, 0, len(webhooks))
+	for _, webhook := range webhooks {
+		deliveries, _ := models.GetWebhookDeliveries(webhook.ID, webhookDeliveryLimit)
+		views = append(views, webhookView{Webhook: webhook, Deliveries: deliveries})
+	}
+
+	data := map[string]interface{}{
+		"User":       user,
+		"Webhooks":   views,
+		"EventTypes": services.EventTypes,
+		"Success":    session.Flashes("success"),
+		"Error":      session.Flashes("error"),
+	}
+	session.Save(r, w)
+
+	tmpl.Execute(w, data)
+}
+
+// CreateWebhook handles adding a webhook
+func CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Error parsing form", http.StatusBadRequest)
+		return
+	}
+
+	userID := middleware.GetUserID(r)
+	session, _ := config.GetSessionStore().Get(r, "auth-session")
+
+	name := strings.TrimSpace(r.FormValue("name"))
+	endpoint := strings.TrimSpace(r.FormValue("url"))
+	format := r.FormValue("format")
+	secret := strings.TrimSpace(r.FormValue("secret"))
+
+	if name == "" {
+		session.AddFlash("Name cannot be empty", "error")
+		session.Save(r, w)
+		http.Redirect(w, r, "/webhooks", http.StatusSeeOther)
+		return
+	}
+
+	parsed, err := url.Parse(endpoint)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		session.AddFlash("URL must be an http:// or https:// address", "error")
+		session.Save(r, w)
+		http.Redirect(w, r, "/webhooks", http.StatusSeeOther)
+		return
+	}
+
+	if format != models.WebhookFormatDiscord {
+		format = models.WebhookFormatJSON
+	}
+
+	events := []string{}
+	for _, event := range r.Form["events"] {
+		if services.IsEventType(event) {
+			events = append(events, event)
+		}
+	}
+	if len(events) == 0 {
+		session.AddFlash("Select at least one event", "error")
+		session.Save(r, w)
+		http.Redirect(w, r, "/webhooks", http.StatusSeeOther)
+		return
+	}
+
+	if secret == "" {
+		secret = services.GenerateWebhookSecret()
+	}
+
+	if _, err := models.CreateWebhook(name, endpoint, secret, events, format, userID); err != nil {
+		session.AddFlash("Error creating webhook: "+err.Error(), "error")
+		session.Save(r, w)
+		http.Redirect(w, r, "/webhooks", http.StatusSeeOther)
+		return
+	}
+
+	session.AddFlash("Webhook created successfully", "success")
+	session.Save(r, w)
+
+	http.Redirect(w, r, "/webhooks", http.StatusSeeOther)
+}
+
+// ToggleWebhook handles enabling or disabling a webhook
+func ToggleWebhook(w http.ResponseWriter, r *http.Request) {
+	session, _ := config.GetSessionStore().Get(r, "auth-session")
+
+	webhook, err := webhookFromRequest(r)
+	if err != nil {
+		session.AddFlash("Webhook not found", "error")
+		session.Save(r, w)
+		http.Redirect(w, r, "/webhooks", http.StatusSeeOther)
+		return
+	}
+
+	if err := webhook.SetEnabled(!webhook.Enabled); err != nil {
+		session.AddFlash("Error updating webhook: "+err.Error(), "error")
+		session.Save(r, w)
+		http.Redirect(w, r, "/webhooks", http.StatusSeeOther)
+		return
+	}
+
+	if webhook.Enabled {
+		session.AddFlash("Webhook enabled", "success")
+	} else {
+		session.AddFlash("Webhook disabled", "success")
+	}
+	session.Save(r, w)
+
+	http.Redirect(w, r, "/webhooks", http.StatusSeeOther)
+}
+
+// DeleteWebhook handles removing a webhook
+func DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	session, _ := config.GetSessionStore().Get(r, "auth-session")
+
+	webhook, err := webhookFromRequest(r)
+	if err != nil {
+		session.AddFlash("Webhook not found", "error")
+		session.Save(r, w)
+		http.Redirect(w, r, "/webhooks", http.StatusSeeOther)
+		return
+	}
+
+	if err := webhook.Delete(); err != nil {
+		session.AddFlash("Error deleting webhook: "+err.Error(), "error")
+		session.Save(r, w)
+		http.Redirect(w, r, "/webhooks", http.StatusSeeOther)
+		return
+	}
+
+	session.AddFlash("Webhook deleted", "success")
+	session.Save(r, w)
+
+	http.Redirect(w, r, "/webhooks", http.StatusSeeOther)
+}
+
+// TestWebhook sends a test delivery to a webhook
+func TestWebhook(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	webhook, err := webhookFromRequest(r)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Webhook not found"})
+		return
+	}
+
+	if err := services.SendTestWebhook(webhook, nil); err != nil {
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{"status": "Test delivery succeeded"})
+}
+
+// GetWebhookDeliveries returns the delivery log of a webhook as JSON
+func GetWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	webhook, err := webhookFromRequest(r)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Webhook not found"})
+		return
+	}
+
+	limit := 50
+	if value, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && value > 0 && value <= 200 {
+		limit = value
+	}
+
+	deliveries, err := models.GetWebhookDeliveries(webhook.ID, limit)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{"deliveries": deliveries})
+}
+
+// webhookFromRequest loads the webhook named by the {id} route variable,
+// limited to the current user's webhooks
+func webhookFromRequest(r *http.Request) (*models.Webhook, error) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	return models.GetWebhookByID(uint(id), middleware.GetUserID(r))
+}
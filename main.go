@@ -3,10 +3,14 @@ package main
 import (
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"minecraft-server-controller/config"
 	"minecraft-server-controller/handlers"
 	"minecraft-server-controller/middleware"
 	"minecraft-server-controller/models"
+	"minecraft-server-controller/services"
 
 	"github.com/gorilla/mux"
 )
@@ -18,6 +22,12 @@ func main() {
 	// Initialize configuration
 	config.Init()
 
+	// Start background metrics collection
+	services.StartSystemCollector()
+	services.StartMetricsSampler()
+	services.StartFolderUsageRefresher()
+	services.StartAlertEngine()
+
 	// Create router
 	r := mux.NewRouter()
 
@@ -30,6 +40,9 @@ func main() {
 	r.HandleFunc("/register", handlers.RegisterPage).Methods("GET")
 	r.HandleFunc("/register", handlers.Register).Methods("POST")
 
+	// Prometheus metrics (token authentication)
+	r.Handle("/metrics", middleware.MetricsTokenMiddleware(http.HandlerFunc(handlers.PrometheusMetrics))).Methods("GET")
+
 	// Protected routes (authentication required)
 	protected := r.PathPrefix("/").Subrouter()
 	protected.Use(middleware.AuthMiddleware)
@@ -37,6 +50,13 @@ func main() {
 	// Dashboard
 	protected.HandleFunc("/dashboard", handlers.Dashboard).Methods("GET")
 
+	// Server groups and bulk actions
+	protected.HandleFunc("/groups/create", handlers.CreateServerGroup).Methods("POST")
+	protected.HandleFunc("/groups/{id}/update", handlers.UpdateServerGroup).Methods("POST")
+	protected.HandleFunc("/groups/{id}/delete", handlers.DeleteServerGroup).Methods("POST")
+	protected.HandleFunc("/api/bulk", handlers.BulkAction).Methods("POST")
+	protected.HandleFunc("/api/network", handlers.GetNetwork).Methods("GET")
+
 	// Account management
 	protected.HandleFunc("/account", handlers.AccountPage).Methods("GET")
 	protected.HandleFunc("/account/update-username", handlers.UpdateUsername).Methods("POST")
@@ -45,10 +65,44 @@ func main() {
 	// Resource monitoring (NEW)
 	protected.HandleFunc("/resource", handlers.ResourcePage).Methods("GET")
 	protected.HandleFunc("/api/system/stats", handlers.GetSystemStats).Methods("GET")
+	protected.HandleFunc("/api/system/disks", handlers.GetDiskUsage).Methods("GET")
+	protected.HandleFunc("/api/system/disks/refresh", handlers.RefreshDiskUsage).Methods("POST")
+	protected.HandleFunc("/api/metrics", handlers.GetMetrics).Methods("GET")
+
+	// Lifecycle operations
+	protected.HandleFunc("/api/operations", handlers.GetOperations).Methods("GET")
+	protected.HandleFunc("/api/operations/ws", handlers.OperationsWebSocket).Methods("GET")
+	protected.HandleFunc("/api/operations/{id}", handlers.GetOperation).Methods("GET")
+
+	// Alerts
+	protected.HandleFunc("/alerts", handlers.AlertsPage).Methods("GET")
+	protected.HandleFunc("/alerts/rules", handlers.UpdateAlertRules).Methods("POST")
+	protected.HandleFunc("/alerts/notifications", handlers.UpdateNotifications).Methods("POST")
+	protected.HandleFunc("/api/alerts", handlers.GetAlerts).Methods("GET")
+	protected.HandleFunc("/api/alerts/test", handlers.TestAlert).Methods("POST")
+
+	// Webhooks
+	protected.HandleFunc("/webhooks", handlers.WebhooksPage).Methods("GET")
+	protected.HandleFunc("/webhooks/create", handlers.CreateWebhook).Methods("POST")
+	protected.HandleFunc("/webhooks/{id}/toggle", handlers.ToggleWebhook).Methods("POST")
+	protected.HandleFunc("/webhooks/{id}/delete", handlers.DeleteWebhook).Methods("POST")
+	protected.HandleFunc("/api/webhooks/{id}/test", handlers.TestWebhook).Methods("POST")
+	protected.HandleFunc("/api/webhooks/{id}/deliveries", handlers.GetWebhookDeliveries).Methods("GET")
 
 	// Settings
 	protected.HandleFunc("/settings", handlers.SettingsPage).Methods("GET")
 	protected.HandleFunc("/settings/update-path", handlers.UpdateServerPath).Methods("POST")
+	protected.HandleFunc("/settings/metrics-token", handlers.UpdateMetricsToken).Methods("POST")
+	protected.HandleFunc("/settings/java-paths", handlers.UpdateJavaPaths).Methods("POST")
+	protected.HandleFunc("/settings/memory-budget", handlers.UpdateMemoryBudget).Methods("POST")
+	protected.HandleFunc("/settings/env-allowlist", handlers.UpdateEnvAllowlist).Methods("POST")
+	protected.HandleFunc("/settings/stop-timeouts", handlers.UpdateStopEscalation).Methods("POST")
+
+	// Server creation
+	protected.HandleFunc("/servers/new", handlers.NewServerPage).Methods("GET")
+	protected.HandleFunc("/servers/new", handlers.ProvisionServer).Methods("POST")
+	protected.HandleFunc("/jars/upload", handlers.UploadJar).Methods("POST")
+	protected.HandleFunc("/jars/{id}/delete", handlers.DeleteJar).Methods("POST")
 
 	// Server management
 	protected.HandleFunc("/server/{name}", handlers.ServerConsolePage).Methods("GET")
@@ -60,9 +114,35 @@ func main() {
 	protected.HandleFunc("/server/{name}/stats", handlers.GetServerStats).Methods("GET")
 	protected.HandleFunc("/server/{name}/ws", handlers.ConsoleWebSocket).Methods("GET")
 
+	// Server lifecycle
+	protected.HandleFunc("/server/{name}/manage", handlers.ManagePage).Methods("GET")
+	protected.HandleFunc("/server/{name}/rename", handlers.RenameServer).Methods("POST")
+	protected.HandleFunc("/server/{name}/clone", handlers.CloneServer).Methods("POST")
+	protected.HandleFunc("/server/{name}/archive", handlers.ArchiveServer).Methods("POST")
+	protected.HandleFunc("/server/{name}/delete", handlers.DeleteServer).Methods("POST")
+	protected.HandleFunc("/server/{name}/detect", handlers.DetectSoftware).Methods("POST")
+	protected.HandleFunc("/server/{name}/ports/assign", handlers.AssignFreePort).Methods("POST")
+	protected.HandleFunc("/server/{name}/limits", handlers.UpdateResourceLimits).Methods("POST")
+
 	// Startup management
 	protected.HandleFunc("/server/{name}/startup", handlers.StartupPage).Methods("GET")
 	protected.HandleFunc("/server/{name}/startup/update", handlers.UpdateStartup).Methods("POST")
+	protected.HandleFunc("/server/{name}/startup/process", handlers.UpdateProcessOptions).Methods("POST")
+	protected.HandleFunc("/server/{name}/startup/stop", handlers.UpdateStopSettings).Methods("POST")
+	protected.HandleFunc("/server/{name}/startup/dependencies", handlers.UpdateDependencies).Methods("POST")
+	protected.HandleFunc("/server/{name}/startup/validate", handlers.ValidateStartup).Methods("POST")
+
+	// Crash reports
+	protected.HandleFunc("/server/{name}/crashes", handlers.CrashesPage).Methods("GET")
+	protected.HandleFunc("/server/{name}/crashes/{id}", handlers.CrashesPage).Methods("GET")
+	protected.HandleFunc("/server/{name}/crashes/{id}/file", handlers.CrashReportFile).Methods("GET")
+
+	// Plugins and mods
+	protected.HandleFunc("/server/{name}/addons", handlers.AddonsPage).Methods("GET")
+	protected.HandleFunc("/server/{name}/addons/list", handlers.GetAddons).Methods("GET")
+	protected.HandleFunc("/server/{name}/addons/upload", handlers.UploadAddon).Methods("POST")
+	protected.HandleFunc("/server/{name}/addons/toggle", handlers.ToggleAddon).Methods("POST")
+	protected.HandleFunc("/server/{name}/addons/remove", handlers.RemoveAddon).Methods("POST")
 
 	// Files (Coming Soon)
 	protected.HandleFunc("/server/{name}/files", handlers.FilesPage).Methods("GET")
@@ -70,6 +150,17 @@ func main() {
 	// Logout
 	protected.HandleFunc("/logout", handlers.Logout).Methods("GET")
 
+	// Stop the servers before exiting; they run in sessions of their own and
+	// would be left behind
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+		sig := <-signals
+		log.Printf("⏹️  Received %s, stopping all servers...", sig)
+		services.StopAllServers()
+		os.Exit(0)
+	}()
+
 	// Start server
 	log.Println("🚀 Minecraft Server Controller starting on http://localhost:6767")
 	log.Fatal(http.ListenAndServe(":6767", r))
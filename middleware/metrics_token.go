@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"minecraft-server-controller/models"
+)
+
+// MetricsTokenMiddleware protects the /metrics endpoint with the metrics
+// token of a user, given as "Authorization: Bearer <token>" or
+// "?token=<token>". A scrape only sees the servers of the token's user.
+func MetricsTokenMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := r.URL.Query().Get("token")
+		if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+			token = strings.TrimPrefix(auth, "Bearer ")
+		}
+
+		user, err := models.GetUserByMetricsToken(token)
+		if err != nil || subtle.ConstantTimeCompare([]byte(token), []byte(user.MetricsToken)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="metrics"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), UserIDKey, user.ID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,103 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Alert status values
+const (
+	AlertFiring   = "firing"
+	AlertResolved = "resolved"
+)
+
+// Alert records one firing/resolved cycle of an alert rule for a subject
+// (the host, a mount point or a server). ServerID is set for server subjects.
+type Alert struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	RuleID     string     `gorm:"index;not null" json:"rule_id"`
+	Subject    string     `gorm:"not null" json:"subject"`
+	ServerID   uint       `gorm:"index" json:"server_id,omitempty"`
+	Severity   string     `json:"severity"`
+	Status     string     `gorm:"index;not null" json:"status"`
+	Message    string     `json:"message"`
+	Value      float64    `json:"value"`
+	FiredAt    time.Time  `json:"fired_at"`
+	ResolvedAt *time.Time `json:"resolved_at"`
+	NotifiedAt *time.Time `json:"notified_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// CreateAlert records a newly firing alert. serverID is 0 unless the subject
+// is a server.
+func CreateAlert(ruleID, subject string, serverID uint, severity, message string, value float64) (*Alert, error) {
+	alert := &Alert{
+		RuleID:   ruleID,
+		Subject:  subject,
+		ServerID: serverID,
+		Severity: severity,
+		Status:   AlertFiring,
+		Message:  message,
+		Value:    value,
+		FiredAt:  time.Now(),
+	}
+
+	if err := DB.Create(alert).Error; err != nil {
+		return nil, err
+	}
+
+	return alert, nil
+}
+
+// Resolve marks the alert as resolved
+func (a *Alert) Resolve() error {
+	now := time.Now()
+	a.Status = AlertResolved
+	a.ResolvedAt = &now
+	return DB.Model(a).Updates(map[string]interface{}{
+		"status":      a.Status,
+		"resolved_at": now,
+	}).Error
+}
+
+// MarkAlertNotified records that a notification was sent for an alert
+func MarkAlertNotified(id uint) error {
+	return DB.Model(&Alert{}).Where("id = ?", id).Update("notified_at", time.Now()).Error
+}
+
+// GetFiringAlerts retrieves all alerts that are currently firing
+func GetFiringAlerts() ([]Alert, error) {
+	var alerts []Alert
+	if err := DB.Where("status = ?", AlertFiring).Order("fired_at DESC").Find(&alerts).Error; err != nil {
+		return nil, err
+	}
+	return alerts, nil
+}
+
+// visibleAlerts limits a query to the alerts a user may see: those about the
+// host and its mounts, and those about the user's own servers
+func visibleAlerts(userID uint) *gorm.DB {
+	owned := DB.Model(&Server{}).Select("id").Where("user_id = ?", userID)
+	return DB.Where("server_id IN (?) OR (server_id = 0 AND subject NOT LIKE ?)", owned, "server:%")
+}
+
+// GetFiringAlertsByUserID retrieves the firing alerts visible to a user
+func GetFiringAlertsByUserID(userID uint) ([]Alert, error) {
+	var alerts []Alert
+	if err := visibleAlerts(userID).Where("status = ?", AlertFiring).Order("fired_at DESC").Find(&alerts).Error; err != nil {
+		return nil, err
+	}
+	return alerts, nil
+}
+
+// GetRecentAlertsByUserID retrieves the most recent alerts visible to a user,
+// newest first
+func GetRecentAlertsByUserID(userID uint, limit int) ([]Alert, error) {
+	var alerts []Alert
+	if err := visibleAlerts(userID).Order("fired_at DESC").Limit(limit).Find(&alerts).Error; err != nil {
+		return nil, err
+	}
+	return alerts, nil
+}
@@ -0,0 +1,81 @@
+package models
+
+import (
+	"time"
+)
+
+// Crash report kinds, by the file the report was parsed from
+const (
+	CrashKindReport   = "crash_report" // crash-reports/crash-*.txt written by the game
+	CrashKindJVMFatal = "jvm_fatal"    // hs_err_pid*.log written by the JVM
+	CrashKindExit     = "exit"         // no file; parsed from the console output
+)
+
+// maxCrashReportsPerServer is how many crash reports are kept per server
+const maxCrashReportsPerServer = 50
+
+// CrashReport is the analysis of an abnormal server exit
+type CrashReport struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	ServerID    uint      `gorm:"index;not null" json:"server_id"`
+	ExitCode    int       `json:"exit_code"`
+	Kind        string    `json:"kind"`
+	File        string    `json:"file"` // relative to the server folder
+	Description string    `json:"description"`
+	Exception   string    `json:"exception"`
+	Suspects    []string  `gorm:"serializer:json" json:"suspects"` // mods, plugins or packages in the stack trace
+	OOMCause    string    `json:"oom_cause"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Summary returns a one-line description of the crash
+func (c *CrashReport) Summary() string {
+	switch {
+	case c.OOMCause != "":
+		return c.OOMCause
+	case c.Description != "" && c.Exception != "":
+		return c.Description + ": " + c.Exception
+	case c.Description != "":
+		return c.Description
+	case c.Exception != "":
+		return c.Exception
+	}
+	return "Server exited unexpectedly"
+}
+
+// RecordCrashReport stores a crash report, keeping the most recent ones per server
+func RecordCrashReport(report *CrashReport) error {
+	if err := DB.Create(report).Error; err != nil {
+		return err
+	}
+
+	var cutoff CrashReport
+	err := DB.Where("server_id = ?", report.ServerID).
+		Order("id DESC").
+		Offset(maxCrashReportsPerServer).
+		Limit(1).
+		Find(&cutoff).Error
+	if err != nil || cutoff.ID == 0 {
+		return err
+	}
+
+	return DB.Where("server_id = ? AND id <= ?", report.ServerID, cutoff.ID).Delete(&CrashReport{}).Error
+}
+
+// GetCrashReports retrieves the most recent crash reports of a server
+func GetCrashReports(serverID uint, limit int) ([]CrashReport, error) {
+	var reports []CrashReport
+	if err := DB.Where("server_id = ?", serverID).Order("id DESC").Limit(limit).Find(&reports).Error; err != nil {
+		return nil, err
+	}
+	return reports, nil
+}
+
+// GetCrashReport retrieves a crash report of a server by ID
+func GetCrashReport(id, serverID uint) (*CrashReport, error) {
+	var report CrashReport
+	if err := DB.Where("id = ? AND server_id = ?", id, serverID).First(&report).Error; err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
@@ -32,7 +32,7 @@ func InitDatabase() {
 	log.Println("✅ Database connected successfully")
 
 	// Auto migrate models
-	err = DB.AutoMigrate(&User{}, &Server{})
+	err = DB.AutoMigrate(&User{}, &Server{}, &MetricSample{}, &Alert{}, &Webhook{}, &WebhookDelivery{}, &JarFile{}, &CrashReport{}, &ServerGroup{})
 	if err != nil {
 		log.Fatal("Failed to migrate database:", err)
 	}
@@ -0,0 +1,37 @@
+package models
+
+// Readiness conditions of a dependency
+const (
+	ReadyPort = "port" // a TCP port accepts connections
+	ReadyLog  = "log"  // a line of the server's console output matches a regex
+	ReadyPing = "ping" // a Server List Ping is answered
+)
+
+// ServerDependency is something a server waits for before it starts: another
+// server of the panel, or a service outside it such as a database
+type ServerDependency struct {
+	ServerID       uint   `json:"server_id"`       // server depended on, 0 for an outside service
+	Condition      string `json:"condition"`       // ReadyPort, ReadyLog or ReadyPing
+	Address        string `json:"address"`         // host:port to check, the game port of the server when empty
+	Pattern        string `json:"pattern"`         // regex for ReadyLog
+	TimeoutSeconds int    `json:"timeout_seconds"` // how long to wait, the default when 0
+}
+
+// UpdateDependencies stores what the server waits for before it starts
+func (s *Server) UpdateDependencies(dependencies []ServerDependency) error {
+	if len(dependencies) == 0 {
+		dependencies = nil
+	}
+	s.Dependencies = dependencies
+	return DB.Save(s).Error
+}
+
+// DependsOn reports whether the server waits for another server
+func (s *Server) DependsOn(serverID uint) bool {
+	for _, dependency := range s.Dependencies {
+		if dependency.ServerID == serverID {
+			return true
+		}
+	}
+	return false
+}
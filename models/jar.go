@@ -0,0 +1,95 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// JarFile is a server jar in the local jar library. Files are stored once
+// per content, keyed by their SHA-256.
+type JarFile struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	Name       string    `gorm:"not null" json:"name"` // original file name
+	Label      string    `json:"label"`                // e.g. "Paper 1.20.4"
+	SHA256     string    `gorm:"uniqueIndex;not null" json:"sha256"`
+	Size       int64     `json:"size"`
+	UploadedBy uint      `json:"uploaded_by"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// CreateJarFile records a jar added to the library
+func CreateJarFile(name, label, sha256 string, size int64, userID uint) (*JarFile, error) {
+	jar := &JarFile{
+		Name:       name,
+		Label:      label,
+		SHA256:     sha256,
+		Size:       size,
+		UploadedBy: userID,
+	}
+
+	if err := DB.Create(jar).Error; err != nil {
+		return nil, err
+	}
+
+	return jar, nil
+}
+
+// GetJarFileByID retrieves a library jar by ID
+func GetJarFileByID(id uint) (*JarFile, error) {
+	var jar JarFile
+	if err := DB.First(&jar, id).Error; err != nil {
+		return nil, err
+	}
+	return &jar, nil
+}
+
+// GetJarFileBySHA256 retrieves a library jar by its content hash
+func GetJarFileBySHA256(sum string) (*JarFile, error) {
+	var jar JarFile
+	if err := DB.Where("sha256 = ?", sum).First(&jar).Error; err != nil {
+		return nil, err
+	}
+	return &jar, nil
+}
+
+// GetAllJarFiles retrieves every library jar, newest first
+func GetAllJarFiles() ([]JarFile, error) {
+	var jars []JarFile
+	if err := DB.Order("created_at DESC").Find(&jars).Error; err != nil {
+		return nil, err
+	}
+	return jars, nil
+}
+
+// DisplayName returns the label, or the file name when there is none
+func (j *JarFile) DisplayName() string {
+	if j.Label != "" {
+		return j.Label
+	}
+	return j.Name
+}
+
+// ShortSHA returns the first 12 characters of the hash, for display
+func (j *JarFile) ShortSHA() string {
+	if len(j.SHA256) > 12 {
+		return j.SHA256[:12]
+	}
+	return j.SHA256
+}
+
+// FormatSize returns the size in MB, for display
+func (j *JarFile) FormatSize() string {
+	return fmt.Sprintf("%.1f MB", float64(j.Size)/(1024*1024))
+}
+
+// ServerCount returns how many servers were provisioned from the jar
+func (j *JarFile) ServerCount() (int64, error) {
+	var count int64
+	err := DB.Model(&Server{}).Where("jar_sha256 = ?", j.SHA256).Count(&count).Error
+	return count, err
+}
+
+// Delete deletes the library entry
+func (j *JarFile) Delete() error {
+	return DB.Delete(j).Error
+}
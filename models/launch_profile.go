@@ -0,0 +1,29 @@
+package models
+
+// Startup command modes
+const (
+	CommandRaw     = "raw"     // the startup command is edited by hand
+	CommandProfile = "profile" // the startup command is rendered from the launch profile
+)
+
+// GC presets of a launch profile
+const (
+	GCDefault    = "default"
+	GCAikarG1    = "aikar_g1"
+	GCZGC        = "zgc"
+	GCShenandoah = "shenandoah"
+)
+
+// LaunchProfile is the structured form of a Java server startup command
+type LaunchProfile struct {
+	JavaPath   string   `json:"java_path"` // "java" when empty
+	MinHeapMB  int      `json:"min_heap_mb"`
+	MaxHeapMB  int      `json:"max_heap_mb"`
+	GCPreset   string   `json:"gc_preset"`
+	JVMArgs    []string `json:"jvm_args"`
+	JarPath    string   `json:"jar_path"` // relative to the working directory
+	NoGUI      bool     `json:"nogui"`
+	Port       int      `json:"port"` // passed as --port when set
+	ServerArgs []string `json:"server_args"`
+	WorkingDir string   `json:"working_dir"` // relative to the server folder
+}
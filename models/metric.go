@@ -0,0 +1,101 @@
+package models
+
+import "fmt"
+
+// MetricSample is one data point of a time series at a given resolution.
+// Raw samples have Count 1; rollups hold the aggregate of their bucket.
+type MetricSample struct {
+	ID         uint    `gorm:"primaryKey" json:"-"`
+	Series     string  `gorm:"not null;index:idx_metric_lookup,priority:1" json:"series"`
+	Resolution int     `gorm:"not null;index:idx_metric_lookup,priority:2" json:"resolution"` // seconds per bucket
+	Timestamp  int64   `gorm:"not null;index:idx_metric_lookup,priority:3" json:"timestamp"`  // bucket start, unix seconds
+	Value      float64 `json:"value"`                                                         // average
+	Min        float64 `json:"min"`
+	Max        float64 `json:"max"`
+	Count      int     `json:"count"`
+}
+
+// MetricPoint is a single point returned by a time-range query
+type MetricPoint struct {
+	Timestamp int64   `json:"t"`
+	Value     float64 `json:"avg"`
+	Min       float64 `json:"min"`
+	Max       float64 `json:"max"`
+}
+
+// RecordMetricSamples stores a batch of samples
+func RecordMetricSamples(samples []MetricSample) error {
+	if len(samples) == 0 {
+		return nil
+	}
+	return DB.Create(&samples).Error
+}
+
+// RollupMetrics aggregates samples of one resolution into buckets of a
+// coarser resolution for the time range [start, end). Existing rollups in
+// the range are replaced, so running it twice is harmless.
+func RollupMetrics(fromResolution, toResolution int, start, end int64) error {
+	var rows []MetricSample
+	err := DB.Model(&MetricSample{}).
+		Select("series, (timestamp / ?) * ? AS timestamp, SUM(value * count) / SUM(count) AS value, MIN(min) AS min, MAX(max) AS max, SUM(count) AS count",
+			toResolution, toResolution).
+		Where("resolution = ? AND timestamp >= ? AND timestamp < ?", fromResolution, start, end).
+		Group(fmt.Sprintf("series, (timestamp / %d) * %d", toResolution, toResolution)).
+		Scan(&rows).Error
+	if err != nil {
+		return err
+	}
+
+	if err := DB.Where("resolution = ? AND timestamp >= ? AND timestamp < ?", toResolution, start, end).
+		Delete(&MetricSample{}).Error; err != nil {
+		return err
+	}
+
+	for i := range rows {
+		rows[i].ID = 0
+		rows[i].Resolution = toResolution
+	}
+
+	return RecordMetricSamples(rows)
+}
+
+// PruneMetrics deletes samples of a resolution older than the given timestamp
+func PruneMetrics(resolution int, before int64) error {
+	return DB.Where("resolution = ? AND timestamp < ?", resolution, before).Delete(&MetricSample{}).Error
+}
+
+// LatestMetricTimestamp returns the newest bucket start stored for a resolution
+func LatestMetricTimestamp(resolution int) (int64, bool) {
+	var latest *int64
+	DB.Model(&MetricSample{}).Where("resolution = ?", resolution).Select("MAX(timestamp)").Scan(&latest)
+	if latest == nil {
+		return 0, false
+	}
+	return *latest, true
+}
+
+// QueryMetrics returns points for a series between from and to (unix seconds),
+// re-bucketed to the given step from samples of the given resolution
+func QueryMetrics(series string, resolution int, from, to, step int64) ([]MetricPoint, error) {
+	points := []MetricPoint{}
+	err := DB.Model(&MetricSample{}).
+		Select("(timestamp / ?) * ? AS timestamp, SUM(value * count) / SUM(count) AS value, MIN(min) AS min, MAX(max) AS max",
+			step, step).
+		Where("series = ? AND resolution = ? AND timestamp >= ? AND timestamp <= ?", series, resolution, from, to).
+		Group(fmt.Sprintf("(timestamp / %d) * %d", step, step)).
+		Order("timestamp").
+		Scan(&points).Error
+	return points, err
+}
+
+// ListMetricSeries returns the names of all stored series
+func ListMetricSeries() ([]string, error) {
+	series := []string{}
+	err := DB.Model(&MetricSample{}).Distinct("series").Order("series").Pluck("series", &series).Error
+	return series, err
+}
+
+// RenameMetricSeries moves the samples of a series to a new name
+func RenameMetricSeries(oldName, newName string) error {
+	return DB.Model(&MetricSample{}).Where("series = ?", oldName).Update("series", newName).Error
+}
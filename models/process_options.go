@@ -0,0 +1,32 @@
+package models
+
+// I/O scheduling classes of a server process
+const (
+	IOClassDefault    = ""
+	IOClassBestEffort = "best-effort"
+	IOClassIdle       = "idle"
+)
+
+// ProcessOptions control the user, permissions and priority a server runs with
+type ProcessOptions struct {
+	User    string `json:"user"`     // user name or uid, the controller's user when empty
+	Group   string `json:"group"`    // group name or gid, the user's primary group when empty
+	Umask   string `json:"umask"`    // octal such as 007, the controller's umask when empty
+	Nice    int    `json:"nice"`     // -20 (highest) to 19 (lowest)
+	IOClass string `json:"io_class"` // "", best-effort or idle
+	IOLevel int    `json:"io_level"` // 0 (highest) to 7 (lowest), for best-effort
+}
+
+// IsEmpty reports whether the server runs like the controller itself
+func (o *ProcessOptions) IsEmpty() bool {
+	return o == nil || *o == ProcessOptions{}
+}
+
+// UpdateProcessOptions stores the process options of the server
+func (s *Server) UpdateProcessOptions(options *ProcessOptions) error {
+	if options.IsEmpty() {
+		options = nil
+	}
+	s.ProcessOptions = options
+	return DB.Save(s).Error
+}
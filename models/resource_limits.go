@@ -0,0 +1,24 @@
+package models
+
+// ResourceLimits are the cgroup limits of a server. Zero means no limit.
+type ResourceLimits struct {
+	CPUPercent   int `json:"cpu_percent"`    // cpu.max, in percent of one CPU; 200 allows two full CPUs
+	MemoryMaxMB  int `json:"memory_max_mb"`  // memory.max, the process tree is OOM killed above it
+	MemoryHighMB int `json:"memory_high_mb"` // memory.high, the process tree is throttled above it
+	IOWeight     int `json:"io_weight"`      // io.weight, 1-10000 with 100 as the default
+	PidsMax      int `json:"pids_max"`       // pids.max
+}
+
+// IsEmpty reports whether no limit is set
+func (l *ResourceLimits) IsEmpty() bool {
+	return l == nil || *l == ResourceLimits{}
+}
+
+// UpdateResourceLimits stores the resource limits of the server
+func (s *Server) UpdateResourceLimits(limits *ResourceLimits) error {
+	if limits.IsEmpty() {
+		limits = nil
+	}
+	s.ResourceLimits = limits
+	return DB.Save(s).Error
+}
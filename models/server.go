@@ -3,6 +3,8 @@ package models
 import (
 	"fmt"
 	"time"
+
+	"gorm.io/gorm"
 )
 
 // Server represents a Minecraft server
@@ -11,8 +13,22 @@ type Server struct {
 	Name           string    `gorm:"unique;not null" json:"name"`
 	FolderPath     string    `gorm:"not null" json:"folder_path"`
 	StartupCommand string    `gorm:"not null" json:"startup_command"`
+	ExecMode       string    `gorm:"default:'direct'" json:"exec_mode"` // direct, shell
+	Env            map[string]string `gorm:"serializer:json" json:"env"`
+	CommandMode    string    `gorm:"default:'raw'" json:"command_mode"` // raw, profile
+	LaunchProfile  *LaunchProfile `gorm:"serializer:json" json:"launch_profile"`
+	ResourceLimits *ResourceLimits `gorm:"serializer:json" json:"resource_limits"`
+	ProcessOptions *ProcessOptions `gorm:"serializer:json" json:"process_options"`
+	StopSettings   *StopSettings   `gorm:"serializer:json" json:"stop_settings"`
+	Dependencies   []ServerDependency `gorm:"serializer:json" json:"dependencies"`
 	Status         string    `gorm:"default:'offline'" json:"status"` // online, offline
 	StartedAt      *time.Time `json:"started_at"`
+	Archived       bool      `gorm:"default:false" json:"archived"`
+	Missing        bool      `gorm:"default:false" json:"missing"` // folder no longer exists on disk
+	Software       string    `json:"software"`   // detected server software, see SoftwareNames
+	MCVersion      string    `json:"mc_version"` // detected Minecraft version, or the proxy version
+	SoftwareStamp  int64     `json:"-"`          // modification time of the files the software was detected from
+	JarSHA256      string    `gorm:"index" json:"jar_sha256"` // library jar the server was provisioned from
 	CreatedAt      time.Time `json:"created_at"`
 	UpdatedAt      time.Time `json:"updated_at"`
 	UserID         uint      `gorm:"not null" json:"user_id"`
@@ -44,6 +60,15 @@ func GetServerByName(name string, userID uint) (*Server, error) {
 	return &server, nil
 }
 
+// GetServerByID retrieves a server by ID
+func GetServerByID(id uint) (*Server, error) {
+	var server Server
+	if err := DB.First(&server, id).Error; err != nil {
+		return nil, err
+	}
+	return &server, nil
+}
+
 // GetServersByUserID retrieves all servers for a user
 func GetServersByUserID(userID uint) ([]Server, error) {
 	var servers []Server
@@ -53,12 +78,101 @@ func GetServersByUserID(userID uint) ([]Server, error) {
 	return servers, nil
 }
 
+// GetAllServers retrieves every server regardless of owner
+func GetAllServers() ([]Server, error) {
+	var servers []Server
+	if err := DB.Find(&servers).Error; err != nil {
+		return nil, err
+	}
+	return servers, nil
+}
+
 // UpdateStartupCommand updates the server's startup command
 func (s *Server) UpdateStartupCommand(command string) error {
 	s.StartupCommand = command
 	return DB.Save(s).Error
 }
 
+// UpdateStartup switches the server to a raw startup command and updates how
+// it is run and its environment. The launch profile is kept for later.
+func (s *Server) UpdateStartup(command, execMode string, env map[string]string) error {
+	s.CommandMode = CommandRaw
+	s.StartupCommand = command
+	s.ExecMode = execMode
+	s.Env = env
+	return DB.Save(s).Error
+}
+
+// UpdateLaunchProfile switches the server to a launch profile, storing the
+// command line it renders to
+func (s *Server) UpdateLaunchProfile(profile *LaunchProfile, command string, env map[string]string) error {
+	s.CommandMode = CommandProfile
+	s.LaunchProfile = profile
+	s.StartupCommand = command
+	s.ExecMode = "direct"
+	s.Env = env
+	return DB.Save(s).Error
+}
+
+// UsesLaunchProfile reports whether the startup command is rendered from the launch profile
+func (s *Server) UsesLaunchProfile() bool {
+	return s.CommandMode == CommandProfile && s.LaunchProfile != nil
+}
+
+// Rename updates the server's name and folder
+func (s *Server) Rename(name, folderPath string) error {
+	return DB.Model(s).Updates(map[string]interface{}{
+		"name":        name,
+		"folder_path": folderPath,
+	}).Error
+}
+
+// SetArchived archives or unarchives the server
+func (s *Server) SetArchived(archived bool) error {
+	s.Archived = archived
+	return DB.Model(s).Update("archived", archived).Error
+}
+
+// SetMissing records whether the server folder is missing from disk
+func (s *Server) SetMissing(missing bool) error {
+	s.Missing = missing
+	return DB.Model(s).Update("missing", missing).Error
+}
+
+// SetSoftware records the detected server software and version
+func (s *Server) SetSoftware(software, version string) error {
+	s.Software = software
+	s.MCVersion = version
+	return DB.Model(s).Updates(map[string]interface{}{
+		"software":   software,
+		"mc_version": version,
+	}).Error
+}
+
+// SetSoftwareStamp records the modification time software detection saw
+func (s *Server) SetSoftwareStamp(stamp int64) error {
+	s.SoftwareStamp = stamp
+	return DB.Model(s).Update("software_stamp", stamp).Error
+}
+
+// SetLibraryJar records the library jar the server was provisioned from
+func (s *Server) SetLibraryJar(sha256 string) error {
+	s.JarSHA256 = sha256
+	return DB.Model(s).Update("jar_sha256", sha256).Error
+}
+
+// SoftwareLabel returns the software and version for display, e.g. "Paper 1.20.4"
+func (s *Server) SoftwareLabel() string {
+	name, known := SoftwareNames[s.Software]
+	if !known {
+		return ""
+	}
+	if s.MCVersion != "" {
+		return name + " " + s.MCVersion
+	}
+	return name
+}
+
 // SetStatus updates the server's status
 func (s *Server) SetStatus(status string) error {
 	s.Status = status
@@ -114,4 +228,67 @@ func formatDurationM(m int) string {
 // DeleteServer deletes a server
 func (s *Server) Delete() error {
 	return DB.Delete(s).Error
+}
+
+// DeleteWithRecords deletes the server in one transaction with its crash
+// reports, the metric series named in series, the alerts about
+// alertSubject, its place in server groups and the dependencies of other
+// servers on it
+func (s *Server) DeleteWithRecords(series []string, alertSubject string) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("server_id = ?", s.ID).Delete(&CrashReport{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("series IN ?", series).Delete(&MetricSample{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("subject = ?", alertSubject).Delete(&Alert{}).Error; err != nil {
+			return err
+		}
+
+		var groups []ServerGroup
+		if err := tx.Where("user_id = ?", s.UserID).Find(&groups).Error; err != nil {
+			return err
+		}
+		for _, group := range groups {
+			members := make([]uint, 0, len(group.ServerIDs))
+			for _, id := range group.ServerIDs {
+				if id != s.ID {
+					members = append(members, id)
+				}
+			}
+			if len(members) == len(group.ServerIDs) {
+				continue
+			}
+			group.ServerIDs = members
+			if err := tx.Save(&group).Error; err != nil {
+				return err
+			}
+		}
+
+		var dependents []Server
+		if err := tx.Where("user_id = ? AND id <> ?", s.UserID, s.ID).Find(&dependents).Error; err != nil {
+			return err
+		}
+		for _, dependent := range dependents {
+			if !dependent.DependsOn(s.ID) {
+				continue
+			}
+			kept := []ServerDependency{}
+			for _, dependency := range dependent.Dependencies {
+				if dependency.ServerID != s.ID {
+					kept = append(kept, dependency)
+				}
+			}
+			if len(kept) == 0 {
+				kept = nil
+			}
+			dependent.Dependencies = kept
+			if err := tx.Save(&dependent).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Delete(s).Error
+	})
 }
\ No newline at end of file
@@ -0,0 +1,79 @@
+package models
+
+import "time"
+
+// ServerGroup is a user-defined set of servers that bulk actions run on.
+// Members start in their order and stop in reverse.
+type ServerGroup struct {
+	ID                uint      `gorm:"primaryKey" json:"id"`
+	Name              string    `gorm:"not null" json:"name"`
+	ServerIDs         []uint    `gorm:"serializer:json" json:"server_ids"`
+	Parallelism       int       `gorm:"default:1" json:"parallelism"` // servers acted on at once
+	StartDelaySeconds int       `json:"start_delay_seconds"`          // pause between starts
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+	UserID            uint      `gorm:"not null;index" json:"user_id"`
+}
+
+// CreateServerGroup creates a new server group
+func CreateServerGroup(name string, serverIDs []uint, parallelism, startDelay int, userID uint) (*ServerGroup, error) {
+	group := &ServerGroup{
+		Name:              name,
+		ServerIDs:         serverIDs,
+		Parallelism:       parallelism,
+		StartDelaySeconds: startDelay,
+		UserID:            userID,
+	}
+
+	if err := DB.Create(group).Error; err != nil {
+		return nil, err
+	}
+
+	return group, nil
+}
+
+// GetServerGroupByID retrieves a server group by ID for a user
+func GetServerGroupByID(id, userID uint) (*ServerGroup, error) {
+	var group ServerGroup
+	if err := DB.Where("id = ? AND user_id = ?", id, userID).First(&group).Error; err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+// GetServerGroupsByUserID retrieves all server groups of a user
+func GetServerGroupsByUserID(userID uint) ([]ServerGroup, error) {
+	var groups []ServerGroup
+	if err := DB.Where("user_id = ?", userID).Order("name").Find(&groups).Error; err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+// Update changes the name, members and ordering settings of the group
+func (g *ServerGroup) Update(name string, serverIDs []uint, parallelism, startDelay int) error {
+	g.Name = name
+	g.ServerIDs = serverIDs
+	g.Parallelism = parallelism
+	g.StartDelaySeconds = startDelay
+	return DB.Save(g).Error
+}
+
+// Servers returns the members of the group in start order. Members that
+// were deleted since are left out.
+func (g *ServerGroup) Servers() []Server {
+	servers := []Server{}
+	for _, id := range g.ServerIDs {
+		server, err := GetServerByID(id)
+		if err != nil || server.UserID != g.UserID {
+			continue
+		}
+		servers = append(servers, *server)
+	}
+	return servers
+}
+
+// Delete deletes the group; its servers are not touched
+func (g *ServerGroup) Delete() error {
+	return DB.Delete(g).Error
+}
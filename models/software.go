@@ -0,0 +1,33 @@
+package models
+
+// Server software types
+const (
+	SoftwareUnknown    = ""
+	SoftwareVanilla    = "vanilla"
+	SoftwarePaper      = "paper"
+	SoftwarePurpur     = "purpur"
+	SoftwareSpigot     = "spigot"
+	SoftwareFabric     = "fabric"
+	SoftwareForge      = "forge"
+	SoftwareNeoForge   = "neoforge"
+	SoftwareVelocity   = "velocity"
+	SoftwareBungeeCord = "bungeecord"
+)
+
+// SoftwareNames are the display names of the server software types
+var SoftwareNames = map[string]string{
+	SoftwareVanilla:    "Vanilla",
+	SoftwarePaper:      "Paper",
+	SoftwarePurpur:     "Purpur",
+	SoftwareSpigot:     "Spigot",
+	SoftwareFabric:     "Fabric",
+	SoftwareForge:      "Forge",
+	SoftwareNeoForge:   "NeoForge",
+	SoftwareVelocity:   "Velocity",
+	SoftwareBungeeCord: "BungeeCord",
+}
+
+// IsProxySoftware reports whether the software is a proxy rather than a game server
+func IsProxySoftware(software string) bool {
+	return software == SoftwareVelocity || software == SoftwareBungeeCord
+}
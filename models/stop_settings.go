@@ -0,0 +1,36 @@
+package models
+
+// StopSettings control how a server is stopped and restarted
+type StopSettings struct {
+	Command          string `json:"command"`           // console command that stops the server, see DefaultStopCommand
+	TimeoutSeconds   int    `json:"timeout_seconds"`   // wait after the command before SIGTERM, the global timeout when 0
+	CountdownSeconds int    `json:"countdown_seconds"` // players are warned this long before the stop, 0 for no warning
+}
+
+// DefaultStopCommand returns the console command that stops the software
+func DefaultStopCommand(software string) string {
+	switch software {
+	case SoftwareBungeeCord:
+		return "end"
+	case SoftwareVelocity:
+		return "shutdown"
+	}
+	return "stop"
+}
+
+// StopCommand returns the configured stop command or the software's default
+func (s *Server) StopCommand() string {
+	if s.StopSettings != nil && s.StopSettings.Command != "" {
+		return s.StopSettings.Command
+	}
+	return DefaultStopCommand(s.Software)
+}
+
+// UpdateStopSettings stores the stop settings of the server
+func (s *Server) UpdateStopSettings(settings *StopSettings) error {
+	if *settings == (StopSettings{}) {
+		settings = nil
+	}
+	s.StopSettings = settings
+	return DB.Save(s).Error
+}
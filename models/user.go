@@ -1,6 +1,8 @@
 package models
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"time"
 
@@ -15,6 +17,10 @@ type User struct {
 	Password  string    `gorm:"not null" json:"-"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+
+	// MetricsToken authenticates Prometheus scrapes of the user's servers;
+	// empty disables the /metrics endpoint for the user
+	MetricsToken string `gorm:"index" json:"-"`
 }
 
 // CreateUser creates a new user with hashed password
@@ -109,4 +115,32 @@ func (u *User) UpdatePassword(currentPassword, newPassword string) error {
 
 	u.Password = string(hashedPassword)
 	return DB.Save(u).Error
-}
\ No newline at end of file
+}
+
+// GetUserByMetricsToken retrieves the user a /metrics token belongs to
+func GetUserByMetricsToken(token string) (*User, error) {
+	if token == "" {
+		return nil, errors.New("no metrics token given")
+	}
+	var user User
+	if err := DB.Where("metrics_token = ?", token).First(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// RegenerateMetricsToken creates and saves a new /metrics token for the user
+func (u *User) RegenerateMetricsToken() error {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	u.MetricsToken = hex.EncodeToString(b)
+	return DB.Model(u).Update("metrics_token", u.MetricsToken).Error
+}
+
+// DisableMetricsToken clears the user's /metrics token
+func (u *User) DisableMetricsToken() error {
+	u.MetricsToken = ""
+	return DB.Model(u).Update("metrics_token", "").Error
+}
@@ -0,0 +1,153 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// Webhook payload formats
+const (
+	WebhookFormatJSON    = "json"
+	WebhookFormatDiscord = "discord"
+)
+
+// maxDeliveriesPerWebhook bounds the delivery log kept per webhook
+const maxDeliveriesPerWebhook = 200
+
+// Webhook is a user-configured endpoint that receives panel events
+type Webhook struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Name      string    `gorm:"not null" json:"name"`
+	URL       string    `gorm:"not null" json:"url"`
+	Secret    string    `json:"-"`
+	Events    string    `json:"events"` // comma separated event types
+	Format    string    `gorm:"default:'json'" json:"format"`
+	Enabled   bool      `gorm:"default:true" json:"enabled"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	UserID    uint      `gorm:"not null;index" json:"user_id"`
+}
+
+// WebhookDelivery records one delivery attempt of an event to a webhook
+type WebhookDelivery struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	WebhookID  uint      `gorm:"index;not null" json:"webhook_id"`
+	DeliveryID string    `gorm:"index" json:"delivery_id"` // shared by the attempts of one event
+	Event      string    `json:"event"`
+	Attempt    int       `json:"attempt"`
+	StatusCode int       `json:"status_code"`
+	Success    bool      `json:"success"`
+	Error      string    `json:"error"`
+	DurationMs int64     `json:"duration_ms"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// CreateWebhook creates a new webhook
+func CreateWebhook(name, url, secret string, events []string, format string, userID uint) (*Webhook, error) {
+	webhook := &Webhook{
+		Name:    name,
+		URL:     url,
+		Secret:  secret,
+		Events:  strings.Join(events, ","),
+		Format:  format,
+		Enabled: true,
+		UserID:  userID,
+	}
+
+	if err := DB.Create(webhook).Error; err != nil {
+		return nil, err
+	}
+
+	return webhook, nil
+}
+
+// GetWebhookByID retrieves a webhook by ID for a user
+func GetWebhookByID(id, userID uint) (*Webhook, error) {
+	var webhook Webhook
+	if err := DB.Where("id = ? AND user_id = ?", id, userID).First(&webhook).Error; err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+// GetWebhooksByUserID retrieves all webhooks for a user
+func GetWebhooksByUserID(userID uint) ([]Webhook, error) {
+	var webhooks []Webhook
+	if err := DB.Where("user_id = ?", userID).Order("id").Find(&webhooks).Error; err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+// GetEnabledWebhooks retrieves the enabled webhooks of a user
+func GetEnabledWebhooks(userID uint) ([]Webhook, error) {
+	var webhooks []Webhook
+	if err := DB.Where("user_id = ? AND enabled = ?", userID, true).Find(&webhooks).Error; err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+// EventList returns the event types the webhook is subscribed to
+func (h *Webhook) EventList() []string {
+	events := []string{}
+	for _, event := range strings.Split(h.Events, ",") {
+		if event = strings.TrimSpace(event); event != "" {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+// Subscribes reports whether the webhook receives an event type
+func (h *Webhook) Subscribes(event string) bool {
+	for _, subscribed := range h.EventList() {
+		if subscribed == event {
+			return true
+		}
+	}
+	return false
+}
+
+// SetEnabled enables or disables the webhook
+func (h *Webhook) SetEnabled(enabled bool) error {
+	h.Enabled = enabled
+	return DB.Model(h).Update("enabled", enabled).Error
+}
+
+// Delete deletes the webhook and its delivery log
+func (h *Webhook) Delete() error {
+	if err := DB.Where("webhook_id = ?", h.ID).Delete(&WebhookDelivery{}).Error; err != nil {
+		return err
+	}
+	return DB.Delete(h).Error
+}
+
+// RecordWebhookDelivery stores a delivery attempt and trims the webhook's
+// delivery log to its newest entries
+func RecordWebhookDelivery(delivery *WebhookDelivery) error {
+	if err := DB.Create(delivery).Error; err != nil {
+		return err
+	}
+
+	var cutoff WebhookDelivery
+	err := DB.Where("webhook_id = ?", delivery.WebhookID).
+		Order("id DESC").
+		Offset(maxDeliveriesPerWebhook).
+		Limit(1).
+		Find(&cutoff).Error
+	if err != nil || cutoff.ID == 0 {
+		return err
+	}
+
+	return DB.Where("webhook_id = ? AND id <= ?", delivery.WebhookID, cutoff.ID).Delete(&WebhookDelivery{}).Error
+}
+
+// GetWebhookDeliveries retrieves the most recent delivery attempts of a webhook
+func GetWebhookDeliveries(webhookID uint, limit int) ([]WebhookDelivery, error) {
+	var deliveries []WebhookDelivery
+	if err := DB.Where("webhook_id = ?", webhookID).Order("id DESC").Limit(limit).Find(&deliveries).Error; err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
@@ -0,0 +1,105 @@
+package services
+
+import (
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Plugin and mod metadata and server configuration files are YAML
+// (plugin.yml, config.yml, spigot.yml) and TOML (mods.toml, velocity.toml).
+// Files that do not parse are treated like files without the keys.
+
+// parseYAML parses a YAML document into maps, lists and strings. Scalars
+// stay strings as written, so a version such as 1.10 is not read as a number.
+func parseYAML(data string) map[string]interface{} {
+	var node yaml.Node
+	if err := yaml.Unmarshal([]byte(data), &node); err != nil {
+		return map[string]interface{}{}
+	}
+	doc, ok := yamlNodeValue(&node).(map[string]interface{})
+	if !ok {
+		return map[string]interface{}{}
+	}
+	return doc
+}
+
+// yamlNodeValue converts a YAML node into maps, lists and strings, following
+// aliases and merge keys
+func yamlNodeValue(node *yaml.Node) interface{} {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) > 0 {
+			return yamlNodeValue(node.Content[0])
+		}
+	case yaml.AliasNode:
+		return yamlNodeValue(node.Alias)
+	case yaml.MappingNode:
+		m := make(map[string]interface{})
+		merged := []interface{}{}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if key.Tag == "!!merge" {
+				if list, ok := yamlNodeValue(value).([]interface{}); ok {
+					merged = append(merged, list...)
+				} else {
+					merged = append(merged, yamlNodeValue(value))
+				}
+				continue
+			}
+			m[key.Value] = yamlNodeValue(value)
+		}
+		// Keys of the mapping itself win over merged ones, earlier merges over later
+		for _, source := range merged {
+			if values, ok := source.(map[string]interface{}); ok {
+				for key, value := range values {
+					if _, exists := m[key]; !exists {
+						m[key] = value
+					}
+				}
+			}
+		}
+		return m
+	case yaml.SequenceNode:
+		list := make([]interface{}, 0, len(node.Content))
+		for _, item := range node.Content {
+			list = append(list, yamlNodeValue(item))
+		}
+		return list
+	case yaml.ScalarNode:
+		if node.Tag == "!!null" {
+			return nil
+		}
+		return node.Value
+	}
+	return nil
+}
+
+// yamlStrings returns a string or list of strings as a list
+func yamlStrings(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		if v == "" {
+			return nil
+		}
+		return []string{v}
+	case []interface{}:
+		list := []string{}
+		for _, item := range v {
+			if s, ok := item.(string); ok && s != "" {
+				list = append(list, s)
+			}
+		}
+		return list
+	}
+	return nil
+}
+
+// parseTOML parses a TOML document. Tables are maps, arrays of tables lists
+// of maps, and values keep their TOML types.
+func parseTOML(data string) map[string]interface{} {
+	doc := make(map[string]interface{})
+	if _, err := toml.Decode(data, &doc); err != nil {
+		return map[string]interface{}{}
+	}
+	return doc
+}
@@ -0,0 +1,536 @@
+package services
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"minecraft-server-controller/models"
+)
+
+// Addon kinds, named after the folder they are installed in
+const (
+	AddonPlugin = "plugins"
+	AddonMod    = "mods"
+)
+
+// disabledSuffix is appended to the file name of disabled addons
+const disabledSuffix = ".disabled"
+
+// providedDependencies are mod ids supplied by the loader or the game itself
+var providedDependencies = map[string]bool{
+	"minecraft":     true,
+	"java":          true,
+	"fabricloader":  true,
+	"fabric-loader": true,
+	"forge":         true,
+	"neoforge":      true,
+	"quilt_loader":  true,
+}
+
+// Addon is a plugin or mod jar of a server
+type Addon struct {
+	Kind        string   `json:"kind"`
+	File        string   `json:"file"`
+	Size        int64    `json:"size"`
+	Enabled     bool     `json:"enabled"`
+	Loader      string   `json:"loader"` // bukkit, paper, fabric, forge, neoforge, or empty when unknown
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Version     string   `json:"version"`
+	Authors     []string `json:"authors"`
+	Depends     []string `json:"depends"`      // required dependencies
+	SoftDepends []string `json:"soft_depends"` // optional dependencies
+	Breaks      []string `json:"breaks"`       // addons this one is incompatible with
+	Missing     []string `json:"missing"`      // required dependencies that are not installed
+	Conflicts   []string `json:"conflicts"`    // duplicates and incompatible addons
+	Error       string   `json:"error,omitempty"`
+}
+
+// DisplayName returns the addon name, or its file name when it has no metadata
+func (a *Addon) DisplayName() string {
+	if a.Name != "" {
+		return a.Name
+	}
+	if a.ID != "" {
+		return a.ID
+	}
+	return a.File
+}
+
+// FormatSize returns the size in MB, for display
+func (a *Addon) FormatSize() string {
+	return fmt.Sprintf("%.1f MB", float64(a.Size)/(1024*1024))
+}
+
+// DefaultAddonKind returns the addon kind that fits the server's software
+func DefaultAddonKind(server *models.Server) string {
+	switch server.Software {
+	case models.SoftwareFabric, models.SoftwareForge, models.SoftwareNeoForge:
+		return AddonMod
+	}
+	return AddonPlugin
+}
+
+// addonDir returns the folder holding addons of a kind
+func addonDir(server *models.Server, kind string) (string, error) {
+	if kind != AddonPlugin && kind != AddonMod {
+		return "", errors.New("unknown addon kind")
+	}
+	return filepath.Join(ServerWorkingDir(server), kind), nil
+}
+
+// addonPath returns the path of an addon file, refusing names that are not
+// plain jar file names inside the addon folder
+func addonPath(server *models.Server, kind, file string) (string, error) {
+	dir, err := addonDir(server, kind)
+	if err != nil {
+		return "", err
+	}
+	if file != filepath.Base(file) || strings.HasPrefix(file, ".") {
+		return "", errors.New("invalid file name")
+	}
+	if !strings.HasSuffix(file, ".jar") && !strings.HasSuffix(file, ".jar"+disabledSuffix) {
+		return "", errors.New("not a jar file")
+	}
+	return filepath.Join(dir, file), nil
+}
+
+// ListAddons reads the plugins and mods of a server with their metadata and
+// flags missing dependencies, duplicates and incompatibilities
+func ListAddons(server *models.Server) ([]Addon, error) {
+	addons := []Addon{}
+	for _, kind := range []string{AddonPlugin, AddonMod} {
+		dir, _ := addonDir(server, kind)
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+
+		for _, entry := range entries {
+			name := entry.Name()
+			enabled := strings.HasSuffix(name, ".jar")
+			if entry.IsDir() || (!enabled && !strings.HasSuffix(name, ".jar"+disabledSuffix)) {
+				continue
+			}
+
+			addon := Addon{Kind: kind, File: name, Enabled: enabled}
+			if info, err := entry.Info(); err == nil {
+				addon.Size = info.Size()
+			}
+			if err := readAddonMetadata(filepath.Join(dir, name), &addon); err != nil {
+				addon.Error = err.Error()
+			}
+			addons = append(addons, addon)
+		}
+	}
+
+	checkAddons(addons)
+
+	sort.SliceStable(addons, func(i, j int) bool {
+		if addons[i].Kind != addons[j].Kind {
+			return addons[i].Kind > addons[j].Kind
+		}
+		return strings.ToLower(addons[i].DisplayName()) < strings.ToLower(addons[j].DisplayName())
+	})
+	return addons, nil
+}
+
+// checkAddons fills in missing dependencies and conflicts between the
+// enabled addons of each kind
+func checkAddons(addons []Addon) {
+	installed := make(map[string][]int)
+	for i, addon := range addons {
+		if !addon.Enabled {
+			continue
+		}
+		key := addon.Kind + "/" + strings.ToLower(addon.ID)
+		installed[key] = append(installed[key], i)
+	}
+
+	// Breaks mark both sides, so every addon is reset before any is checked
+	for i := range addons {
+		addons[i].Missing = []string{}
+		addons[i].Conflicts = []string{}
+	}
+
+	for i := range addons {
+		addon := &addons[i]
+		if !addon.Enabled || addon.ID == "" {
+			continue
+		}
+
+		for _, dep := range addon.Depends {
+			if providedDependencies[strings.ToLower(dep)] {
+				continue
+			}
+			if len(installed[addon.Kind+"/"+strings.ToLower(dep)]) == 0 {
+				addon.Missing = append(addon.Missing, dep)
+			}
+		}
+
+		for _, j := range installed[addon.Kind+"/"+strings.ToLower(addon.ID)] {
+			if j == i {
+				continue
+			}
+			other := addons[j]
+			if other.Version != addon.Version {
+				addon.Conflicts = append(addon.Conflicts, fmt.Sprintf("another version (%s) is installed as %s", other.Version, other.File))
+			} else {
+				addon.Conflicts = append(addon.Conflicts, "duplicate of "+other.File)
+			}
+		}
+
+		for _, broken := range addon.Breaks {
+			for _, j := range installed[addon.Kind+"/"+strings.ToLower(broken)] {
+				addConflict(addon, "incompatible with "+addons[j].DisplayName())
+				addConflict(&addons[j], "incompatible with "+addon.DisplayName())
+			}
+		}
+	}
+}
+
+// addConflict records a conflict once, since two addons may both declare
+// that they break each other
+func addConflict(addon *Addon, conflict string) {
+	for _, existing := range addon.Conflicts {
+		if existing == conflict {
+			return
+		}
+	}
+	addon.Conflicts = append(addon.Conflicts, conflict)
+}
+
+// readAddonMetadata reads the plugin or mod descriptor of a jar
+func readAddonMetadata(path string, addon *Addon) error {
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return errors.New("not a valid jar")
+	}
+	defer archive.Close()
+
+	for _, descriptor := range []string{"paper-plugin.yml", "plugin.yml", "bungee.yml", "fabric.mod.json", "META-INF/neoforge.mods.toml", "META-INF/mods.toml"} {
+		entry := findZipEntry(&archive.Reader, descriptor)
+		if entry == nil {
+			continue
+		}
+		data, err := readZipEntry(entry)
+		if err != nil {
+			return err
+		}
+
+		switch descriptor {
+		case "paper-plugin.yml":
+			parsePaperPlugin(data, addon)
+		case "plugin.yml", "bungee.yml":
+			parseBukkitPlugin(data, addon)
+		case "fabric.mod.json":
+			if err := parseFabricMod(data, addon); err != nil {
+				return err
+			}
+		default:
+			parseForgeMods(data, descriptor == "META-INF/neoforge.mods.toml", addon)
+			if strings.Contains(addon.Version, "${") {
+				// ${file.jarVersion} comes from the manifest
+				addon.Version = readJarManifest(&archive.Reader)["Implementation-Version"]
+			}
+		}
+		return nil
+	}
+
+	// Velocity plugins describe themselves in velocity-plugin.json
+	if entry := findZipEntry(&archive.Reader, "velocity-plugin.json"); entry != nil {
+		var meta struct {
+			ID           string   `json:"id"`
+			Name         string   `json:"name"`
+			Version      string   `json:"version"`
+			Authors      []string `json:"authors"`
+			Dependencies []struct {
+				ID       string `json:"id"`
+				Optional bool   `json:"optional"`
+			} `json:"dependencies"`
+		}
+		if err := readZipJSON(entry, &meta); err != nil {
+			return errors.New("invalid velocity-plugin.json")
+		}
+		addon.Loader = models.SoftwareVelocity
+		addon.ID, addon.Name, addon.Version, addon.Authors = meta.ID, meta.Name, meta.Version, meta.Authors
+		for _, dep := range meta.Dependencies {
+			if dep.Optional {
+				addon.SoftDepends = append(addon.SoftDepends, dep.ID)
+			} else {
+				addon.Depends = append(addon.Depends, dep.ID)
+			}
+		}
+		return nil
+	}
+
+	return errors.New("no plugin or mod descriptor found")
+}
+
+// readZipEntry reads an archive entry of at most 1 MB
+func readZipEntry(entry *zip.File) (string, error) {
+	file, err := entry.Open()
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, 1<<20))
+	return string(data), err
+}
+
+// parseBukkitPlugin reads a Bukkit or BungeeCord plugin.yml
+func parseBukkitPlugin(data string, addon *Addon) {
+	doc := parseYAML(data)
+	addon.Loader = "bukkit"
+	addon.Name = yamlString(doc["name"])
+	addon.ID = addon.Name
+	addon.Version = yamlString(doc["version"])
+	addon.Authors = append(yamlStrings(doc["author"]), yamlStrings(doc["authors"])...)
+	addon.Depends = yamlStrings(doc["depend"])
+	addon.SoftDepends = yamlStrings(doc["softdepend"])
+}
+
+// parsePaperPlugin reads a paper-plugin.yml, whose dependencies are maps of
+// plugin name to settings
+func parsePaperPlugin(data string, addon *Addon) {
+	doc := parseYAML(data)
+	addon.Loader = "paper"
+	addon.Name = yamlString(doc["name"])
+	addon.ID = addon.Name
+	addon.Version = yamlString(doc["version"])
+	addon.Authors = append(yamlStrings(doc["author"]), yamlStrings(doc["authors"])...)
+
+	dependencies, _ := doc["dependencies"].(map[string]interface{})
+	for _, section := range []string{"server", "bootstrap"} {
+		plugins, _ := dependencies[section].(map[string]interface{})
+		names := make([]string, 0, len(plugins))
+		for name := range plugins {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			settings, _ := plugins[name].(map[string]interface{})
+			// Dependencies are required unless stated otherwise
+			if yamlString(settings["required"]) == "false" {
+				addon.SoftDepends = appendUnique(addon.SoftDepends, name)
+			} else {
+				addon.Depends = appendUnique(addon.Depends, name)
+			}
+		}
+	}
+}
+
+// parseFabricMod reads a fabric.mod.json
+func parseFabricMod(data string, addon *Addon) error {
+	var meta struct {
+		ID         string                 `json:"id"`
+		Name       string                 `json:"name"`
+		Version    string                 `json:"version"`
+		Authors    []interface{}          `json:"authors"`
+		Depends    map[string]interface{} `json:"depends"`
+		Recommends map[string]interface{} `json:"recommends"`
+		Suggests   map[string]interface{} `json:"suggests"`
+		Breaks     map[string]interface{} `json:"breaks"`
+	}
+	if err := decodeLenientJSON(data, &meta); err != nil {
+		return errors.New("invalid fabric.mod.json")
+	}
+
+	addon.Loader = models.SoftwareFabric
+	addon.ID, addon.Name, addon.Version = meta.ID, meta.Name, meta.Version
+	for _, author := range meta.Authors {
+		// Authors are names or {"name": ...} objects
+		switch a := author.(type) {
+		case string:
+			addon.Authors = append(addon.Authors, a)
+		case map[string]interface{}:
+			if name, ok := a["name"].(string); ok {
+				addon.Authors = append(addon.Authors, name)
+			}
+		}
+	}
+	addon.Depends = sortedKeys(meta.Depends)
+	addon.SoftDepends = append(sortedKeys(meta.Recommends), sortedKeys(meta.Suggests)...)
+	addon.Breaks = sortedKeys(meta.Breaks)
+	return nil
+}
+
+// decodeLenientJSON decodes JSON that may contain raw line breaks inside
+// strings, which Fabric's own parser accepts
+func decodeLenientJSON(data string, v interface{}) error {
+	if err := json.Unmarshal([]byte(data), v); err == nil {
+		return nil
+	}
+	cleaned := strings.NewReplacer("\r", "", "\n", " ", "\t", " ").Replace(data)
+	return json.Unmarshal([]byte(cleaned), v)
+}
+
+// parseForgeMods reads the first mod of a Forge or NeoForge mods.toml
+func parseForgeMods(data string, neoforge bool, addon *Addon) {
+	doc := parseTOML(data)
+	addon.Loader = models.SoftwareForge
+	if neoforge {
+		addon.Loader = models.SoftwareNeoForge
+	}
+
+	mods, _ := doc["mods"].([]map[string]interface{})
+	if len(mods) == 0 {
+		return
+	}
+	mod := mods[0]
+	addon.ID = tomlString(mod["modId"])
+	addon.Name = tomlString(mod["displayName"])
+	addon.Version = tomlString(mod["version"])
+	if authors := tomlString(mod["authors"]); authors != "" {
+		for _, author := range strings.Split(authors, ",") {
+			if author = strings.TrimSpace(author); author != "" {
+				addon.Authors = append(addon.Authors, author)
+			}
+		}
+	}
+
+	dependencies, _ := doc["dependencies"].(map[string]interface{})
+	deps, _ := dependencies[addon.ID].([]map[string]interface{})
+	for _, dep := range deps {
+		id := tomlString(dep["modId"])
+		if id == "" {
+			continue
+		}
+		// Forge uses mandatory = true, NeoForge type = "required"
+		switch {
+		case dep["mandatory"] == true || tomlString(dep["type"]) == "required":
+			addon.Depends = append(addon.Depends, id)
+		case tomlString(dep["type"]) == "incompatible":
+			addon.Breaks = append(addon.Breaks, id)
+		default:
+			addon.SoftDepends = append(addon.SoftDepends, id)
+		}
+	}
+}
+
+// yamlString returns a YAML scalar as a string
+func yamlString(value interface{}) string {
+	s, _ := value.(string)
+	return s
+}
+
+// tomlString returns a TOML string value
+func tomlString(value interface{}) string {
+	s, _ := value.(string)
+	return s
+}
+
+// sortedKeys returns the keys of a map in order
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// appendUnique appends value unless the list already has it
+func appendUnique(list []string, value string) []string {
+	if containsArg(list, value) {
+		return list
+	}
+	return append(list, value)
+}
+
+// SetAddonEnabled enables or disables an addon by renaming it to or from
+// .jar.disabled. The change takes effect when the server restarts.
+func SetAddonEnabled(server *models.Server, kind, file string, enabled bool) (string, error) {
+	path, err := addonPath(server, kind, file)
+	if err != nil {
+		return "", err
+	}
+
+	target := strings.TrimSuffix(file, disabledSuffix)
+	if !enabled {
+		target += disabledSuffix
+	}
+	if target == file {
+		return file, nil
+	}
+
+	targetPath := filepath.Join(filepath.Dir(path), target)
+	if _, err := os.Lstat(targetPath); err == nil {
+		return "", fmt.Errorf("%s already exists", target)
+	}
+	if err := os.Rename(path, targetPath); err != nil {
+		return "", err
+	}
+	return target, nil
+}
+
+// RemoveAddon deletes an addon jar
+func RemoveAddon(server *models.Server, kind, file string) error {
+	path, err := addonPath(server, kind, file)
+	if err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// UploadAddon stores an uploaded jar in the plugins or mods folder. Existing
+// files are not overwritten.
+func UploadAddon(server *models.Server, kind, name string, src io.Reader) (*Addon, error) {
+	name = filepath.Base(name)
+	path, err := addonPath(server, kind, name)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(name, ".jar") {
+		return nil, errors.New("only .jar files can be uploaded")
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create %s folder: %w", kind, err)
+	}
+
+	tmp, err := os.CreateTemp(dir, ".upload-*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	size, err := io.Copy(tmp, io.LimitReader(src, maxJarSize+1))
+	closeErr := tmp.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to store upload: %w", err)
+	}
+	if closeErr != nil {
+		return nil, fmt.Errorf("failed to store upload: %w", closeErr)
+	}
+	if size > maxJarSize {
+		return nil, fmt.Errorf("jar is larger than %d MB", maxJarSize>>20)
+	}
+
+	addon := &Addon{Kind: kind, File: name, Size: size, Enabled: true}
+	if err := readAddonMetadata(tmp.Name(), addon); err != nil {
+		return nil, err
+	}
+
+	// Link instead of rename so an existing file is never replaced
+	if err := os.Link(tmp.Name(), path); err != nil {
+		if os.IsExist(err) {
+			return nil, fmt.Errorf("%s already exists", name)
+		}
+		return nil, fmt.Errorf("failed to store jar: %w", err)
+	}
+	return addon, nil
+}
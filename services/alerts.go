@@ -0,0 +1,317 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"minecraft-server-controller/config"
+	"minecraft-server-controller/models"
+)
+
+// alertEvalInterval is how often the alert rules are evaluated
+const alertEvalInterval = 15 * time.Second
+
+// Alert rule types
+const (
+	RuleHostMemory    = "host_memory"
+	RuleHostCPU       = "host_cpu"
+	RuleDisk          = "disk"
+	RuleServerOffline = "server_offline"
+	RuleRestartLoop   = "restart_loop"
+)
+
+// alertCondition is a subject that currently breaches a rule
+type alertCondition struct {
+	serverID uint
+	value    float64
+	message  string
+}
+
+// alertState tracks one rule/subject pair between evaluations
+type alertState struct {
+	ruleID       string
+	subject      string
+	pendingSince time.Time     // when the condition started holding
+	alert        *models.Alert // the firing alert, if any
+	notified     bool          // a notification went out for the firing alert
+	lastNotified time.Time
+}
+
+var (
+	alertStates = make(map[string]*alertState)
+	alertMux    sync.Mutex
+)
+
+// StartAlertEngine starts the goroutine that evaluates the alert rules
+func StartAlertEngine() {
+	// Pick up alerts that were still firing when the controller stopped
+	if firing, err := models.GetFiringAlerts(); err == nil {
+		for i := range firing {
+			alert := &firing[i]
+			state := getAlertState(alert.RuleID, alert.Subject)
+			state.alert = alert
+			state.pendingSince = alert.FiredAt
+			if alert.NotifiedAt != nil {
+				state.notified = true
+				state.lastNotified = *alert.NotifiedAt
+			}
+		}
+	}
+
+	go func() {
+		ticker := time.NewTicker(alertEvalInterval)
+		defer ticker.Stop()
+
+		for now := range ticker.C {
+			evaluateAlerts(now)
+		}
+	}()
+
+	log.Println("✅ Alert engine started")
+}
+
+// alertKey identifies a rule/subject pair
+func alertKey(ruleID, subject string) string {
+	return ruleID + "|" + subject
+}
+
+// serverAlertSubject is the alert subject of a server
+func serverAlertSubject(serverName string) string {
+	return "server:" + serverName
+}
+
+// forgetServerAlerts drops the alert states of a deleted server, so its
+// alerts are neither resolved nor announced again
+func forgetServerAlerts(subject string) {
+	alertMux.Lock()
+	defer alertMux.Unlock()
+
+	for key, state := range alertStates {
+		if state.subject == subject {
+			delete(alertStates, key)
+		}
+	}
+}
+
+// getAlertState returns the state of a rule/subject pair, creating it if needed.
+// The caller must hold alertMux or be the only goroutine touching the states.
+func getAlertState(ruleID, subject string) *alertState {
+	key := alertKey(ruleID, subject)
+	state, exists := alertStates[key]
+	if !exists {
+		state = &alertState{ruleID: ruleID, subject: subject}
+		alertStates[key] = state
+	}
+	return state
+}
+
+// evaluateAlerts checks every enabled rule and moves alerts between firing and resolved
+func evaluateAlerts(now time.Time) {
+	alertMux.Lock()
+	defer alertMux.Unlock()
+
+	enabled := make(map[string]bool)
+	for _, rule := range config.GetAlertRules() {
+		if !rule.Enabled {
+			continue
+		}
+		enabled[rule.ID] = true
+
+		breaching, ok := checkAlertRule(rule)
+		if !ok {
+			// No data to judge the rule on: leave its alerts as they are
+			continue
+		}
+
+		for subject, condition := range breaching {
+			state := getAlertState(rule.ID, subject)
+			if state.pendingSince.IsZero() {
+				state.pendingSince = now
+			}
+			if state.alert == nil && now.Sub(state.pendingSince) >= time.Duration(rule.ForSeconds)*time.Second {
+				fireAlert(rule, state, condition, now)
+			}
+		}
+
+		for _, state := range alertStates {
+			if state.ruleID != rule.ID {
+				continue
+			}
+			if _, still := breaching[state.subject]; !still {
+				state.pendingSince = time.Time{}
+				resolveAlert(state)
+			}
+		}
+	}
+
+	// Alerts of rules that were disabled or removed are resolved
+	for _, state := range alertStates {
+		if !enabled[state.ruleID] {
+			state.pendingSince = time.Time{}
+			resolveAlert(state)
+		}
+	}
+}
+
+// checkAlertRule returns the subjects that currently breach a rule. ok is
+// false when there is no data to evaluate the rule against yet.
+func checkAlertRule(rule config.AlertRule) (breaching map[string]alertCondition, ok bool) {
+	breaching = make(map[string]alertCondition)
+
+	switch rule.Type {
+	case RuleHostMemory, RuleHostCPU:
+		snapshot := GetSystemSnapshot()
+		if snapshot.UpdatedAt.IsZero() {
+			return nil, false
+		}
+		value, what := snapshot.Memory.UsedPercent, "Memory"
+		if rule.Type == RuleHostCPU {
+			value, what = snapshot.CPUPercent, "CPU"
+		}
+		if value > rule.Threshold {
+			breaching["host"] = alertCondition{
+				value:   value,
+				message: fmt.Sprintf("Host %s usage is %.1f%% (threshold %.0f%%)", what, value, rule.Threshold),
+			}
+		}
+
+	case RuleDisk:
+		mounts, err := GetMountStats()
+		if err != nil {
+			return nil, false
+		}
+		for _, mount := range mounts {
+			if mount.UsedPercent > rule.Threshold {
+				breaching["mount:"+mount.MountPoint] = alertCondition{
+					value:   mount.UsedPercent,
+					message: fmt.Sprintf("Disk %s is %.1f%% full (threshold %.0f%%)", mount.MountPoint, mount.UsedPercent, rule.Threshold),
+				}
+			}
+		}
+
+	case RuleServerOffline, RuleRestartLoop:
+		servers, err := models.GetAllServers()
+		if err != nil {
+			return nil, false
+		}
+		for i := range servers {
+			server := &servers[i]
+			subject := serverAlertSubject(server.Name)
+
+			if rule.Type == RuleServerOffline {
+				if IsServerRunning(server) {
+					continue
+				}
+				if exit, exists := GetLastExit(server); exists && exit.Crashed {
+					breaching[subject] = alertCondition{
+						serverID: server.ID,
+						value:    float64(exit.Code),
+						message:  fmt.Sprintf("Server '%s' went offline unexpectedly (exit code %d)", server.Name, exit.Code),
+					}
+				}
+				continue
+			}
+
+			window := time.Duration(rule.WindowSeconds) * time.Second
+			crashes := RecentCrashCount(server, window)
+			if rule.Threshold > 0 && float64(crashes) >= rule.Threshold {
+				breaching[subject] = alertCondition{
+					serverID: server.ID,
+					value:    float64(crashes),
+					message:  fmt.Sprintf("Server '%s' crashed %d times in the last %s", server.Name, crashes, window),
+				}
+			}
+		}
+
+	default:
+		return nil, false
+	}
+
+	return breaching, true
+}
+
+// fireAlert records a new firing alert and notifies unless the rule's cooldown
+// for this subject has not passed yet
+func fireAlert(rule config.AlertRule, state *alertState, condition alertCondition, now time.Time) {
+	alert, err := models.CreateAlert(rule.ID, state.subject, condition.serverID, rule.Severity, condition.message, condition.value)
+	if err != nil {
+		log.Printf("⚠️  Failed to record alert '%s': %v", rule.ID, err)
+		return
+	}
+
+	state.alert = alert
+	state.notified = false
+	log.Printf("🚨 Alert %s [%s]: %s", rule.ID, strings.ToUpper(rule.Severity), condition.message)
+
+	cooldown := time.Duration(rule.CooldownSeconds) * time.Second
+	if !state.lastNotified.IsZero() && now.Sub(state.lastNotified) < cooldown {
+		return
+	}
+
+	state.notified = true
+	state.lastNotified = now
+	dispatchAlert(*alert)
+}
+
+// resolveAlert resolves the firing alert of a state, if any. A resolution is
+// only announced when the firing alert itself was announced.
+func resolveAlert(state *alertState) {
+	if state.alert == nil {
+		return
+	}
+
+	alert := state.alert
+	if err := alert.Resolve(); err != nil {
+		log.Printf("⚠️  Failed to resolve alert '%s': %v", alert.RuleID, err)
+		return
+	}
+
+	state.alert = nil
+	log.Printf("✅ Alert %s resolved for %s", alert.RuleID, alert.Subject)
+
+	if state.notified {
+		state.notified = false
+		dispatchAlert(*alert)
+	}
+}
+
+// dispatchAlert sends an alert through the notification channels in the background
+func dispatchAlert(alert models.Alert) {
+	go func() {
+		sent, failures := NotifyAll(&alert)
+		for channel, err := range failures {
+			log.Printf("⚠️  Failed to send alert '%s' via %s: %v", alert.RuleID, channel, err)
+		}
+		if sent > 0 && alert.Status == models.AlertFiring {
+			if err := models.MarkAlertNotified(alert.ID); err != nil {
+				log.Printf("⚠️  Failed to mark alert '%s' as notified: %v", alert.RuleID, err)
+			}
+		}
+	}()
+}
+
+// SendTestAlert sends a synthetic alert through every configured channel
+// and returns the result of each channel, keyed by channel name
+func SendTestAlert() map[string]string {
+	alert := &models.Alert{
+		RuleID:   "test",
+		Subject:  "host",
+		Severity: "info",
+		Status:   models.AlertFiring,
+		Message:  "Test notification from Minecraft Server Controller",
+		FiredAt:  time.Now(),
+	}
+
+	results := make(map[string]string)
+	for _, notifier := range ConfiguredNotifiers() {
+		if err := notifier.Notify(alert); err != nil {
+			results[notifier.Name()] = err.Error()
+			continue
+		}
+		results[notifier.Name()] = "ok"
+	}
+	return results
+}
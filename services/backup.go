@@ -0,0 +1,126 @@
+package services
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"minecraft-server-controller/models"
+)
+
+// backupFolderName is the folder inside a server folder backups are written to
+const backupFolderName = "backups"
+
+// backupSaveWait is how long a running server gets to flush its worlds
+// before they are copied
+const backupSaveWait = 5 * time.Second
+
+// backupSkip lists top-level entries that are not backed up
+var backupSkip = map[string]bool{
+	backupFolderName: true,
+	"session.lock":   true,
+}
+
+// BackupServer writes a zip of the server folder to its backups folder and
+// returns the path of the zip. A running server has autosaving paused while
+// its files are copied.
+func BackupServer(server *models.Server) (string, error) {
+	if IsServerRunning(server) && !models.IsProxySoftware(server.Software) {
+		if err := SendCommand(server, "save-off"); err == nil {
+			defer SendCommand(server, "save-on")
+			SendCommand(server, "save-all flush")
+			time.Sleep(backupSaveWait)
+		}
+	}
+
+	dir := filepath.Join(server.FolderPath, backupFolderName)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	started := time.Now()
+	path := filepath.Join(dir, fmt.Sprintf("%s-%s.zip", server.Name, started.Format("2006-01-02-150405")))
+	if err := writeBackup(server.FolderPath, path); err != nil {
+		os.Remove(path)
+		return "", err
+	}
+
+	size := int64(0)
+	if info, err := os.Stat(path); err == nil {
+		size = info.Size()
+	}
+	log.Printf("✅ Backed up server '%s' to %s", server.Name, path)
+
+	PublishEvent(EventBackupFinished, server, map[string]interface{}{
+		"file":        filepath.Base(path),
+		"size_bytes":  size,
+		"duration_ms": time.Since(started).Milliseconds(),
+	})
+	return path, nil
+}
+
+// writeBackup zips the files of a server folder
+func writeBackup(folder, path string) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	archive := zip.NewWriter(file)
+	err = filepath.Walk(folder, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(folder, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		if backupSkip[rel] {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		// Symlinks, sockets and devices are skipped
+		if !info.IsDir() && !info.Mode().IsRegular() {
+			return nil
+		}
+
+		header, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(rel)
+		if info.IsDir() {
+			header.Name += "/"
+			_, err = archive.CreateHeader(header)
+			return err
+		}
+		header.Method = zip.Deflate
+
+		w, err := archive.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		in, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		_, err = io.Copy(w, in)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if err := archive.Close(); err != nil {
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,173 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"minecraft-server-controller/models"
+)
+
+// Bulk actions besides the lifecycle operations
+const (
+	BulkCommand = "command"
+	BulkBackup  = "backup"
+)
+
+// BulkActions lists the actions that can run on several servers at once
+var BulkActions = []string{OperationStart, OperationStop, OperationRestart, BulkCommand, BulkBackup}
+
+// BulkRequest is an action to run on several servers
+type BulkRequest struct {
+	Action      string
+	Command     string        // console command for BulkCommand
+	Parallelism int           // servers acted on at once, at least 1
+	StartDelay  time.Duration // pause between starting servers
+	User        string        // who asked, for the command event
+}
+
+// IsBulkAction reports whether name is a known bulk action
+func IsBulkAction(name string) bool {
+	for _, action := range BulkActions {
+		if action == name {
+			return true
+		}
+	}
+	return false
+}
+
+// StartBulkAction runs an action on servers in the background and returns
+// the operations tracking it, one per server in the order of servers. Servers
+// start after the servers they depend on and stop before them, otherwise in
+// the given order and reversed for stops, at most Parallelism at a time. A
+// dependency cycle fails right away.
+func StartBulkAction(req BulkRequest, servers []models.Server) ([]Operation, error) {
+	lifecycle := req.Action == OperationStart || req.Action == OperationStop || req.Action == OperationRestart
+
+	order := make([]int, len(servers))
+	for i := range servers {
+		order[i] = i
+	}
+	if lifecycle {
+		sorted, err := DependencyOrder(servers)
+		if err != nil {
+			return nil, err
+		}
+		index := make(map[uint]int, len(servers))
+		for i, server := range servers {
+			index[server.ID] = i
+		}
+		for n, server := range sorted {
+			order[n] = index[server.ID]
+		}
+	}
+	if req.Action == OperationStop {
+		for i, j := 0, len(order)-1; i < j; i, j = i+1, j-1 {
+			order[i], order[j] = order[j], order[i]
+		}
+	}
+
+	ops := make([]*Operation, len(servers))
+	list := make([]Operation, len(servers))
+	for i := range servers {
+		ops[i] = newOperation(req.Action, &servers[i])
+		list[i] = *ops[i]
+	}
+
+	go runBulkAction(req, servers, order, ops)
+	return list, nil
+}
+
+// runBulkAction runs a bulk action on servers in the given order, finishing
+// the operation of each server
+func runBulkAction(req BulkRequest, servers []models.Server, order []int, ops []*Operation) {
+	lifecycle := req.Action == OperationStart || req.Action == OperationStop || req.Action == OperationRestart
+
+	// A server waits for the servers before it in the graph to finish: those
+	// it depends on when starting, those depending on it when stopping
+	done := make(map[uint]chan struct{}, len(servers))
+	for _, server := range servers {
+		done[server.ID] = make(chan struct{})
+	}
+	prerequisites := func(server *models.Server) []chan struct{} {
+		waits := []chan struct{}{}
+		for _, other := range servers {
+			if !lifecycle || other.ID == server.ID {
+				continue
+			}
+			if (req.Action == OperationStop && other.DependsOn(server.ID)) ||
+				(req.Action != OperationStop && server.DependsOn(other.ID)) {
+				waits = append(waits, done[other.ID])
+			}
+		}
+		return waits
+	}
+
+	slots := make(chan struct{}, max(req.Parallelism, 1))
+	for n, i := range order {
+		// Spacing starts lets a server come up before the ones after it
+		if n > 0 && req.StartDelay > 0 && (req.Action == OperationStart || req.Action == OperationRestart) {
+			time.Sleep(req.StartDelay)
+		}
+
+		slots <- struct{}{}
+		go func(server *models.Server, op *Operation) {
+			defer func() { <-slots }()
+			defer close(done[server.ID])
+
+			for _, wait := range prerequisites(server) {
+				<-wait
+			}
+
+			message, err := runBulkItem(req, server, op.progress)
+			finishOperation(op, message, err)
+		}(&servers[i], ops[i])
+	}
+}
+
+// runBulkItem runs a bulk action on one server, returning a message to show
+// instead of the default one
+func runBulkItem(req BulkRequest, server *models.Server, progress func(step string)) (string, error) {
+	switch req.Action {
+	case BulkCommand:
+		if err := SendCommand(server, req.Command); err != nil {
+			return "", err
+		}
+		data := map[string]interface{}{"command": req.Command}
+		if req.User != "" {
+			data["user"] = req.User
+		}
+		PublishEvent(EventCommandExecuted, server, data)
+		return "Command sent", nil
+
+	case BulkBackup:
+		progress("Backing up")
+		path, err := BackupServer(server)
+		if err != nil {
+			return "", err
+		}
+		return "Saved " + path, nil
+	}
+
+	lock, err := acquireLifecycleLock(server.ID, req.Action)
+	if err != nil {
+		return "", err
+	}
+	defer lock.Unlock()
+
+	switch req.Action {
+	case OperationStart:
+		if IsServerRunning(server) {
+			return "Already running", nil
+		}
+		return "Started", startServer(server, progress)
+	case OperationStop:
+		err := stopServer(server, stopReasonStop, progress)
+		if errors.Is(err, errServerNotRunning) {
+			return "Not running", nil
+		}
+		return "Stopped", err
+	case OperationRestart:
+		return "Restarted", restartServer(server, progress)
+	}
+	return "", errors.New("unknown action: " + req.Action)
+}
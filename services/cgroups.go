@@ -0,0 +1,298 @@
+package services
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"minecraft-server-controller/models"
+)
+
+// cgroupRoot is where the cgroup v2 hierarchy is mounted
+const cgroupRoot = "/sys/fs/cgroup"
+
+// cgroupSliceName is the controller-owned cgroup holding the server cgroups
+const cgroupSliceName = "minecraft-controller.slice"
+
+// cpuPeriod is the cpu.max period in microseconds
+const cpuPeriod = 100000
+
+// cgroupControllers are the controllers resource limits use
+var cgroupControllers = []string{"cpu", "memory", "io", "pids"}
+
+var (
+	cgroupOnce     sync.Once
+	cgroupSliceDir string // empty when cgroups cannot be used
+	cgroupErr      error
+)
+
+// CgroupSlice returns the controller-owned cgroup, creating it on first use,
+// or an error explaining why resource limits cannot be applied
+func CgroupSlice() (string, error) {
+	cgroupOnce.Do(func() {
+		cgroupSliceDir, cgroupErr = createCgroupSlice()
+		if cgroupErr != nil {
+			log.Printf("⚠️  Resource limits are unavailable: %v", cgroupErr)
+		}
+	})
+	return cgroupSliceDir, cgroupErr
+}
+
+// createCgroupSlice creates the slice below the root of the hierarchy, or
+// below the parent of the controller's own cgroup when the root is not
+// writable, and enables the controllers for it and its children
+func createCgroupSlice() (string, error) {
+	if _, err := os.Stat(filepath.Join(cgroupRoot, "cgroup.controllers")); err != nil {
+		return "", errors.New("cgroup v2 is not mounted at " + cgroupRoot)
+	}
+
+	parents := []string{cgroupRoot}
+	if own := ownCgroup(); own != "" && own != "/" {
+		parents = append(parents, filepath.Join(cgroupRoot, filepath.Dir(own)))
+	}
+
+	var lastErr error
+	for _, parent := range parents {
+		slice := filepath.Join(parent, cgroupSliceName)
+		if err := os.Mkdir(slice, 0755); err != nil && !os.IsExist(err) {
+			lastErr = err
+			continue
+		}
+		enableCgroupControllers(parent)
+		enableCgroupControllers(slice)
+		return slice, nil
+	}
+	return "", fmt.Errorf("cgroups are not writable: %w", lastErr)
+}
+
+// ownCgroup returns the cgroup v2 path of the controller process
+func ownCgroup() string {
+	data, err := os.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if path, found := strings.CutPrefix(line, "0::"); found {
+			return strings.TrimSpace(path)
+		}
+	}
+	return ""
+}
+
+// enableCgroupControllers enables the controllers the limits need for the
+// children of a cgroup, one at a time so a missing one does not stop the others
+func enableCgroupControllers(dir string) {
+	data, err := os.ReadFile(filepath.Join(dir, "cgroup.controllers"))
+	if err != nil {
+		return
+	}
+	available := strings.Fields(string(data))
+	for _, controller := range cgroupControllers {
+		if containsArg(available, controller) {
+			os.WriteFile(filepath.Join(dir, "cgroup.subtree_control"), []byte("+"+controller), 0644)
+		}
+	}
+}
+
+// serverCgroupDir returns the cgroup of a server below the slice
+func serverCgroupDir(slice string, server *models.Server) string {
+	return filepath.Join(slice, fmt.Sprintf("server-%d", server.ID))
+}
+
+// prepareServerCgroup creates the cgroup of a server with resource limits and
+// makes the command start inside it. It returns the cgroup and the open
+// directory the command needs until it started, or nothing when the server
+// has no limits or cgroups are unavailable.
+func prepareServerCgroup(server *models.Server, cmd *exec.Cmd) (string, *os.File) {
+	if server.ResourceLimits.IsEmpty() {
+		return "", nil
+	}
+
+	slice, err := CgroupSlice()
+	if err != nil {
+		log.Printf("⚠️  Server '%s' starts without its resource limits: %v", server.Name, err)
+		return "", nil
+	}
+
+	dir := serverCgroupDir(slice, server)
+	if err := os.Mkdir(dir, 0755); err != nil && !os.IsExist(err) {
+		log.Printf("⚠️  Server '%s' starts without its resource limits: %v", server.Name, err)
+		return "", nil
+	}
+	if err := writeCgroupLimits(dir, server.ResourceLimits); err != nil {
+		log.Printf("⚠️  Some resource limits of server '%s' were not applied: %v", server.Name, err)
+	}
+
+	file, err := os.Open(dir)
+	if err != nil {
+		log.Printf("⚠️  Server '%s' starts without its resource limits: %v", server.Name, err)
+		return "", nil
+	}
+
+	// The child is cloned straight into the cgroup, so no process of the
+	// tree ever runs outside it
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.UseCgroupFD = true
+	cmd.SysProcAttr.CgroupFD = int(file.Fd())
+	return dir, file
+}
+
+// writeCgroupLimits writes resource limits to a cgroup; unset limits are
+// reset to the kernel defaults
+func writeCgroupLimits(dir string, limits *models.ResourceLimits) error {
+	if limits == nil {
+		limits = &models.ResourceLimits{}
+	}
+
+	cpuMax := "max"
+	if limits.CPUPercent > 0 {
+		cpuMax = strconv.Itoa(limits.CPUPercent * cpuPeriod / 100)
+	}
+	ioWeight := 100
+	if limits.IOWeight > 0 {
+		ioWeight = limits.IOWeight
+	}
+
+	values := []struct{ file, value string }{
+		{"cpu.max", cpuMax + " " + strconv.Itoa(cpuPeriod)},
+		{"memory.max", cgroupBytes(limits.MemoryMaxMB)},
+		{"memory.high", cgroupBytes(limits.MemoryHighMB)},
+		{"io.weight", "default " + strconv.Itoa(ioWeight)},
+		{"pids.max", cgroupMax(limits.PidsMax)},
+	}
+
+	failed := []string{}
+	for _, v := range values {
+		if err := os.WriteFile(filepath.Join(dir, v.file), []byte(v.value), 0644); err != nil {
+			// A file is missing when its controller is not enabled; only
+			// setting a limit makes that a problem
+			if os.IsNotExist(err) && (v.value == "max" || strings.HasPrefix(v.value, "max ") || v.value == "default 100") {
+				continue
+			}
+			failed = append(failed, v.file)
+		}
+	}
+	if len(failed) > 0 {
+		return errors.New("could not write " + strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// cgroupBytes formats a size in MB for a memory limit file
+func cgroupBytes(mb int) string {
+	if mb <= 0 {
+		return "max"
+	}
+	return strconv.FormatInt(int64(mb)*1024*1024, 10)
+}
+
+// cgroupMax formats a count for a limit file
+func cgroupMax(n int) string {
+	if n <= 0 {
+		return "max"
+	}
+	return strconv.Itoa(n)
+}
+
+// removeServerCgroup removes the cgroup of a stopped server. The kernel
+// refuses while processes of the tree are still alive.
+func removeServerCgroup(server *models.Server, dir string) {
+	if err := os.Remove(dir); err != nil && !os.IsNotExist(err) {
+		log.Printf("⚠️  Failed to remove cgroup of server '%s': %v", server.Name, err)
+	}
+}
+
+// ApplyResourceLimits writes changed limits to the cgroup of a running
+// server. It reports false when the server is not running in a cgroup, in
+// which case the limits apply from the next start.
+func ApplyResourceLimits(server *models.Server) (bool, error) {
+	serverMux.Lock()
+	sp, exists := runningServers[server.ID]
+	serverMux.Unlock()
+
+	if !exists || sp.cgroup == "" {
+		return false, nil
+	}
+	return true, writeCgroupLimits(sp.cgroup, server.ResourceLimits)
+}
+
+// applyCgroupStats replaces the process tree totals with the accounting of
+// the cgroup, which also covers processes that left the tree
+func applyCgroupStats(dir string, stats *ProcessTreeStats) {
+	if current, err := readCgroupValue(dir, "memory.current"); err == nil {
+		// Like the kernel's working set, reclaimable page cache is not counted
+		inactive := readCgroupKeyed(dir, "memory.stat")["inactive_file"]
+		if inactive < current {
+			current -= inactive
+		}
+		stats.MemoryKB = int64(current / 1024)
+	}
+	if usec, ok := readCgroupKeyed(dir, "cpu.stat")["usage_usec"]; ok {
+		stats.CPUTicks = uint64(float64(usec) / 1e6 * clockTicks)
+	}
+	// pids.current counts tasks, which are threads
+	if tasks, err := readCgroupValue(dir, "pids.current"); err == nil {
+		stats.Threads = int(tasks)
+	}
+
+	// io.stat has a line per device: "8:0 rbytes=1 wbytes=2 ..."
+	data, err := os.ReadFile(filepath.Join(dir, "io.stat"))
+	if err != nil {
+		return
+	}
+	var read, written uint64
+	for _, line := range strings.Split(string(data), "\n") {
+		for _, field := range strings.Fields(line) {
+			key, value, _ := strings.Cut(field, "=")
+			n, _ := strconv.ParseUint(value, 10, 64)
+			switch key {
+			case "rbytes":
+				read += n
+			case "wbytes":
+				written += n
+			}
+		}
+	}
+	stats.ReadBytes, stats.WriteBytes = read, written
+}
+
+// readCgroupValue reads a cgroup file holding a single number
+func readCgroupValue(dir, file string) (uint64, error) {
+	data, err := os.ReadFile(filepath.Join(dir, file))
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+}
+
+// readCgroupKeyed reads a cgroup file of "key value" lines
+func readCgroupKeyed(dir, file string) map[string]uint64 {
+	values := make(map[string]uint64)
+	f, err := os.Open(filepath.Join(dir, file))
+	if err != nil {
+		return values
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		if n, err := strconv.ParseUint(fields[1], 10, 64); err == nil {
+			values[fields[0]] = n
+		}
+	}
+	return values
+}
@@ -0,0 +1,273 @@
+package services
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"minecraft-server-controller/models"
+)
+
+// maxCrashFileSize is how much of a crash file is read for analysis
+const maxCrashFileSize = 4 << 20
+
+// maxSuspects limits the suspects listed for a crash
+const maxSuspects = 5
+
+// platformPackages are stack frame prefixes of the JDK, the game and the
+// loaders; frames outside them point at a mod or plugin
+var platformPackages = []string{
+	"java.", "javax.", "jdk.", "sun.", "com.sun.",
+	"net.minecraft.", "com.mojang.", "org.bukkit.", "org.spigotmc.", "io.papermc.", "com.destroystokyo.", "org.purpurmc.",
+	"net.fabricmc.", "net.minecraftforge.", "net.neoforged.", "cpw.mods.", "org.spongepowered.asm.",
+	"com.velocitypowered.", "net.md_5.",
+	"com.google.", "it.unimi.", "io.netty.", "org.apache.", "org.slf4j.", "joptsimple.", "oshi.", "org.joml.",
+}
+
+var (
+	// stackFramePattern matches "at pkg.Class.method(File.java:1)" lines, with
+	// the module or mod jar prefix Forge and Paper add
+	stackFramePattern = regexp.MustCompile(`^\s*at (?:[\w.-]+(?:@[\w.+-]*)?/)*([\w$.]+)\.[\w$<>]+\(`)
+	// suspectedModPattern matches the "Suspected Mod(s):" lines of Forge crash reports
+	suspectedModPattern = regexp.MustCompile(`^\s*Suspected Mods?:\s*(.+)$`)
+	// exceptionPattern matches the first line of a Java exception
+	exceptionPattern = regexp.MustCompile(`(?:^|\s)(?:Exception in thread "[^"]*" |Caused by: )?((?:[a-z_$][\w$]*\.)+[A-Z][\w$]*(?:Exception|Error|Throwable)\b.*)$`)
+	// nativeAllocationPattern matches the native allocation failure of an hs_err file
+	nativeAllocationPattern = regexp.MustCompile(`^# Native memory allocation \((\w+)\) failed to (?:allocate|map) (\d+) bytes`)
+)
+
+// AnalyzeCrash looks for a crash report or JVM fatal error log written since
+// the server started and builds a crash report from it, falling back to the
+// console output when the server left no file. killed is set when the
+// process was ended by SIGKILL.
+func AnalyzeCrash(server *models.Server, exitCode int, killed bool, since time.Time, output []string) *models.CrashReport {
+	dir := ServerWorkingDir(server)
+	report := &models.CrashReport{ServerID: server.ID, ExitCode: exitCode, Kind: models.CrashKindExit}
+
+	if path := newestFile(filepath.Join(dir, "crash-reports", "*.txt"), since); path != "" {
+		if text, err := readCrashFile(path); err == nil {
+			report.Kind = models.CrashKindReport
+			report.File, _ = filepath.Rel(server.FolderPath, path)
+			parseCrashReport(text, report)
+		}
+	}
+
+	if path := newestFile(filepath.Join(dir, "hs_err_pid*.log"), since); path != "" && report.Kind == models.CrashKindExit {
+		if text, err := readCrashFile(path); err == nil {
+			report.Kind = models.CrashKindJVMFatal
+			report.File, _ = filepath.Rel(server.FolderPath, path)
+			parseJVMFatalError(text, report)
+		}
+	}
+
+	if report.Kind == models.CrashKindExit {
+		parseConsoleOutput(output, report)
+	}
+
+	// The kernel's OOM killer ends the JVM with SIGKILL; a shell running it
+	// reports that as exit code 137
+	if report.OOMCause == "" && (killed || exitCode == 137) {
+		report.OOMCause = "Killed by SIGKILL, most likely by the kernel OOM killer"
+	}
+
+	return report
+}
+
+// newestFile returns the newest file matching pattern modified since the time
+func newestFile(pattern string, since time.Time) string {
+	matches, _ := filepath.Glob(pattern)
+	newest := ""
+	var newestTime time.Time
+	for _, path := range matches {
+		info, err := os.Stat(path)
+		if err != nil || info.IsDir() || info.ModTime().Before(since) {
+			continue
+		}
+		if newest == "" || info.ModTime().After(newestTime) {
+			newest, newestTime = path, info.ModTime()
+		}
+	}
+	return newest
+}
+
+// readCrashFile reads the start of a crash file
+func readCrashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxCrashFileSize))
+	return string(data), err
+}
+
+// parseCrashReport reads a game crash report: the description, the
+// exception below it and the suspected mods
+func parseCrashReport(text string, report *models.CrashReport) {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		if description, found := strings.CutPrefix(line, "Description: "); found && report.Description == "" {
+			report.Description = strings.TrimSpace(description)
+
+			// The exception follows the description after a blank line
+			for _, next := range lines[i+1:] {
+				if strings.TrimSpace(next) == "" {
+					continue
+				}
+				report.Exception = strings.TrimSpace(next)
+				break
+			}
+		}
+		if match := suspectedModPattern.FindStringSubmatch(line); match != nil && !strings.EqualFold(strings.TrimSpace(match[1]), "NONE") {
+			for _, mod := range strings.Split(match[1], ",") {
+				report.Suspects = appendUnique(report.Suspects, strings.TrimSpace(mod))
+			}
+		}
+	}
+
+	// Only the stack trace of the crash itself, before the detailed sections
+	trace := lines
+	for i, line := range lines {
+		if strings.HasPrefix(line, "A detailed walkthrough of the error") {
+			trace = lines[:i]
+			break
+		}
+	}
+	addFrameSuspects(trace, report)
+
+	if strings.Contains(report.Exception, "OutOfMemoryError") {
+		report.OOMCause = report.Exception
+	}
+}
+
+// parseJVMFatalError reads an hs_err_pid*.log: the kind of fatal error, the
+// problematic frame and a native out of memory cause
+func parseJVMFatalError(text string, report *models.CrashReport) {
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	header := []string{}
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "#") {
+			if len(header) > 0 {
+				break
+			}
+			continue
+		}
+		header = append(header, line)
+	}
+
+	for i, line := range header {
+		content := strings.TrimSpace(strings.TrimPrefix(line, "#"))
+		switch {
+		case strings.Contains(line, "There is insufficient memory for the Java Runtime Environment to continue"):
+			report.Description = "Insufficient memory for the Java Runtime Environment"
+		case nativeAllocationPattern.MatchString(line):
+			match := nativeAllocationPattern.FindStringSubmatch(line)
+			report.OOMCause = fmt.Sprintf("Native memory allocation (%s) of %s bytes failed", match[1], match[2])
+		case strings.Contains(line, "A fatal error has been detected by the Java Runtime Environment"):
+			report.Description = "Fatal error in the Java Runtime Environment"
+			// The signal line, e.g. "SIGSEGV (0xb) at pc=...", follows
+			for _, next := range header[i+1:] {
+				if signal := strings.TrimSpace(strings.TrimPrefix(next, "#")); signal != "" {
+					report.Exception = signal
+					break
+				}
+			}
+		case strings.HasPrefix(content, "Problematic frame:") && i+1 < len(header):
+			frame := strings.TrimSpace(strings.TrimPrefix(header[i+1], "#"))
+			report.Suspects = appendUnique(report.Suspects, frame)
+		}
+	}
+}
+
+// parseConsoleOutput looks for an exception in the last console lines
+func parseConsoleOutput(output []string, report *models.CrashReport) {
+	start := len(output) - 200
+	if start < 0 {
+		start = 0
+	}
+	tail := output[start:]
+
+	for i, line := range tail {
+		if match := exceptionPattern.FindStringSubmatch(strings.TrimSpace(line)); match != nil {
+			exception := match[1]
+			if strings.Contains(exception, "OutOfMemoryError") {
+				report.OOMCause = exception
+			}
+			if report.Exception == "" || strings.Contains(exception, "OutOfMemoryError") {
+				report.Exception = exception
+				report.Suspects = nil
+				addFrameSuspects(tail[i+1:], report)
+			}
+		}
+	}
+}
+
+// addFrameSuspects adds the packages of stack frames outside the platform
+// packages, in the order they appear
+func addFrameSuspects(lines []string, report *models.CrashReport) {
+	for _, line := range lines {
+		if len(report.Suspects) >= maxSuspects {
+			return
+		}
+		match := stackFramePattern.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		class := match[1]
+		if isPlatformClass(class) {
+			continue
+		}
+		report.Suspects = appendUnique(report.Suspects, framePackage(class))
+	}
+}
+
+// isPlatformClass reports whether a class belongs to the JDK, game or loader
+func isPlatformClass(class string) bool {
+	for _, prefix := range platformPackages {
+		if strings.HasPrefix(class, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// framePackage shortens a class name to its first three package segments,
+// which usually name the mod or plugin
+func framePackage(class string) string {
+	parts := strings.Split(class, ".")
+	if len(parts) > 4 {
+		parts = parts[:3]
+	} else if len(parts) > 1 {
+		parts = parts[:len(parts)-1]
+	}
+	return strings.Join(parts, ".")
+}
+
+// recordCrash analyzes an abnormal exit and stores the crash report
+func recordCrash(server *models.Server, exitCode int, killed bool, since time.Time, output []string) *models.CrashReport {
+	report := AnalyzeCrash(server, exitCode, killed, since, output)
+	if err := models.RecordCrashReport(report); err != nil {
+		log.Printf("⚠️  Failed to store crash report of server '%s': %v", server.Name, err)
+		return nil
+	}
+
+	log.Printf("💥 Server '%s' crashed: %s", server.Name, report.Summary())
+	return report
+}
+
+// CrashReportFile returns the full path of the file a crash report was parsed
+// from, or an empty string when it has none
+func CrashReportFile(server *models.Server, report *models.CrashReport) string {
+	if report.File == "" || !filepath.IsLocal(report.File) {
+		return ""
+	}
+	return filepath.Join(server.FolderPath, report.File)
+}
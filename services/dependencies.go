@@ -0,0 +1,337 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"minecraft-server-controller/models"
+)
+
+// DefaultReadyTimeout is how long a start waits for a dependency by default
+const DefaultReadyTimeout = 120 * time.Second
+
+// maxReadyTimeout bounds the timeout of a dependency
+const maxReadyTimeout = time.Hour
+
+// readyPollInterval is how often a readiness condition is checked
+const readyPollInterval = time.Second
+
+// readyCheckTimeout bounds a single connection or ping attempt
+const readyCheckTimeout = 2 * time.Second
+
+// DefaultReadyPattern matches the line servers and proxies log once they
+// accept players, such as "Done (4.2s)! For help, type "help""
+const DefaultReadyPattern = `Done \([0-9.,]+m?s\)`
+
+// errDependencyStopped is returned when a server depended on is not running
+var errDependencyStopped = errors.New("it is not running")
+
+// errStartCancelled is returned when a stop cancels a start that waits for
+// its dependencies
+var errStartCancelled = errors.New("start cancelled by a stop")
+
+var (
+	// dependencyWaits are closed to cancel the start of a server waiting for
+	// its dependencies
+	dependencyWaits   = make(map[uint]chan struct{})
+	dependencyWaitMux sync.Mutex
+)
+
+// ValidateDependencies checks the dependencies of a server before they are
+// stored, including that they do not make its servers depend on themselves
+func ValidateDependencies(server *models.Server, dependencies []models.ServerDependency) error {
+	for _, dependency := range dependencies {
+		if dependency.ServerID == server.ID {
+			return errors.New("a server cannot depend on itself")
+		}
+		if dependency.ServerID != 0 {
+			target, err := models.GetServerByID(dependency.ServerID)
+			if err != nil || target.UserID != server.UserID {
+				return errors.New("unknown server in dependencies")
+			}
+		}
+
+		switch dependency.Condition {
+		case models.ReadyPort, models.ReadyPing:
+			if dependency.Address == "" && dependency.ServerID == 0 {
+				return errors.New("a service outside the panel needs an address to check")
+			}
+		case models.ReadyLog:
+			if dependency.ServerID == 0 {
+				return errors.New("a log line can only be awaited from a server of the panel")
+			}
+			if _, err := regexp.Compile(dependency.Pattern); err != nil {
+				return fmt.Errorf("invalid log pattern: %w", err)
+			}
+		default:
+			return errors.New("unknown readiness condition: " + dependency.Condition)
+		}
+
+		if dependency.Address != "" {
+			if _, port, err := net.SplitHostPort(dependency.Address); err != nil {
+				return fmt.Errorf("address must be host:port: %s", dependency.Address)
+			} else if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+				return fmt.Errorf("invalid port in address: %s", dependency.Address)
+			}
+		}
+		if dependency.TimeoutSeconds < 0 || time.Duration(dependency.TimeoutSeconds)*time.Second > maxReadyTimeout {
+			return fmt.Errorf("timeout must be between 0 and %d seconds", int(maxReadyTimeout.Seconds()))
+		}
+	}
+
+	servers, err := models.GetServersByUserID(server.UserID)
+	if err != nil {
+		return err
+	}
+	for i := range servers {
+		if servers[i].ID == server.ID {
+			servers[i].Dependencies = dependencies
+		}
+	}
+	_, err = DependencyOrder(servers)
+	return err
+}
+
+// DependencyOrder sorts servers so each comes after the servers it depends
+// on; dependencies on servers outside the list are ignored. Servers keep
+// their given order where dependencies allow, and a cycle is an error.
+func DependencyOrder(servers []models.Server) ([]models.Server, error) {
+	inList := make(map[uint]bool, len(servers))
+	for _, server := range servers {
+		inList[server.ID] = true
+	}
+
+	placed := make(map[uint]bool, len(servers))
+	ordered := make([]models.Server, 0, len(servers))
+	for len(ordered) < len(servers) {
+		progressed := false
+		for _, server := range servers {
+			if placed[server.ID] {
+				continue
+			}
+			ready := true
+			for _, dependency := range server.Dependencies {
+				if inList[dependency.ServerID] && !placed[dependency.ServerID] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				placed[server.ID] = true
+				ordered = append(ordered, server)
+				progressed = true
+				break
+			}
+		}
+
+		if !progressed {
+			names := []string{}
+			for _, server := range servers {
+				if !placed[server.ID] {
+					names = append(names, server.Name)
+				}
+			}
+			return nil, errors.New("dependency cycle between " + strings.Join(names, ", "))
+		}
+	}
+	return ordered, nil
+}
+
+// waitForDependencies waits until every dependency of a server is ready,
+// failing when one times out, a server depended on is not running or a stop
+// cancels the start. Servers depended on that no longer exist are skipped.
+func waitForDependencies(server *models.Server, progress func(step string)) error {
+	if len(server.Dependencies) == 0 {
+		return nil
+	}
+
+	cancel := make(chan struct{})
+	dependencyWaitMux.Lock()
+	dependencyWaits[server.ID] = cancel
+	dependencyWaitMux.Unlock()
+	defer func() {
+		dependencyWaitMux.Lock()
+		if dependencyWaits[server.ID] == cancel {
+			delete(dependencyWaits, server.ID)
+		}
+		dependencyWaitMux.Unlock()
+	}()
+
+	for _, dependency := range server.Dependencies {
+		var target *models.Server
+		if dependency.ServerID != 0 {
+			t, err := models.GetServerByID(dependency.ServerID)
+			if err != nil {
+				log.Printf("⚠️  Server '%s' depends on server %d, which no longer exists; skipping it", server.Name, dependency.ServerID)
+				continue
+			}
+			target = t
+		}
+
+		description := "waiting for " + DescribeDependency(dependency, target)
+		progress(description)
+		if err := waitReady(dependency, target, cancel); err != nil {
+			return fmt.Errorf("%s: %w", description, err)
+		}
+	}
+	return nil
+}
+
+// cancelDependencyWait cancels the start of a server that waits for its
+// dependencies, and reports whether there was one
+func cancelDependencyWait(serverID uint) bool {
+	dependencyWaitMux.Lock()
+	defer dependencyWaitMux.Unlock()
+
+	cancel, waiting := dependencyWaits[serverID]
+	if waiting {
+		close(cancel)
+		delete(dependencyWaits, serverID)
+	}
+	return waiting
+}
+
+// waitReady polls a readiness condition until it holds, times out or cancel
+// is closed
+func waitReady(dependency models.ServerDependency, target *models.Server, cancel <-chan struct{}) error {
+	timeout := DefaultReadyTimeout
+	if dependency.TimeoutSeconds > 0 {
+		timeout = time.Duration(dependency.TimeoutSeconds) * time.Second
+	}
+
+	var pattern *regexp.Regexp
+	if dependency.Condition == models.ReadyLog {
+		var err error
+		if pattern, err = regexp.Compile(readyPattern(dependency)); err != nil {
+			return err
+		}
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		if target != nil && !IsServerRunning(target) {
+			return errDependencyStopped
+		}
+
+		switch dependency.Condition {
+		case models.ReadyPort:
+			if conn, err := net.DialTimeout("tcp", readyAddress(dependency, target), readyCheckTimeout); err == nil {
+				conn.Close()
+				return nil
+			}
+		case models.ReadyPing:
+			if _, err := PingServer(readyAddress(dependency, target), readyCheckTimeout); err == nil {
+				return nil
+			}
+		case models.ReadyLog:
+			if logMatches(target, pattern) {
+				return nil
+			}
+		default:
+			return errors.New("unknown readiness condition: " + dependency.Condition)
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("not ready after %s", timeout)
+		}
+		select {
+		case <-cancel:
+			return errStartCancelled
+		case <-time.After(readyPollInterval):
+		}
+	}
+}
+
+// readyAddress returns the address a dependency is checked at: its own, or
+// the game port of the server on this host
+func readyAddress(dependency models.ServerDependency, target *models.Server) string {
+	if dependency.Address != "" || target == nil {
+		return dependency.Address
+	}
+	port := DefaultServerPort
+	if ports := ServerPorts(target); len(ports) > 0 {
+		port = ports[0].Port
+	}
+	return net.JoinHostPort("127.0.0.1", strconv.Itoa(port))
+}
+
+// logMatches reports whether a line of a running server's console output
+// since its start matches pattern
+func logMatches(server *models.Server, pattern *regexp.Regexp) bool {
+	serverMux.Lock()
+	sp, exists := runningServers[server.ID]
+	serverMux.Unlock()
+
+	if !exists {
+		return false
+	}
+
+	sp.LogMux.Lock()
+	defer sp.LogMux.Unlock()
+	if sp.readyMatched[pattern.String()] {
+		return true
+	}
+	// Patterns added after the start are only found while still buffered
+	for _, line := range sp.Logs {
+		if pattern.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
+
+// dependentLogPatterns compiles the log patterns servers depending on server
+// wait for, so its output can be matched as it is read
+func dependentLogPatterns(server *models.Server) map[string]*regexp.Regexp {
+	patterns := make(map[string]*regexp.Regexp)
+	servers, err := models.GetServersByUserID(server.UserID)
+	if err != nil {
+		return patterns
+	}
+	for _, dependent := range servers {
+		for _, dependency := range dependent.Dependencies {
+			if dependency.ServerID != server.ID || dependency.Condition != models.ReadyLog {
+				continue
+			}
+			text := readyPattern(dependency)
+			if pattern, err := regexp.Compile(text); err == nil {
+				patterns[text] = pattern
+			}
+		}
+	}
+	return patterns
+}
+
+// readyPattern returns the log pattern of a dependency, or the default
+func readyPattern(dependency models.ServerDependency) string {
+	if dependency.Pattern != "" {
+		return dependency.Pattern
+	}
+	return DefaultReadyPattern
+}
+
+// DescribeDependency describes what a dependency waits for, such as
+// "'lobby' to log a ready line" or "127.0.0.1:3306 to accept connections"
+func DescribeDependency(dependency models.ServerDependency, target *models.Server) string {
+	name := dependency.Address
+	if target != nil {
+		name = "'" + target.Name + "'"
+	}
+
+	switch dependency.Condition {
+	case models.ReadyPort:
+		return name + " to accept connections"
+	case models.ReadyPing:
+		return name + " to answer a ping"
+	case models.ReadyLog:
+		return name + " to log a ready line"
+	}
+	return name
+}
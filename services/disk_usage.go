@@ -0,0 +1,199 @@
+package services
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"minecraft-server-controller/config"
+)
+
+// MountStats holds usage for one mounted filesystem
+type MountStats struct {
+	Device      string  `json:"device"`
+	MountPoint  string  `json:"mount_point"`
+	FSType      string  `json:"fs_type"`
+	Total       uint64  `json:"total"`
+	Used        uint64  `json:"used"`
+	Free        uint64  `json:"free"`
+	UsedPercent float64 `json:"used_percent"`
+}
+
+// mountEntry is one line of /proc/mounts
+type mountEntry struct {
+	device     string
+	mountPoint string
+	fsType     string
+}
+
+// poolFilesystems are filesystems whose device is not a /dev path but
+// which still hold real data
+var poolFilesystems = map[string]bool{
+	"zfs":            true,
+	"nfs":            true,
+	"nfs4":           true,
+	"cifs":           true,
+	"smb3":           true,
+	"ceph":           true,
+	"glusterfs":      true,
+	"fuse.mergerfs":  true,
+	"fuse.sshfs":     true,
+	"fuse.glusterfs": true,
+}
+
+// readMounts parses /proc/mounts
+func readMounts() ([]mountEntry, error) {
+	file, err := os.Open("/proc/mounts")
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	mounts := []mountEntry{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 {
+			continue
+		}
+		mounts = append(mounts, mountEntry{
+			device:     unescapeMountField(fields[0]),
+			mountPoint: unescapeMountField(fields[1]),
+			fsType:     fields[2],
+		})
+	}
+
+	return mounts, scanner.Err()
+}
+
+// unescapeMountField decodes the octal escapes (e.g. \040 for a space) used in /proc/mounts
+func unescapeMountField(field string) string {
+	if !strings.Contains(field, `\`) {
+		return field
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(field); i++ {
+		if field[i] == '\\' && i+3 < len(field) {
+			if code, err := strconv.ParseUint(field[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(code))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(field[i])
+	}
+	return b.String()
+}
+
+// isRealMount reports whether a mount holds real data rather than being a
+// pseudo filesystem (proc, sysfs, cgroup, tmpfs, ...) or a read-only image
+func isRealMount(m mountEntry) bool {
+	if poolFilesystems[m.fsType] {
+		return true
+	}
+	if m.fsType == "squashfs" {
+		return false
+	}
+	return strings.HasPrefix(m.device, "/dev/")
+}
+
+// GetMountStats returns usage for every real mounted filesystem. Bind
+// mounts of the same device are reported once, at the shortest mount point.
+func GetMountStats() ([]MountStats, error) {
+	mounts, err := readMounts()
+	if err != nil {
+		return nil, err
+	}
+
+	byDevice := make(map[string]mountEntry)
+	for _, m := range mounts {
+		if !isRealMount(m) {
+			continue
+		}
+		if existing, ok := byDevice[m.device]; !ok || len(m.mountPoint) < len(existing.mountPoint) {
+			byDevice[m.device] = m
+		}
+	}
+
+	stats := make([]MountStats, 0, len(byDevice))
+	for _, m := range byDevice {
+		disk, err := getDiskStatsActual(m.mountPoint)
+		if err != nil {
+			continue
+		}
+		stats = append(stats, newMountStats(m, disk))
+	}
+
+	sort.Slice(stats, func(i, j int) bool { return stats[i].MountPoint < stats[j].MountPoint })
+	return stats, nil
+}
+
+// GetDiskStatsForPath returns usage of the filesystem that contains path,
+// along with the mount it belongs to
+func GetDiskStatsForPath(path string) (*MountStats, error) {
+	disk, err := getDiskStatsActual(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Resolve symlinks so the path can be matched against mount points
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		resolved = path
+	}
+	if abs, err := filepath.Abs(resolved); err == nil {
+		resolved = abs
+	}
+
+	// The longest mount point that prefixes the path is the one containing it
+	best := mountEntry{mountPoint: "/"}
+	if mounts, err := readMounts(); err == nil {
+		bestLen := -1
+		for _, m := range mounts {
+			if !pathWithin(resolved, m.mountPoint) || len(m.mountPoint) <= bestLen {
+				continue
+			}
+			best = m
+			bestLen = len(m.mountPoint)
+		}
+	}
+
+	stats := newMountStats(best, disk)
+	return &stats, nil
+}
+
+// pathWithin reports whether path is dir or somewhere below it
+func pathWithin(path, dir string) bool {
+	if dir == "/" {
+		return strings.HasPrefix(path, "/")
+	}
+	return path == dir || strings.HasPrefix(path, dir+"/")
+}
+
+// newMountStats combines a mount entry with its usage figures
+func newMountStats(m mountEntry, disk *DiskStats) MountStats {
+	return MountStats{
+		Device:      m.device,
+		MountPoint:  m.mountPoint,
+		FSType:      m.fsType,
+		Total:       disk.Total,
+		Used:        disk.Used,
+		Free:        disk.Free,
+		UsedPercent: disk.UsedPercent,
+	}
+}
+
+// DiskStatsPath returns the path whose filesystem GetDiskStats reports:
+// the server folder when configured, otherwise the root partition
+func DiskStatsPath() string {
+	if config.AppConfig != nil {
+		if path := config.GetServerPath(); path != "" {
+			return path
+		}
+	}
+	return "/"
+}
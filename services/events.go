@@ -0,0 +1,64 @@
+package services
+
+import (
+	"time"
+
+	"minecraft-server-controller/models"
+)
+
+// Event types published by the panel
+const (
+	EventServerStarted   = "server.started"
+	EventServerStopped   = "server.stopped"
+	EventServerCrashed   = "server.crashed"
+	EventPlayerJoined    = "player.joined"
+	EventPlayerLeft      = "player.left"
+	EventBackupFinished  = "backup.finished"
+	EventCommandExecuted = "command.executed"
+)
+
+// EventTypes lists every event type, in display order
+var EventTypes = []string{
+	EventServerStarted,
+	EventServerStopped,
+	EventServerCrashed,
+	EventPlayerJoined,
+	EventPlayerLeft,
+	EventBackupFinished,
+	EventCommandExecuted,
+}
+
+// Event is something that happened to a server
+type Event struct {
+	Type     string                 `json:"event"`
+	Time     time.Time              `json:"timestamp"`
+	ServerID uint                   `json:"server_id"`
+	Server   string                 `json:"server"`
+	Data     map[string]interface{} `json:"data,omitempty"`
+	userID   uint
+}
+
+// IsEventType reports whether name is a known event type
+func IsEventType(name string) bool {
+	for _, eventType := range EventTypes {
+		if eventType == name {
+			return true
+		}
+	}
+	return false
+}
+
+// PublishEvent announces an event of a server to its owner's webhooks.
+// It never blocks: deliveries happen in the background.
+func PublishEvent(eventType string, server *models.Server, data map[string]interface{}) {
+	event := Event{
+		Type:     eventType,
+		Time:     time.Now().UTC(),
+		ServerID: server.ID,
+		Server:   server.Name,
+		Data:     data,
+		userID:   server.UserID,
+	}
+
+	go deliverEvent(event)
+}
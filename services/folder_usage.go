@@ -0,0 +1,259 @@
+package services
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"minecraft-server-controller/models"
+)
+
+const (
+	// folderUsageMaxAge is how old a server's size breakdown may get before it is rescanned
+	folderUsageMaxAge = 10 * time.Minute
+
+	// folderUsageTick is how often the refresher looks for a stale server.
+	// Only one server is scanned per tick to keep the disk load low.
+	folderUsageTick = 15 * time.Second
+)
+
+// Folder usage categories
+const (
+	UsageWorlds  = "worlds"
+	UsageLogs    = "logs"
+	UsagePlugins = "plugins"
+	UsageBackups = "backups"
+	UsageOther   = "other"
+)
+
+// FolderUsage holds the disk usage of a server folder, split by category
+type FolderUsage struct {
+	ServerID   uint              `json:"-"`
+	Server     string            `json:"server"`
+	Path       string            `json:"path"`
+	Total      uint64            `json:"total"`
+	Categories map[string]uint64 `json:"categories"`
+	ScannedAt  time.Time         `json:"scanned_at"`
+	Duration   float64           `json:"scan_seconds"`
+	Error      string            `json:"error,omitempty"` // why the last scan failed
+}
+
+var (
+	folderUsage    = make(map[uint]*FolderUsage)
+	folderUsageMux sync.Mutex
+
+	// folderRefreshQueue receives server IDs to rescan out of turn
+	folderRefreshQueue = make(chan uint, 32)
+)
+
+// StartFolderUsageRefresher starts the goroutine that keeps the per-server
+// folder size breakdown up to date, rescanning one stale server at a time
+func StartFolderUsageRefresher() {
+	go func() {
+		ticker := time.NewTicker(folderUsageTick)
+		defer ticker.Stop()
+
+		// Start with a pass right away so the page has data
+		refreshStalestFolder()
+
+		for {
+			select {
+			case serverID := <-folderRefreshQueue:
+				if server, err := models.GetServerByID(serverID); err == nil {
+					scanServerFolder(server)
+				}
+			case <-ticker.C:
+				refreshStalestFolder()
+			}
+		}
+	}()
+
+	log.Println("✅ Folder usage refresher started")
+}
+
+// RequestFolderUsageRefresh queues a server folder for rescanning
+func RequestFolderUsageRefresh(server *models.Server) {
+	select {
+	case folderRefreshQueue <- server.ID:
+	default:
+		// Queue full: the regular rotation will get to it
+	}
+}
+
+// refreshStalestFolder rescans the server whose breakdown is oldest, if it is stale
+func refreshStalestFolder() {
+	servers, err := models.GetAllServers()
+	if err != nil {
+		return
+	}
+
+	folderUsageMux.Lock()
+	var stalest *models.Server
+	var stalestAt time.Time
+	for i := range servers {
+		// A missing folder has nothing to measure
+		if servers[i].Missing {
+			continue
+		}
+		scannedAt := time.Time{}
+		if usage, ok := folderUsage[servers[i].ID]; ok {
+			scannedAt = usage.ScannedAt
+		}
+		if time.Since(scannedAt) < folderUsageMaxAge {
+			continue
+		}
+		if stalest == nil || scannedAt.Before(stalestAt) {
+			stalest = &servers[i]
+			stalestAt = scannedAt
+		}
+	}
+
+	// Forget servers that no longer exist
+	known := make(map[uint]bool, len(servers))
+	for _, server := range servers {
+		known[server.ID] = true
+	}
+	for id := range folderUsage {
+		if !known[id] {
+			delete(folderUsage, id)
+		}
+	}
+	folderUsageMux.Unlock()
+
+	if stalest != nil {
+		scanServerFolder(stalest)
+	}
+}
+
+// scanServerFolder measures a server folder and stores the result
+func scanServerFolder(server *models.Server) {
+	started := time.Now()
+	usage := &FolderUsage{
+		ServerID:   server.ID,
+		Server:     server.Name,
+		Path:       server.FolderPath,
+		Categories: make(map[string]uint64),
+	}
+
+	entries, err := os.ReadDir(server.FolderPath)
+	if err != nil {
+		log.Printf("⚠️  Failed to scan folder of server '%s': %v", server.Name, err)
+		// Recording the failure puts the server at the back of the rotation
+		usage.Error = err.Error()
+		usage.ScannedAt = time.Now()
+		folderUsageMux.Lock()
+		folderUsage[server.ID] = usage
+		folderUsageMux.Unlock()
+		return
+	}
+
+	// Hard links are only counted once
+	seen := make(map[uint64]bool)
+	for _, entry := range entries {
+		fullPath := filepath.Join(server.FolderPath, entry.Name())
+		size := diskUsage(fullPath, seen)
+		usage.Categories[categorizeFolderEntry(fullPath, entry)] += size
+		usage.Total += size
+	}
+
+	usage.ScannedAt = time.Now()
+	usage.Duration = time.Since(started).Seconds()
+
+	folderUsageMux.Lock()
+	folderUsage[server.ID] = usage
+	folderUsageMux.Unlock()
+}
+
+// categorizeFolderEntry decides which category a top-level entry of a server folder belongs to
+func categorizeFolderEntry(fullPath string, entry os.DirEntry) string {
+	name := strings.ToLower(entry.Name())
+
+	if entry.IsDir() {
+		switch name {
+		case "logs", "crash-reports":
+			return UsageLogs
+		case "plugins", "mods":
+			return UsagePlugins
+		case "backups", "backup", "world-backups":
+			return UsageBackups
+		}
+
+		// Any folder with a level.dat is a world (world, world_nether, ...)
+		if _, err := os.Stat(filepath.Join(fullPath, "level.dat")); err == nil {
+			return UsageWorlds
+		}
+		return UsageOther
+	}
+
+	switch {
+	case strings.HasSuffix(name, ".log"), strings.HasSuffix(name, ".log.gz"):
+		return UsageLogs
+	case strings.HasSuffix(name, ".zip"), strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return UsageBackups
+	}
+	return UsageOther
+}
+
+// diskUsage returns the space allocated on disk for a file or directory tree, like du
+func diskUsage(path string, seen map[uint64]bool) uint64 {
+	var total uint64
+	filepath.WalkDir(path, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			// Unreadable entries are skipped, not fatal
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+
+		if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+			if stat.Nlink > 1 && !d.IsDir() {
+				if seen[stat.Ino] {
+					return nil
+				}
+				seen[stat.Ino] = true
+			}
+			total += uint64(stat.Blocks) * 512
+		} else {
+			total += uint64(info.Size())
+		}
+		return nil
+	})
+	return total
+}
+
+// GetFolderUsage returns the cached size breakdown of every scanned server
+// of a user, largest first
+func GetFolderUsage(userID uint) []FolderUsage {
+	servers, err := models.GetServersByUserID(userID)
+	if err != nil {
+		return []FolderUsage{}
+	}
+
+	folderUsageMux.Lock()
+	defer folderUsageMux.Unlock()
+
+	usages := make([]FolderUsage, 0, len(servers))
+	for _, server := range servers {
+		usage, exists := folderUsage[server.ID]
+		if !exists {
+			continue
+		}
+		copied := *usage
+		copied.Categories = make(map[string]uint64, len(usage.Categories))
+		for category, size := range usage.Categories {
+			copied.Categories[category] = size
+		}
+		usages = append(usages, copied)
+	}
+
+	sort.Slice(usages, func(i, j int) bool { return usages[i].Total > usages[j].Total })
+	return usages
+}
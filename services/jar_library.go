@@ -0,0 +1,128 @@
+package services
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"minecraft-server-controller/config"
+	"minecraft-server-controller/models"
+)
+
+// maxJarSize caps the size of an uploaded server jar
+const maxJarSize = 512 << 20
+
+// JarLibraryFile returns where a library jar is stored on disk
+func JarLibraryFile(jar *models.JarFile) string {
+	return filepath.Join(config.GetJarLibraryPath(), jar.SHA256+".jar")
+}
+
+// AddJarToLibrary stores an uploaded jar in the library. When a jar with the
+// same content is already there, that entry is returned and existing is true.
+func AddJarToLibrary(src io.Reader, name, label string, userID uint) (jar *models.JarFile, existing bool, err error) {
+	dir := config.GetJarLibraryPath()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, false, fmt.Errorf("failed to create jar library: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, "upload-*.tmp")
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	// Hash while copying so the upload is only read once
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), io.LimitReader(src, maxJarSize+1))
+	closeErr := tmp.Close()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to store upload: %w", err)
+	}
+	if closeErr != nil {
+		return nil, false, fmt.Errorf("failed to store upload: %w", closeErr)
+	}
+	if size > maxJarSize {
+		return nil, false, fmt.Errorf("jar is larger than %d MB", maxJarSize>>20)
+	}
+
+	if err := checkJarArchive(tmp.Name()); err != nil {
+		return nil, false, err
+	}
+
+	sum := hex.EncodeToString(hash.Sum(nil))
+	if found, err := models.GetJarFileBySHA256(sum); err == nil {
+		return found, true, nil
+	}
+
+	jar = &models.JarFile{SHA256: sum}
+	if err := os.Rename(tmp.Name(), JarLibraryFile(jar)); err != nil {
+		return nil, false, fmt.Errorf("failed to store jar: %w", err)
+	}
+
+	jar, err = models.CreateJarFile(name, label, sum, size, userID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return jar, false, nil
+}
+
+// checkJarArchive verifies that a file is a readable zip archive
+func checkJarArchive(path string) error {
+	reader, err := zip.OpenReader(path)
+	if err != nil {
+		return errors.New("file is not a valid jar")
+	}
+	return reader.Close()
+}
+
+// RemoveJarFromLibrary deletes a jar the user added to the library, unless
+// servers were provisioned from it
+func RemoveJarFromLibrary(jar *models.JarFile, userID uint) error {
+	if jar.UploadedBy != userID {
+		return errors.New("only the user who added the jar can remove it")
+	}
+	if count, err := jar.ServerCount(); err != nil {
+		return err
+	} else if count > 0 {
+		return fmt.Errorf("%d server(s) were provisioned from this jar", count)
+	}
+
+	if err := os.Remove(JarLibraryFile(jar)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return jar.Delete()
+}
+
+// copyLibraryJar copies a library jar to dst, checking its hash on the way
+func copyLibraryJar(jar *models.JarFile, dst string) error {
+	src, err := os.Open(JarLibraryFile(jar))
+	if err != nil {
+		return fmt.Errorf("jar is missing from the library: %w", err)
+	}
+	defer src.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(out, hash), src); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+
+	if hex.EncodeToString(hash.Sum(nil)) != jar.SHA256 {
+		return errors.New("library jar is corrupted (SHA-256 mismatch)")
+	}
+	return nil
+}
@@ -0,0 +1,356 @@
+package services
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"minecraft-server-controller/config"
+)
+
+// JavaRuntime is an installed JDK or JRE
+type JavaRuntime struct {
+	Path    string `json:"path"`    // the java binary
+	Home    string `json:"home"`    // the JAVA_HOME of the runtime
+	Version string `json:"version"` // JAVA_VERSION from the release file
+	Major   int    `json:"major"`   // feature version, e.g. 8, 17 or 21
+	Vendor  string `json:"vendor"`
+}
+
+// Label returns a short description for selection lists
+func (rt *JavaRuntime) Label() string {
+	label := "Java " + strconv.Itoa(rt.Major)
+	if rt.Vendor != "" {
+		label += " (" + rt.Vendor + ")"
+	}
+	return label
+}
+
+// javaSearchDirs returns the folders whose subfolders are Java installations
+func javaSearchDirs() []string {
+	dirs := []string{"/usr/lib/jvm", "/usr/java", "/opt/java", "/opt/jdk"}
+
+	if sdkman := os.Getenv("SDKMAN_DIR"); sdkman != "" {
+		dirs = append(dirs, filepath.Join(sdkman, "candidates", "java"))
+	} else if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, filepath.Join(home, ".sdkman", "candidates", "java"))
+	}
+	return dirs
+}
+
+// DiscoverJavaRuntimes scans the common install locations and the configured
+// Java paths for runtimes. Runtimes reachable through several paths, such as
+// SDKMAN's "current" link, are listed once. The newest runtimes come first.
+func DiscoverJavaRuntimes() []JavaRuntime {
+	homes := []string{}
+	for _, dir := range javaSearchDirs() {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			homes = append(homes, filepath.Join(dir, entry.Name()))
+		}
+	}
+
+	// Configured paths may be a Java home, a java binary or a folder of homes
+	for _, path := range config.GetJavaPaths() {
+		if _, err := os.Stat(filepath.Join(path, "bin", "java")); err == nil {
+			homes = append(homes, path)
+			continue
+		}
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			// /usr/bin/java is usually a link into the real home
+			if real, err := filepath.EvalSymlinks(path); err == nil {
+				path = real
+			}
+			homes = append(homes, filepath.Dir(filepath.Dir(path)))
+			continue
+		}
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			homes = append(homes, filepath.Join(path, entry.Name()))
+		}
+	}
+
+	// Prefer real folders over links to them, like SDKMAN's "current"
+	sort.SliceStable(homes, func(i, j int) bool {
+		return !isSymlink(homes[i]) && isSymlink(homes[j])
+	})
+
+	seen := make(map[string]bool)
+	runtimes := []JavaRuntime{}
+	for _, home := range homes {
+		javaPath := filepath.Join(home, "bin", "java")
+		real, err := filepath.EvalSymlinks(javaPath)
+		if err != nil || seen[real] {
+			continue
+		}
+		seen[real] = true
+
+		rt, err := inspectJavaHome(home)
+		if err != nil {
+			continue
+		}
+		rt.Path = javaPath
+		runtimes = append(runtimes, *rt)
+	}
+
+	sort.SliceStable(runtimes, func(i, j int) bool {
+		if runtimes[i].Major != runtimes[j].Major {
+			return runtimes[i].Major > runtimes[j].Major
+		}
+		return runtimes[i].Path < runtimes[j].Path
+	})
+	return runtimes
+}
+
+// isSymlink reports whether path is a symbolic link
+func isSymlink(path string) bool {
+	info, err := os.Lstat(path)
+	return err == nil && info.Mode()&os.ModeSymlink != 0
+}
+
+// inspectJavaHome reads the version and vendor of a Java home from its
+// release file. A Java 8 JRE inside a JDK uses the JDK's release file.
+func inspectJavaHome(home string) (*JavaRuntime, error) {
+	release, err := readReleaseFile(filepath.Join(home, "release"))
+	if err != nil && filepath.Base(home) == "jre" {
+		release, err = readReleaseFile(filepath.Join(filepath.Dir(home), "release"))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	version := release["JAVA_VERSION"]
+	major := JavaMajorVersion(version)
+	if major == 0 {
+		return nil, fmt.Errorf("unknown Java version %q", version)
+	}
+
+	vendor := release["IMPLEMENTOR"]
+	if vendor == "" {
+		vendor = release["JAVA_VENDOR"]
+	}
+
+	return &JavaRuntime{
+		Home:    home,
+		Version: version,
+		Major:   major,
+		Vendor:  vendor,
+	}, nil
+}
+
+// readReleaseFile parses the KEY="VALUE" lines of a Java release file
+func readReleaseFile(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		key, value, found := strings.Cut(scanner.Text(), "=")
+		if !found {
+			continue
+		}
+		values[strings.TrimSpace(key)] = strings.Trim(strings.TrimSpace(value), `"`)
+	}
+	return values, scanner.Err()
+}
+
+// JavaMajorVersion returns the feature version of a Java version string:
+// 8 for "1.8.0_392", 17 for "17.0.9" and 21 for "21"
+func JavaMajorVersion(version string) int {
+	version = strings.TrimPrefix(version, "1.")
+	end := 0
+	for end < len(version) && version[end] >= '0' && version[end] <= '9' {
+		end++
+	}
+	major, _ := strconv.Atoi(version[:end])
+	return major
+}
+
+// ResolveJavaRuntime finds the runtime a java command refers to, following
+// PATH and symlinks such as /usr/bin/java -> /etc/alternatives/java
+func ResolveJavaRuntime(java string) (*JavaRuntime, error) {
+	path := java
+	if !strings.Contains(java, "/") {
+		found, err := exec.LookPath(java)
+		if err != nil {
+			return nil, err
+		}
+		path = found
+	}
+
+	real, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return nil, err
+	}
+
+	rt, err := inspectJavaHome(filepath.Dir(filepath.Dir(real)))
+	if err != nil {
+		return nil, err
+	}
+	rt.Path = java
+	return rt, nil
+}
+
+// SelectJavaRuntime returns the oldest discovered runtime that can run code
+// for the required Java version, or nil when none is installed
+func SelectJavaRuntime(required int) *JavaRuntime {
+	runtimes := DiscoverJavaRuntimes()
+	var selected *JavaRuntime
+	for i := range runtimes {
+		if runtimes[i].Major >= required && (selected == nil || runtimes[i].Major < selected.Major) {
+			selected = &runtimes[i]
+		}
+	}
+	return selected
+}
+
+// JavaForJar returns the java command to run a jar with: a discovered
+// runtime that meets the jar's requirement, or plain "java" from PATH
+func JavaForJar(jarPath string) string {
+	required, err := JarJavaRequirement(jarPath)
+	if err != nil {
+		return "java"
+	}
+	if rt := SelectJavaRuntime(required); rt != nil {
+		return rt.Path
+	}
+	return "java"
+}
+
+// classFileMagic starts every Java class file
+const classFileMagic = 0xCAFEBABE
+
+// JarJavaRequirement returns the Java version a server jar needs. Vanilla
+// and Paper jars state it in version.json; otherwise the class file version
+// of the main class is used.
+func JarJavaRequirement(jarPath string) (int, error) {
+	archive, err := zip.OpenReader(jarPath)
+	if err != nil {
+		return 0, err
+	}
+	defer archive.Close()
+
+	if entry := findZipEntry(&archive.Reader, "version.json"); entry != nil {
+		var info struct {
+			JavaVersion int `json:"java_version"`
+		}
+		if err := readZipJSON(entry, &info); err == nil && info.JavaVersion > 0 {
+			return info.JavaVersion, nil
+		}
+	}
+
+	mainClass, err := jarMainClass(&archive.Reader)
+	if err != nil {
+		return 0, err
+	}
+	entry := findZipEntry(&archive.Reader, strings.ReplaceAll(mainClass, ".", "/")+".class")
+	if entry == nil {
+		return 0, fmt.Errorf("main class %s not found", mainClass)
+	}
+
+	file, err := entry.Open()
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	// magic (4 bytes), minor version (2), major version (2)
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(file, header); err != nil {
+		return 0, err
+	}
+	if binary.BigEndian.Uint32(header) != classFileMagic {
+		return 0, errors.New("main class is not a class file")
+	}
+
+	// Class file version 52 is Java 8, 61 is Java 17
+	return int(binary.BigEndian.Uint16(header[6:])) - 44, nil
+}
+
+// findZipEntry returns the named entry of an archive
+func findZipEntry(archive *zip.Reader, name string) *zip.File {
+	for _, entry := range archive.File {
+		if entry.Name == name {
+			return entry
+		}
+	}
+	return nil
+}
+
+// readZipJSON decodes a JSON entry of an archive
+func readZipJSON(entry *zip.File, v interface{}) error {
+	file, err := entry.Open()
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return json.NewDecoder(file).Decode(v)
+}
+
+// jarMainClass returns the Main-Class of a jar's manifest
+func jarMainClass(archive *zip.Reader) (string, error) {
+	if findZipEntry(archive, "META-INF/MANIFEST.MF") == nil {
+		return "", errors.New("jar has no manifest")
+	}
+	mainClass := readJarManifest(archive)["Main-Class"]
+	if mainClass == "" {
+		return "", errors.New("jar manifest has no Main-Class")
+	}
+	return mainClass, nil
+}
+
+// javaCompatibilityWarning checks that the java of a startup command can run
+// the jar it starts, and returns a warning when the jar needs a newer Java
+func javaCompatibilityWarning(args []string, folder string) string {
+	if len(args) == 0 || !strings.HasPrefix(filepath.Base(args[0]), "java") {
+		return ""
+	}
+
+	jar := ""
+	for i := 1; i+1 < len(args); i++ {
+		if args[i] == "-jar" {
+			jar = args[i+1]
+			break
+		}
+	}
+	if jar == "" {
+		return ""
+	}
+	if !filepath.IsAbs(jar) {
+		jar = filepath.Join(folder, jar)
+	}
+
+	required, err := JarJavaRequirement(jar)
+	if err != nil {
+		return ""
+	}
+	rt, err := ResolveJavaRuntime(args[0])
+	if err != nil {
+		return ""
+	}
+
+	if required > rt.Major {
+		return fmt.Sprintf("%s needs Java %d but %s is Java %d", filepath.Base(jar), required, args[0], rt.Major)
+	}
+	return ""
+}
@@ -0,0 +1,302 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"minecraft-server-controller/models"
+)
+
+// GCPresets lists the GC presets with their display names, in display order
+var GCPresets = []struct {
+	ID   string
+	Name string
+}{
+	{models.GCDefault, "JVM default"},
+	{models.GCAikarG1, "G1 with Aikar's flags"},
+	{models.GCZGC, "ZGC"},
+	{models.GCShenandoah, "Shenandoah"},
+}
+
+// aikarFlags are Aikar's G1 flags for heaps up to 12 GB (https://mcflags.emc.gs)
+var aikarFlags = []string{
+	"-XX:+UseG1GC",
+	"-XX:+ParallelRefProcEnabled",
+	"-XX:MaxGCPauseMillis=200",
+	"-XX:+UnlockExperimentalVMOptions",
+	"-XX:+DisableExplicitGC",
+	"-XX:+AlwaysPreTouch",
+	"-XX:G1NewSizePercent=30",
+	"-XX:G1MaxNewSizePercent=40",
+	"-XX:G1HeapRegionSize=8M",
+	"-XX:G1ReservePercent=20",
+	"-XX:G1HeapWastePercent=5",
+	"-XX:G1MixedGCCountTarget=4",
+	"-XX:InitiatingHeapOccupancyPercent=15",
+	"-XX:G1MixedGCLiveThresholdPercent=90",
+	"-XX:G1RSetUpdatingPauseTimePercent=5",
+	"-XX:SurvivorRatio=32",
+	"-XX:+PerfDisableSharedMem",
+	"-XX:MaxTenuringThreshold=1",
+	"-Dusing.aikars.flags=https://mcflags.emc.gs",
+	"-Daikars.new.flags=true",
+}
+
+// aikarLargeHeap replaces some of Aikar's flags for heaps above 12 GB
+var aikarLargeHeap = map[string]string{
+	"-XX:G1NewSizePercent=30":               "-XX:G1NewSizePercent=40",
+	"-XX:G1MaxNewSizePercent=40":            "-XX:G1MaxNewSizePercent=50",
+	"-XX:G1HeapRegionSize=8M":               "-XX:G1HeapRegionSize=16M",
+	"-XX:G1ReservePercent=20":               "-XX:G1ReservePercent=15",
+	"-XX:InitiatingHeapOccupancyPercent=15": "-XX:InitiatingHeapOccupancyPercent=20",
+}
+
+// gcFlags returns the JVM flags of a GC preset for the given max heap
+func gcFlags(preset string, maxHeapMB int) []string {
+	switch preset {
+	case models.GCAikarG1:
+		flags := make([]string, len(aikarFlags))
+		for i, flag := range aikarFlags {
+			if replacement, ok := aikarLargeHeap[flag]; ok && maxHeapMB > 12*1024 {
+				flag = replacement
+			}
+			flags[i] = flag
+		}
+		return flags
+	case models.GCZGC:
+		return []string{"-XX:+UseZGC"}
+	case models.GCShenandoah:
+		return []string{"-XX:+UseShenandoahGC"}
+	}
+	return nil
+}
+
+// DefaultLaunchProfile returns the profile used for new servers
+func DefaultLaunchProfile(jar string, memoryMB int) *models.LaunchProfile {
+	return &models.LaunchProfile{
+		JavaPath:  "java",
+		MinHeapMB: memoryMB,
+		MaxHeapMB: memoryMB,
+		GCPreset:  models.GCAikarG1,
+		JarPath:   jar,
+		NoGUI:     true,
+	}
+}
+
+// ValidateLaunchProfile checks a profile before it is rendered
+func ValidateLaunchProfile(profile *models.LaunchProfile) error {
+	if profile.MaxHeapMB < 256 {
+		return errors.New("max heap must be at least 256 MB")
+	}
+	if profile.MinHeapMB < 0 || profile.MinHeapMB > profile.MaxHeapMB {
+		return errors.New("min heap must be between 0 and the max heap")
+	}
+	if strings.TrimSpace(profile.JarPath) == "" {
+		return errors.New("jar path cannot be empty")
+	}
+	if profile.Port < 0 || profile.Port > 65535 {
+		return errors.New("port must be between 1 and 65535")
+	}
+
+	validPreset := false
+	for _, preset := range GCPresets {
+		if profile.GCPreset == preset.ID {
+			validPreset = true
+		}
+	}
+	if !validPreset {
+		return errors.New("unknown GC preset")
+	}
+
+	for _, arg := range profile.JVMArgs {
+		if !strings.HasPrefix(arg, "-") {
+			return fmt.Errorf("JVM argument %q must start with '-'", arg)
+		}
+	}
+
+	if profile.WorkingDir != "" {
+		if filepath.IsAbs(profile.WorkingDir) {
+			return errors.New("working directory must be relative to the server folder")
+		}
+		if clean := filepath.Clean(profile.WorkingDir); clean == ".." || strings.HasPrefix(clean, "../") {
+			return errors.New("working directory must stay inside the server folder")
+		}
+	}
+
+	return nil
+}
+
+// RenderLaunchProfile renders a profile to a startup command. The output
+// only depends on the profile, so the same profile always gives the same
+// command: java, heap, GC preset flags, extra JVM args, -jar, then server
+// args.
+func RenderLaunchProfile(profile *models.LaunchProfile) string {
+	java := profile.JavaPath
+	if java == "" {
+		java = "java"
+	}
+
+	args := []string{java}
+	if profile.MinHeapMB > 0 {
+		args = append(args, fmt.Sprintf("-Xms%dM", profile.MinHeapMB))
+	}
+	args = append(args, fmt.Sprintf("-Xmx%dM", profile.MaxHeapMB))
+	args = append(args, gcFlags(profile.GCPreset, profile.MaxHeapMB)...)
+	args = append(args, profile.JVMArgs...)
+	args = append(args, "-jar", profile.JarPath)
+
+	if profile.Port > 0 && !containsArg(profile.ServerArgs, "--port") {
+		args = append(args, "--port", strconv.Itoa(profile.Port))
+	}
+	if profile.NoGUI && !containsArg(profile.ServerArgs, "nogui") && !containsArg(profile.ServerArgs, "--nogui") {
+		args = append(args, "--nogui")
+	}
+	args = append(args, profile.ServerArgs...)
+
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = QuoteArg(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// containsArg reports whether args contains arg
+func containsArg(args []string, arg string) bool {
+	for _, a := range args {
+		if a == arg {
+			return true
+		}
+	}
+	return false
+}
+
+// ProfileFromCommand builds a launch profile from a raw java command, as a
+// starting point when switching a server to the profile editor. Flags of a
+// known GC preset are folded into the preset.
+func ProfileFromCommand(command string, env map[string]string) *models.LaunchProfile {
+	profile := &models.LaunchProfile{JavaPath: "java", GCPreset: models.GCDefault, MaxHeapMB: 2048}
+
+	args, err := SplitCommand(command, env)
+	if err != nil || len(args) == 0 || !strings.HasSuffix(filepath.Base(args[0]), "java") {
+		return profile
+	}
+	profile.JavaPath = args[0]
+
+	jvmArgs := []string{}
+	i := 1
+	for ; i < len(args); i++ {
+		arg := args[i]
+		if arg == "-jar" && i+1 < len(args) {
+			profile.JarPath = args[i+1]
+			i += 2
+			break
+		}
+		if mb, ok := ParseMemoryFlag(arg, "-Xms"); ok {
+			profile.MinHeapMB = mb
+		} else if mb, ok := ParseMemoryFlag(arg, "-Xmx"); ok {
+			profile.MaxHeapMB = mb
+		} else {
+			jvmArgs = append(jvmArgs, arg)
+		}
+	}
+
+	serverArgs := args[i:]
+	for j := 0; j < len(serverArgs); j++ {
+		arg := serverArgs[j]
+		if arg == "nogui" || arg == "--nogui" {
+			profile.NoGUI = true
+			continue
+		}
+		if arg == "--port" && j+1 < len(serverArgs) && profile.Port == 0 {
+			if port, err := strconv.Atoi(serverArgs[j+1]); err == nil {
+				profile.Port = port
+				j++
+				continue
+			}
+		}
+		profile.ServerArgs = append(profile.ServerArgs, arg)
+	}
+
+	// Recognise a GC preset when all of its flags are present
+	for _, preset := range []string{models.GCAikarG1, models.GCZGC, models.GCShenandoah} {
+		flags := gcFlags(preset, profile.MaxHeapMB)
+		if !containsAll(jvmArgs, flags) {
+			continue
+		}
+		profile.GCPreset = preset
+		jvmArgs = removeAll(jvmArgs, flags)
+		break
+	}
+	profile.JVMArgs = jvmArgs
+
+	return profile
+}
+
+// containsAll reports whether every flag is in args
+func containsAll(args, flags []string) bool {
+	for _, flag := range flags {
+		if !containsArg(args, flag) {
+			return false
+		}
+	}
+	return true
+}
+
+// removeAll returns args without the given flags
+func removeAll(args, flags []string) []string {
+	kept := []string{}
+	for _, arg := range args {
+		if !containsArg(flags, arg) {
+			kept = append(kept, arg)
+		}
+	}
+	return kept
+}
+
+// ParseMemoryFlag parses a JVM memory flag such as -Xmx4G or -Xms512m with
+// the given prefix and returns the size in MB
+func ParseMemoryFlag(arg, prefix string) (int, bool) {
+	if !strings.HasPrefix(arg, prefix) {
+		return 0, false
+	}
+	value := arg[len(prefix):]
+	if value == "" {
+		return 0, false
+	}
+
+	unit := value[len(value)-1]
+	number := value[:len(value)-1]
+	multiplier := 0.0
+	switch unit {
+	case 'k', 'K':
+		multiplier = 1.0 / 1024
+	case 'm', 'M':
+		multiplier = 1
+	case 'g', 'G':
+		multiplier = 1024
+	case 't', 'T':
+		multiplier = 1024 * 1024
+	default:
+		// Plain bytes
+		number = value
+		multiplier = 1.0 / (1024 * 1024)
+	}
+
+	n, err := strconv.ParseFloat(number, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return int(n * multiplier), true
+}
+
+// ServerWorkingDir returns the directory a server process runs in: the
+// profile's working directory inside the server folder, or the folder itself
+func ServerWorkingDir(server *models.Server) string {
+	if server.UsesLaunchProfile() && server.LaunchProfile.WorkingDir != "" {
+		return filepath.Join(server.FolderPath, server.LaunchProfile.WorkingDir)
+	}
+	return server.FolderPath
+}
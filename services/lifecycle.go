@@ -0,0 +1,338 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"minecraft-server-controller/models"
+)
+
+// Delete modes
+const (
+	DeleteToTrash = "trash" // move the folder to .trash next to the servers
+	DeletePurge   = "purge" // remove the folder for good
+)
+
+// trashFolderName is the folder, next to the server folders, that deleted servers are moved to
+const trashFolderName = ".trash"
+
+// cloneSkip lists top-level entries that are not copied when cloning
+var cloneSkip = map[string]bool{
+	"logs":           true,
+	"crash-reports":  true,
+	backupFolderName: true,
+}
+
+// cloneSkipFile is the world lock file, not copied at any depth when cloning
+const cloneSkipFile = "session.lock"
+
+// lockStopped takes the lifecycle lock of a stopped server, so no start can
+// launch it while its folder or record changes. It fails with ErrServerBusy
+// while another operation runs, and when the server is running. The caller
+// unlocks the lock.
+func lockStopped(server *models.Server) (*sync.Mutex, error) {
+	lock, err := acquireLifecycleLock(server.ID, "")
+	if err != nil {
+		return nil, err
+	}
+	if IsServerRunning(server) {
+		lock.Unlock()
+		return nil, errors.New("server must be stopped first")
+	}
+	return lock, nil
+}
+
+// RenameServer renames a stopped server, moving its folder along
+func RenameServer(server *models.Server, newName string) error {
+	lock, err := lockStopped(server)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+	if err := ValidateServerName(newName); err != nil {
+		return err
+	}
+	if newName == server.Name {
+		return nil
+	}
+
+	if _, err := models.GetServerByName(newName, server.UserID); err == nil {
+		return errors.New("a server with this name already exists")
+	}
+
+	oldName, oldFolder := server.Name, server.FolderPath
+	newFolder := filepath.Join(filepath.Dir(oldFolder), newName)
+	if _, err := os.Lstat(newFolder); err == nil {
+		return errors.New("a folder with this name already exists")
+	}
+
+	if !server.Missing {
+		if err := os.Rename(oldFolder, newFolder); err != nil {
+			return fmt.Errorf("failed to rename folder: %w", err)
+		}
+	}
+
+	if err := server.Rename(newName, newFolder); err != nil {
+		// Put the folder back so record and disk stay in step
+		if !server.Missing {
+			os.Rename(newFolder, oldFolder)
+		}
+		return err
+	}
+
+	// Keep the metric history with the server
+	for _, metric := range ServerMetrics {
+		if err := models.RenameMetricSeries(ServerMetricSeries(oldName, metric), ServerMetricSeries(newName, metric)); err != nil {
+			log.Printf("⚠️  Failed to rename metric history of server '%s': %v", oldName, err)
+		}
+	}
+
+	RequestFolderUsageRefresh(server)
+	log.Printf("✅ Server '%s' renamed to '%s'", oldName, newName)
+	return nil
+}
+
+// DeleteServer deletes a stopped server with its history. Its folder is moved
+// to the trash or removed, depending on mode; servers whose folder is missing
+// only lose their record.
+func DeleteServer(server *models.Server, mode string) error {
+	lock, err := lockStopped(server)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	if !server.Missing {
+		switch mode {
+		case DeleteToTrash:
+			trash := filepath.Join(filepath.Dir(server.FolderPath), trashFolderName)
+			if err := os.MkdirAll(trash, 0755); err != nil {
+				return fmt.Errorf("failed to create trash folder: %w", err)
+			}
+			target := filepath.Join(trash, fmt.Sprintf("%s-%s", server.Name, time.Now().Format("20060102-150405")))
+			if err := os.Rename(server.FolderPath, target); err != nil {
+				return fmt.Errorf("failed to move folder to trash: %w", err)
+			}
+		case DeletePurge:
+			if err := os.RemoveAll(server.FolderPath); err != nil {
+				return fmt.Errorf("failed to remove folder: %w", err)
+			}
+		default:
+			return errors.New("unknown delete mode")
+		}
+	}
+
+	if err := server.DeleteWithRecords(ServerMetricSeriesNames(server.Name), serverAlertSubject(server.Name)); err != nil {
+		return err
+	}
+	forgetServerAlerts(serverAlertSubject(server.Name))
+	forgetServerCounters(server.ID)
+
+	log.Printf("🗑️  Server '%s' deleted", server.Name)
+	return nil
+}
+
+// CloneServer copies a stopped server into a new server that listens on port
+func CloneServer(server *models.Server, newName string, port int) (*models.Server, error) {
+	lock, err := lockStopped(server)
+	if err != nil {
+		return nil, err
+	}
+	defer lock.Unlock()
+	if server.Missing {
+		return nil, errors.New("server folder is missing")
+	}
+	if err := ValidateServerName(newName); err != nil {
+		return nil, err
+	}
+	if port < 1 || port > 65535 {
+		return nil, errors.New("port must be between 1 and 65535")
+	}
+
+	if _, err := models.GetServerByName(newName, server.UserID); err == nil {
+		return nil, errors.New("a server with this name already exists")
+	}
+
+	newFolder := filepath.Join(filepath.Dir(server.FolderPath), newName)
+	if err := os.Mkdir(newFolder, 0755); err != nil {
+		if os.IsExist(err) {
+			return nil, errors.New("a folder with this name already exists")
+		}
+		return nil, fmt.Errorf("failed to create server folder: %w", err)
+	}
+
+	clone, err := cloneFolder(server, newName, newFolder, port)
+	if err != nil {
+		os.RemoveAll(newFolder)
+		return nil, err
+	}
+
+	log.Printf("✅ Server '%s' cloned to '%s' (port %d)", server.Name, newName, port)
+	return clone, nil
+}
+
+// cloneFolder copies the server files, sets the new port and registers the clone
+func cloneFolder(server *models.Server, newName, newFolder string, port int) (*models.Server, error) {
+	entries, err := os.ReadDir(server.FolderPath)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		if cloneSkip[entry.Name()] {
+			continue
+		}
+		if err := copyTree(filepath.Join(server.FolderPath, entry.Name()), filepath.Join(newFolder, entry.Name())); err != nil {
+			return nil, fmt.Errorf("failed to copy %s: %w", entry.Name(), err)
+		}
+	}
+
+	props := map[string]string{
+		"server-port": strconv.Itoa(port),
+		"query.port":  strconv.Itoa(port),
+	}
+	// The RCON port of the original is taken as well
+	if original, err := ReadProperties(ServerPropertiesFile(newFolder)); err == nil && original["enable-rcon"] == "true" {
+		rcon, err := SuggestPort(DefaultRCONPort)
+		if err == nil && rcon == port {
+			rcon, err = SuggestPort(port + 1)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to find a free RCON port: %w", err)
+		}
+		props["rcon.port"] = strconv.Itoa(rcon)
+	}
+	if err := UpdateProperties(ServerPropertiesFile(newFolder), props); err != nil {
+		return nil, fmt.Errorf("failed to update server.properties: %w", err)
+	}
+
+	clone, err := models.CreateServer(newName, newFolder, server.StartupCommand, server.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if server.UsesLaunchProfile() {
+		profile := *server.LaunchProfile
+		// A --port in the profile would override server.properties
+		if profile.Port > 0 {
+			profile.Port = port
+		}
+		err = clone.UpdateLaunchProfile(&profile, RenderLaunchProfile(&profile), server.Env)
+	} else {
+		err = clone.UpdateStartup(server.StartupCommand, server.ExecMode, server.Env)
+	}
+	if err != nil {
+		clone.Delete()
+		return nil, err
+	}
+	if err := cloneSettings(server, clone); err != nil {
+		clone.Delete()
+		return nil, err
+	}
+	return clone, nil
+}
+
+// cloneSettings gives the clone the software, stop settings, process options,
+// resource limits and dependencies of the original
+func cloneSettings(server, clone *models.Server) error {
+	if err := clone.SetSoftware(server.Software, server.MCVersion); err != nil {
+		return err
+	}
+	if server.StopSettings != nil {
+		settings := *server.StopSettings
+		if err := clone.UpdateStopSettings(&settings); err != nil {
+			return err
+		}
+	}
+	if server.ProcessOptions != nil {
+		options := *server.ProcessOptions
+		if err := clone.UpdateProcessOptions(&options); err != nil {
+			return err
+		}
+	}
+	if server.ResourceLimits != nil {
+		limits := *server.ResourceLimits
+		if err := clone.UpdateResourceLimits(&limits); err != nil {
+			return err
+		}
+	}
+	if len(server.Dependencies) > 0 {
+		dependencies := append([]models.ServerDependency(nil), server.Dependencies...)
+		if err := clone.UpdateDependencies(dependencies); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyTree copies a file, symlink or directory tree, keeping permissions.
+// World lock files are left out.
+func copyTree(src, dst string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Name() == cloneSkipFile && !info.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case info.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm())
+		case info.Mode().IsRegular():
+			return copyFile(path, target, info.Mode().Perm())
+		}
+
+		// Sockets, pipes and devices are skipped
+		return nil
+	})
+}
+
+// copyFile copies a regular file
+func copyFile(src, dst string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// ArchiveServer archives or unarchives a stopped server. Archived servers
+// cannot be started.
+func ArchiveServer(server *models.Server, archived bool) error {
+	lock, err := lockStopped(server)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+	return server.SetArchived(archived)
+}
@@ -0,0 +1,136 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"minecraft-server-controller/config"
+	"minecraft-server-controller/models"
+)
+
+// MemoryCheck is the outcome of the memory check done before a server starts
+type MemoryCheck struct {
+	HeapMB      int      `json:"heap_mb"`      // -Xmx of the server
+	FootprintMB int      `json:"footprint_mb"` // heap plus the configured overhead
+	AvailableMB int      `json:"available_mb"` // memory available on the host
+	GrowthMB    int      `json:"growth_mb"`    // memory running servers may still grow into
+	CommittedMB int      `json:"committed_mb"` // estimated footprint of the running servers
+	BudgetMB    int      `json:"budget_mb"`    // budget for all servers, 0 for none
+	Problems    []string `json:"problems"`
+}
+
+// ServerHeapMB returns the maximum heap of a server from the -Xmx of its
+// startup command or of the user_jvm_args.txt read by Forge's run scripts.
+// Without -Xmx the JVM takes a quarter of the host memory.
+func ServerHeapMB(server *models.Server) int {
+	if args, err := SplitCommand(server.StartupCommand, server.Env); err == nil {
+		if mb := lastHeapFlag(args); mb > 0 {
+			return mb
+		}
+	}
+
+	if data, err := os.ReadFile(filepath.Join(ServerWorkingDir(server), "user_jvm_args.txt")); err == nil {
+		args := []string{}
+		for _, line := range strings.Split(string(data), "\n") {
+			if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+				args = append(args, strings.Fields(line)...)
+			}
+		}
+		if mb := lastHeapFlag(args); mb > 0 {
+			return mb
+		}
+	}
+
+	if mem, err := GetMemoryStats(); err == nil {
+		return int(mem.Total / 4 / (1024 * 1024))
+	}
+	return 0
+}
+
+// lastHeapFlag returns the -Xmx the JVM uses, which is the last one given
+func lastHeapFlag(args []string) int {
+	heap := 0
+	for _, arg := range args {
+		if arg == "-jar" {
+			break
+		}
+		if mb, ok := ParseMemoryFlag(arg, "-Xmx"); ok {
+			heap = mb
+		}
+	}
+	return heap
+}
+
+// EstimateFootprintMB adds the configured JVM overhead to a heap size
+func EstimateFootprintMB(heapMB int) int {
+	budget := config.GetMemoryBudget()
+	return heapMB + heapMB*budget.OverheadPercent/100 + budget.OverheadMB
+}
+
+// checkServerMemory compares the footprint of a server with the available
+// memory, less what running servers may still grow into, and with the
+// total budget. serverMux must be held.
+func checkServerMemory(server *models.Server) *MemoryCheck {
+	budget := config.GetMemoryBudget()
+	check := &MemoryCheck{BudgetMB: budget.TotalMB}
+	check.HeapMB = ServerHeapMB(server)
+	check.FootprintMB = EstimateFootprintMB(check.HeapMB)
+
+	// A JVM reserves its heap up front but only touches it as it fills, so
+	// memory that looks available now may be claimed by running servers later
+	for id, sp := range runningServers {
+		if id == server.ID {
+			continue
+		}
+		footprint := EstimateFootprintMB(ServerHeapMB(sp.Server))
+		check.CommittedMB += footprint
+
+		usedMB := 0
+		if stats, err := getProcessTreeStats(sp.Cmd.Process.Pid); err == nil {
+			usedMB = int(stats.MemoryKB / 1024)
+		}
+		if footprint > usedMB {
+			check.GrowthMB += footprint - usedMB
+		}
+	}
+
+	if mem, err := GetMemoryStats(); err == nil {
+		check.AvailableMB = int(mem.Free / (1024 * 1024))
+		if headroom := check.AvailableMB - check.GrowthMB; check.FootprintMB > headroom {
+			check.Problems = append(check.Problems, fmt.Sprintf("needs about %d MB but only %d MB is available (%d MB free, %d MB reserved by running servers)",
+				check.FootprintMB, max(headroom, 0), check.AvailableMB, check.GrowthMB))
+		}
+	}
+
+	if budget.TotalMB > 0 && check.CommittedMB+check.FootprintMB > budget.TotalMB {
+		check.Problems = append(check.Problems, fmt.Sprintf("needs about %d MB, which with the %d MB of running servers exceeds the %d MB budget",
+			check.FootprintMB, check.CommittedMB, budget.TotalMB))
+	}
+
+	return check
+}
+
+// checkStartMemory applies the memory policy to a server about to start.
+// serverMux must be held.
+func checkStartMemory(server *models.Server) error {
+	policy := config.GetMemoryBudget().Policy
+	if policy == config.MemoryPolicyOff {
+		return nil
+	}
+
+	check := checkServerMemory(server)
+	if len(check.Problems) == 0 {
+		return nil
+	}
+
+	problem := "not enough memory: " + strings.Join(check.Problems, "; ")
+	if policy == config.MemoryPolicyRefuse {
+		return errors.New(problem)
+	}
+	log.Printf("⚠️  Server '%s' started with %s", server.Name, problem)
+	return nil
+}
@@ -0,0 +1,252 @@
+package services
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"minecraft-server-controller/models"
+)
+
+// metricsPrefix namespaces every exported metric
+const metricsPrefix = "mcsc_"
+
+// promWriter writes the Prometheus text exposition format
+type promWriter struct {
+	w *bufio.Writer
+}
+
+// family writes the HELP and TYPE header of a metric family
+func (p *promWriter) family(name, metricType, help string) {
+	fmt.Fprintf(p.w, "# HELP %s%s %s\n", metricsPrefix, name, help)
+	fmt.Fprintf(p.w, "# TYPE %s%s %s\n", metricsPrefix, name, metricType)
+}
+
+// sample writes one sample line; labels are given as name/value pairs
+func (p *promWriter) sample(name string, value float64, labels ...string) {
+	p.w.WriteString(metricsPrefix + name)
+
+	if len(labels) > 0 {
+		p.w.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				p.w.WriteByte(',')
+			}
+			fmt.Fprintf(p.w, `%s="%s"`, labels[i], escapeLabelValue(labels[i+1]))
+		}
+		p.w.WriteByte('}')
+	}
+
+	p.w.WriteByte(' ')
+	p.w.WriteString(strconv.FormatFloat(value, 'g', -1, 64))
+	p.w.WriteByte('\n')
+}
+
+// escapeLabelValue escapes backslashes, quotes and newlines in a label value
+func escapeLabelValue(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `"`, `\"`)
+	return strings.ReplaceAll(value, "\n", `\n`)
+}
+
+// serverSnapshot holds everything exported for one server
+type serverSnapshot struct {
+	name     string
+	up       bool
+	stats    *ServerStats
+	counters ServerCounters
+	players  int
+	clients  int
+}
+
+// WritePrometheusMetrics writes host metrics and the metrics of a user's
+// servers in the Prometheus text exposition format
+func WritePrometheusMetrics(out io.Writer, userID uint) error {
+	p := &promWriter{w: bufio.NewWriter(out)}
+
+	servers, err := models.GetServersByUserID(userID)
+	if err != nil {
+		return err
+	}
+	sort.Slice(servers, func(i, j int) bool { return servers[i].Name < servers[j].Name })
+
+	// Host metrics, from the collector's latest sample
+	snapshot := GetSystemSnapshot()
+	if !snapshot.UpdatedAt.IsZero() {
+		p.family("host_cpu_usage_percent", "gauge", "Host CPU usage in percent.")
+		p.sample("host_cpu_usage_percent", snapshot.CPUPercent)
+
+		p.family("host_cpu_core_usage_percent", "gauge", "Per-core CPU usage in percent.")
+		for i, usage := range snapshot.PerCore {
+			p.sample("host_cpu_core_usage_percent", usage, "core", strconv.Itoa(i))
+		}
+
+		p.family("host_cpu_mode_percent", "gauge", "Share of CPU time spent in each mode in percent.")
+		modes := []struct {
+			name  string
+			value float64
+		}{
+			{"user", snapshot.Breakdown.User},
+			{"nice", snapshot.Breakdown.Nice},
+			{"system", snapshot.Breakdown.System},
+			{"idle", snapshot.Breakdown.Idle},
+			{"iowait", snapshot.Breakdown.IOWait},
+			{"irq", snapshot.Breakdown.IRQ},
+			{"softirq", snapshot.Breakdown.SoftIRQ},
+			{"steal", snapshot.Breakdown.Steal},
+		}
+		for _, mode := range modes {
+			p.sample("host_cpu_mode_percent", mode.value, "mode", mode.name)
+		}
+
+		p.family("host_load_average", "gauge", "Host load average.")
+		p.sample("host_load_average", snapshot.Load.Load1, "period", "1m")
+		p.sample("host_load_average", snapshot.Load.Load5, "period", "5m")
+		p.sample("host_load_average", snapshot.Load.Load15, "period", "15m")
+
+		p.family("host_memory_total_bytes", "gauge", "Total host memory in bytes.")
+		p.sample("host_memory_total_bytes", float64(snapshot.Memory.Total))
+		p.family("host_memory_used_bytes", "gauge", "Used host memory in bytes.")
+		p.sample("host_memory_used_bytes", float64(snapshot.Memory.Used))
+
+		p.family("host_swap_total_bytes", "gauge", "Total swap space in bytes.")
+		p.sample("host_swap_total_bytes", float64(snapshot.Swap.Total))
+		p.family("host_swap_used_bytes", "gauge", "Used swap space in bytes.")
+		p.sample("host_swap_used_bytes", float64(snapshot.Swap.Used))
+
+		diskPath := DiskStatsPath()
+		p.family("host_disk_total_bytes", "gauge", "Total size of the server folder filesystem in bytes.")
+		p.sample("host_disk_total_bytes", float64(snapshot.Disk.Total), "path", diskPath)
+		p.family("host_disk_used_bytes", "gauge", "Used space on the server folder filesystem in bytes.")
+		p.sample("host_disk_used_bytes", float64(snapshot.Disk.Used), "path", diskPath)
+	}
+
+	// Every real mount
+	if mounts, err := GetMountStats(); err == nil {
+		p.family("filesystem_size_bytes", "gauge", "Filesystem size in bytes.")
+		for _, m := range mounts {
+			p.sample("filesystem_size_bytes", float64(m.Total), "device", m.Device, "mountpoint", m.MountPoint, "fstype", m.FSType)
+		}
+		p.family("filesystem_used_bytes", "gauge", "Used filesystem space in bytes.")
+		for _, m := range mounts {
+			p.sample("filesystem_used_bytes", float64(m.Used), "device", m.Device, "mountpoint", m.MountPoint, "fstype", m.FSType)
+		}
+	}
+
+	// Cached server folder sizes
+	if usages := GetFolderUsage(userID); len(usages) > 0 {
+		p.family("server_folder_bytes", "gauge", "Disk space used by a server folder, per category.")
+		for _, usage := range usages {
+			categories := make([]string, 0, len(usage.Categories))
+			for category := range usage.Categories {
+				categories = append(categories, category)
+			}
+			sort.Strings(categories)
+			for _, category := range categories {
+				p.sample("server_folder_bytes", float64(usage.Categories[category]), "server", usage.Server, "category", category)
+			}
+		}
+	}
+
+	// Per-server metrics
+	snapshots := make([]serverSnapshot, 0, len(servers))
+	for i := range servers {
+		server := &servers[i]
+		entry := serverSnapshot{
+			name:     server.Name,
+			up:       IsServerRunning(server),
+			counters: GetServerCounters(server),
+		}
+		if entry.up {
+			// Reuse the sampler's stats: sampling here would move the CPU
+			// percentage window the dashboard reads
+			entry.stats = GetLastServerStats(server)
+			entry.players = GetPlayerCount(server)
+			entry.clients = GetConsoleClientCount(server)
+		}
+		snapshots = append(snapshots, entry)
+	}
+
+	p.family("server_up", "gauge", "Whether the server process is running (1) or not (0).")
+	for _, s := range snapshots {
+		up := 0.0
+		if s.up {
+			up = 1
+		}
+		p.sample("server_up", up, "server", s.name)
+	}
+
+	p.family("server_starts_total", "counter", "Server starts since the controller started.")
+	for _, s := range snapshots {
+		p.sample("server_starts_total", float64(s.counters.Starts), "server", s.name)
+	}
+
+	p.family("server_restarts_total", "counter", "Server restarts since the controller started.")
+	for _, s := range snapshots {
+		p.sample("server_restarts_total", float64(s.counters.Restarts), "server", s.name)
+	}
+
+	p.family("server_crashes_total", "counter", "Unexpected server exits since the controller started.")
+	for _, s := range snapshots {
+		p.sample("server_crashes_total", float64(s.counters.Crashes), "server", s.name)
+	}
+
+	// The remaining metrics only exist while a server is running
+	running := make([]serverSnapshot, 0, len(snapshots))
+	for _, s := range snapshots {
+		if s.up && s.stats != nil {
+			running = append(running, s)
+		}
+	}
+
+	gauges := []struct {
+		name  string
+		help  string
+		value func(s serverSnapshot) float64
+	}{
+		{"server_memory_rss_bytes", "Resident memory of the server process tree in bytes.",
+			func(s serverSnapshot) float64 { return s.stats.MemoryMB * 1024 * 1024 }},
+		{"server_cpu_usage_percent", "CPU usage of the server process tree in percent of one core.",
+			func(s serverSnapshot) float64 { return s.stats.CPUPercent }},
+		{"server_uptime_seconds", "Seconds since the server process started.",
+			func(s serverSnapshot) float64 { return s.stats.Uptime }},
+		{"server_threads", "Threads in the server process tree.",
+			func(s serverSnapshot) float64 { return float64(s.stats.Threads) }},
+		{"server_open_files", "Open file descriptors in the server process tree.",
+			func(s serverSnapshot) float64 { return float64(s.stats.OpenFiles) }},
+		{"server_players_online", "Players currently online.",
+			func(s serverSnapshot) float64 { return float64(s.players) }},
+		{"server_websocket_clients", "WebSocket clients watching the server console.",
+			func(s serverSnapshot) float64 { return float64(s.clients) }},
+	}
+	for _, g := range gauges {
+		p.family(g.name, "gauge", g.help)
+		for _, s := range running {
+			p.sample(g.name, g.value(s), "server", s.name)
+		}
+	}
+
+	counters := []struct {
+		name  string
+		help  string
+		value func(s serverSnapshot) float64
+	}{
+		{"server_cpu_seconds_total", "CPU time used by the server process tree, including exited children, in seconds.",
+			func(s serverSnapshot) float64 { return s.stats.CPUSeconds }},
+		{"server_read_bytes_total", "Bytes read from storage by the server process tree.",
+			func(s serverSnapshot) float64 { return float64(s.stats.ReadBytes) }},
+		{"server_written_bytes_total", "Bytes written to storage by the server process tree.",
+			func(s serverSnapshot) float64 { return float64(s.stats.WriteBytes) }},
+	}
+	for _, c := range counters {
+		p.family(c.name, "counter", c.help)
+		for _, s := range running {
+			p.sample(c.name, c.value(s), "server", s.name)
+		}
+	}
+
+	return p.w.Flush()
+}
@@ -0,0 +1,222 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"minecraft-server-controller/models"
+)
+
+// metricsSampleInterval is how often raw samples are recorded
+const metricsSampleInterval = 10 * time.Second
+
+// MetricsTier is one level of the rollup chain: samples are stored at
+// Resolution seconds per bucket and kept for Retention
+type MetricsTier struct {
+	Resolution int
+	Retention  time.Duration
+}
+
+// MetricsTiers is the retention policy, finest first. Each tier is rolled
+// up from the one before it.
+var MetricsTiers = []MetricsTier{
+	{Resolution: int(metricsSampleInterval / time.Second), Retention: 6 * time.Hour},
+	{Resolution: 60, Retention: 48 * time.Hour},
+	{Resolution: 15 * 60, Retention: 14 * 24 * time.Hour},
+	{Resolution: 60 * 60, Retention: 90 * 24 * time.Hour},
+}
+
+// rollupCursor holds, per tier, the end of the last range that was rolled up
+var rollupCursor = make([]int64, len(MetricsTiers))
+
+// StartMetricsSampler starts the background goroutine that records system
+// and per-server metrics and maintains the rollups
+func StartMetricsSampler() {
+	initRollupCursors()
+
+	go func() {
+		ticker := time.NewTicker(metricsSampleInterval)
+		defer ticker.Stop()
+
+		lastPrune := time.Time{}
+		for now := range ticker.C {
+			if err := models.RecordMetricSamples(collectMetricSamples(now)); err != nil {
+				log.Printf("⚠️  Failed to record metrics: %v", err)
+			}
+
+			rollupMetrics(now)
+
+			if now.Sub(lastPrune) >= time.Hour {
+				pruneMetrics(now)
+				lastPrune = now
+			}
+		}
+	}()
+
+	log.Println("✅ Metrics sampler started")
+}
+
+// collectMetricSamples takes one raw sample of every series
+func collectMetricSamples(now time.Time) []models.MetricSample {
+	ts := now.Unix()
+	samples := []models.MetricSample{}
+	add := func(series string, value float64) {
+		samples = append(samples, models.MetricSample{
+			Series:     series,
+			Resolution: MetricsTiers[0].Resolution,
+			Timestamp:  ts,
+			Value:      value,
+			Min:        value,
+			Max:        value,
+			Count:      1,
+		})
+	}
+
+	// System metrics, from the collector's latest sample
+	if snapshot := GetSystemSnapshot(); !snapshot.UpdatedAt.IsZero() {
+		add("system.cpu_percent", snapshot.CPUPercent)
+		add("system.cpu_iowait_percent", snapshot.Breakdown.IOWait)
+		add("system.load1", snapshot.Load.Load1)
+		add("system.memory_percent", snapshot.Memory.UsedPercent)
+		add("system.memory_used_bytes", float64(snapshot.Memory.Used))
+		add("system.swap_used_bytes", float64(snapshot.Swap.Used))
+		add("system.disk_percent", snapshot.Disk.UsedPercent)
+		add("system.disk_used_bytes", float64(snapshot.Disk.Used))
+	}
+
+	// Per-server metrics, only while a server is running
+	servers, err := models.GetAllServers()
+	if err != nil {
+		return samples
+	}
+	for i := range servers {
+		server := &servers[i]
+		if !IsServerRunning(server) {
+			continue
+		}
+
+		stats, err := GetServerStats(server)
+		if err != nil {
+			continue
+		}
+
+		// Keep in step with ServerMetrics
+		add(ServerMetricSeries(server.Name, "memory_mb"), stats.MemoryMB)
+		add(ServerMetricSeries(server.Name, "cpu_percent"), stats.CPUPercent)
+		add(ServerMetricSeries(server.Name, "players"), float64(GetPlayerCount(server)))
+	}
+
+	return samples
+}
+
+// ServerMetrics are the metrics sampled for every running server
+var ServerMetrics = []string{"memory_mb", "cpu_percent", "players"}
+
+// ServerMetricSeries returns the series name of a per-server metric
+func ServerMetricSeries(serverName, metric string) string {
+	return fmt.Sprintf("server.%s.%s", serverName, metric)
+}
+
+// ServerMetricSeriesNames returns the names of all series of a server.
+// Names may contain dots, so series are matched by exact name and never by
+// a prefix, which would also match a server named like "<name>.other".
+func ServerMetricSeriesNames(serverName string) []string {
+	names := make([]string, len(ServerMetrics))
+	for i, metric := range ServerMetrics {
+		names[i] = ServerMetricSeries(serverName, metric)
+	}
+	return names
+}
+
+// VisibleMetricSeries filters series down to those a user may read: the
+// system series and the series of the user's own servers
+func VisibleMetricSeries(userID uint, series []string) []string {
+	servers, err := models.GetServersByUserID(userID)
+	if err != nil {
+		return []string{}
+	}
+
+	owned := make(map[string]bool)
+	for _, server := range servers {
+		for _, name := range ServerMetricSeriesNames(server.Name) {
+			owned[name] = true
+		}
+	}
+
+	visible := []string{}
+	for _, name := range series {
+		if strings.HasPrefix(name, "system.") || owned[name] {
+			visible = append(visible, name)
+		}
+	}
+	return visible
+}
+
+// initRollupCursors resumes rollups from what is already stored
+func initRollupCursors() {
+	now := time.Now().Unix()
+	for i := 1; i < len(MetricsTiers); i++ {
+		res := int64(MetricsTiers[i].Resolution)
+		if latest, ok := models.LatestMetricTimestamp(MetricsTiers[i].Resolution); ok {
+			rollupCursor[i] = latest + res
+		} else {
+			// Nothing rolled up yet: start from the oldest source data we keep
+			start := now - int64(MetricsTiers[i-1].Retention/time.Second)
+			rollupCursor[i] = start - start%res
+		}
+	}
+}
+
+// rollupMetrics aggregates every completed bucket into the coarser tiers
+func rollupMetrics(now time.Time) {
+	for i := 1; i < len(MetricsTiers); i++ {
+		res := int64(MetricsTiers[i].Resolution)
+		end := now.Unix() - now.Unix()%res
+
+		if end <= rollupCursor[i] {
+			continue
+		}
+
+		if err := models.RollupMetrics(MetricsTiers[i-1].Resolution, MetricsTiers[i].Resolution, rollupCursor[i], end); err != nil {
+			log.Printf("⚠️  Failed to roll up %ds metrics: %v", res, err)
+			continue
+		}
+		rollupCursor[i] = end
+	}
+}
+
+// pruneMetrics drops samples that fell out of their tier's retention
+func pruneMetrics(now time.Time) {
+	for _, tier := range MetricsTiers {
+		before := now.Add(-tier.Retention).Unix()
+		if err := models.PruneMetrics(tier.Resolution, before); err != nil {
+			log.Printf("⚠️  Failed to prune %ds metrics: %v", tier.Resolution, err)
+		}
+	}
+}
+
+// SelectMetricsTier picks the resolution to answer a query from: the
+// coarsest tier that still covers the start of the range and is no coarser
+// than the requested step
+func SelectMetricsTier(from time.Time, step time.Duration) MetricsTier {
+	now := time.Now()
+
+	var best *MetricsTier
+	for i := range MetricsTiers {
+		tier := &MetricsTiers[i]
+		if now.Add(-tier.Retention).After(from) {
+			continue
+		}
+		if best == nil || time.Duration(tier.Resolution)*time.Second <= step {
+			best = tier
+		}
+	}
+
+	// The range starts before any retention window: use the longest one
+	if best == nil {
+		return MetricsTiers[len(MetricsTiers)-1]
+	}
+	return *best
+}
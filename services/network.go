@@ -0,0 +1,346 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"minecraft-server-controller/models"
+)
+
+// Player info forwarding modes of a proxy
+const (
+	ForwardingNone   = "none"   // backends see the proxy's address
+	ForwardingLegacy = "legacy" // BungeeCord IP forwarding, also used by Velocity's legacy and bungeeguard modes
+	ForwardingModern = "modern" // Velocity modern forwarding, signed with a shared secret
+)
+
+// backendDialTimeout bounds the check of a backend outside the panel
+const backendDialTimeout = time.Second
+
+// ProxyConfig is the part of a proxy's configuration the network view uses
+type ProxyConfig struct {
+	OnlineMode bool           `json:"online_mode"`
+	Forwarding string         `json:"forwarding"`
+	Secret     string         `json:"-"` // modern forwarding secret
+	Backends   []ProxyBackend `json:"backends"`
+}
+
+// ProxyBackend is a backend server a proxy routes players to
+type ProxyBackend struct {
+	Name     string   `json:"name"`    // name in the proxy configuration
+	Address  string   `json:"address"` // host:port
+	ServerID uint     `json:"server_id,omitempty"`
+	Server   string   `json:"server,omitempty"` // matching server of the panel
+	Online   bool     `json:"online"`
+	Checked  bool     `json:"checked"` // whether Online is known; backends outside the panel are only checked on request
+	Issues   []string `json:"issues"`
+}
+
+// ProxyNetwork is a proxy of the panel with its backends and the problems
+// found in their settings
+type ProxyNetwork struct {
+	ProxyID  uint   `json:"proxy_id"`
+	Proxy    string `json:"proxy"`
+	Software string `json:"software"`
+	Online   bool   `json:"online"`
+	ProxyConfig
+	Issues []string `json:"issues"`
+}
+
+// ReadProxyConfig reads the backends and forwarding settings of a Velocity
+// velocity.toml or a BungeeCord config.yml
+func ReadProxyConfig(server *models.Server) (*ProxyConfig, error) {
+	dir := ServerWorkingDir(server)
+	switch server.Software {
+	case models.SoftwareVelocity:
+		return readVelocityConfig(dir)
+	case models.SoftwareBungeeCord:
+		return readBungeeConfig(dir)
+	}
+	return nil, errors.New("server is not a proxy")
+}
+
+// readVelocityConfig reads velocity.toml
+func readVelocityConfig(dir string) (*ProxyConfig, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "velocity.toml"))
+	if err != nil {
+		return nil, err
+	}
+	doc := parseTOML(string(data))
+
+	config := &ProxyConfig{OnlineMode: true, Forwarding: ForwardingNone}
+	if online, ok := doc["online-mode"].(bool); ok {
+		config.OnlineMode = online
+	}
+	switch strings.ToLower(tomlString(doc["player-info-forwarding-mode"])) {
+	case "modern":
+		config.Forwarding = ForwardingModern
+	case "legacy", "bungeeguard":
+		config.Forwarding = ForwardingLegacy
+	}
+
+	// Velocity 3 keeps the secret in a file, older versions inline
+	config.Secret = tomlString(doc["forwarding-secret"])
+	if file := tomlString(doc["forwarding-secret-file"]); file != "" || config.Secret == "" {
+		if file == "" {
+			file = "forwarding.secret"
+		}
+		if secret, err := os.ReadFile(filepath.Join(dir, file)); err == nil {
+			config.Secret = strings.TrimSpace(string(secret))
+		}
+	}
+
+	servers, _ := doc["servers"].(map[string]interface{})
+	for _, name := range sortedKeys(servers) {
+		// "try" lists the servers to connect to, it is no server itself
+		if address := tomlString(servers[name]); name != "try" && address != "" {
+			config.Backends = append(config.Backends, ProxyBackend{Name: name, Address: address})
+		}
+	}
+	return config, nil
+}
+
+// readBungeeConfig reads BungeeCord's config.yml
+func readBungeeConfig(dir string) (*ProxyConfig, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "config.yml"))
+	if err != nil {
+		return nil, err
+	}
+	doc := parseYAML(string(data))
+
+	config := &ProxyConfig{
+		OnlineMode: yamlString(doc["online_mode"]) != "false",
+		Forwarding: ForwardingNone,
+	}
+	if yamlString(doc["ip_forward"]) == "true" {
+		config.Forwarding = ForwardingLegacy
+	}
+
+	servers, _ := doc["servers"].(map[string]interface{})
+	for _, name := range sortedKeys(servers) {
+		settings, _ := servers[name].(map[string]interface{})
+		if address := yamlString(settings["address"]); address != "" {
+			config.Backends = append(config.Backends, ProxyBackend{Name: name, Address: address})
+		}
+	}
+	return config, nil
+}
+
+// ProxyNetworks maps the backends of a user's proxies to their servers and
+// checks that they are up and set up for the proxy's authentication and
+// forwarding. Backends outside the panel are only dialed when checkOutside
+// is set, since each may take up to backendDialTimeout.
+func ProxyNetworks(userID uint, checkOutside bool) []ProxyNetwork {
+	servers, err := models.GetServersByUserID(userID)
+	if err != nil {
+		return nil
+	}
+	sort.Slice(servers, func(i, j int) bool { return servers[i].Name < servers[j].Name })
+
+	// Backends are matched to the game port of the servers on this host
+	byPort := make(map[int]*models.Server)
+	for i := range servers {
+		server := &servers[i]
+		if server.Archived || models.IsProxySoftware(server.Software) {
+			continue
+		}
+		if ports := ServerPorts(server); len(ports) > 0 {
+			byPort[ports[0].Port] = server
+		}
+	}
+	local := localAddresses()
+
+	// Backends outside the panel are dialed at once across all proxies
+	var wg sync.WaitGroup
+	networks := []ProxyNetwork{}
+	for i := range servers {
+		proxy := &servers[i]
+		if proxy.Archived || !models.IsProxySoftware(proxy.Software) {
+			continue
+		}
+
+		network := ProxyNetwork{
+			ProxyID:  proxy.ID,
+			Proxy:    proxy.Name,
+			Software: proxy.Software,
+			Online:   IsServerRunning(proxy),
+			Issues:   []string{},
+		}
+		config, err := ReadProxyConfig(proxy)
+		if err != nil {
+			network.Issues = append(network.Issues, "cannot read the proxy configuration: "+err.Error())
+			networks = append(networks, network)
+			continue
+		}
+		network.ProxyConfig = *config
+
+		if !config.OnlineMode {
+			network.Issues = append(network.Issues, "online-mode is off, so players are not authenticated with Mojang")
+		}
+		if config.Forwarding == ForwardingNone {
+			network.Issues = append(network.Issues, "player info forwarding is off, so backends see every player with the proxy's address and an offline UUID")
+		}
+		if config.Forwarding == ForwardingModern && config.Secret == "" {
+			network.Issues = append(network.Issues, "modern forwarding is on but the forwarding secret is empty")
+		}
+
+		for j := range network.Backends {
+			backend := &network.Backends[j]
+			backend.Issues = []string{}
+
+			host, portText, err := net.SplitHostPort(backend.Address)
+			port, _ := strconv.Atoi(portText)
+			if err != nil {
+				backend.Issues = append(backend.Issues, "invalid address")
+				continue
+			}
+
+			server := byPort[port]
+			if server == nil || !isLocalHost(host, local) {
+				// Not a server of the panel: only whether it is up can be checked
+				if !checkOutside {
+					continue
+				}
+				backend.Checked = true
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if conn, err := net.DialTimeout("tcp", backend.Address, backendDialTimeout); err == nil {
+						conn.Close()
+						backend.Online = true
+					} else {
+						backend.Issues = append(backend.Issues, "not a server of the panel and not reachable")
+					}
+				}()
+				continue
+			}
+
+			backend.ServerID = server.ID
+			backend.Server = server.Name
+			backend.Checked = true
+			backend.Online = IsServerRunning(server)
+			if !backend.Online {
+				backend.Issues = append(backend.Issues, "server is down")
+			}
+			backend.Issues = append(backend.Issues, checkBackendSettings(server, config)...)
+		}
+
+		networks = append(networks, network)
+	}
+	wg.Wait()
+	return networks
+}
+
+// checkBackendSettings compares the authentication and forwarding settings
+// of a backend with those of its proxy
+func checkBackendSettings(server *models.Server, config *ProxyConfig) []string {
+	issues := []string{}
+	dir := ServerWorkingDir(server)
+
+	props, _ := ReadProperties(ServerPropertiesFile(dir))
+	if props["online-mode"] != "false" {
+		issues = append(issues, "online-mode is on in server.properties; backends behind a proxy must leave authentication to it")
+	}
+
+	// Only the Bukkit family is known to read the forwarding settings below
+	switch server.Software {
+	case models.SoftwarePaper, models.SoftwarePurpur, models.SoftwareSpigot:
+	default:
+		return issues
+	}
+
+	spigot := parseYAMLFile(filepath.Join(dir, "spigot.yml"))
+	bungeecord := yamlString(yamlPath(spigot, "settings", "bungeecord")) == "true"
+
+	switch config.Forwarding {
+	case ForwardingLegacy:
+		if !bungeecord {
+			issues = append(issues, "settings.bungeecord is off in spigot.yml, but the proxy forwards player info the BungeeCord way")
+		}
+	case ForwardingModern:
+		if server.Software == models.SoftwareSpigot {
+			issues = append(issues, "Spigot does not support modern forwarding; use Paper or the legacy mode")
+			break
+		}
+		enabled, onlineMode, secret := paperVelocitySettings(dir)
+		switch {
+		case !enabled:
+			issues = append(issues, "Velocity support is off in the Paper configuration, but the proxy uses modern forwarding")
+		case secret != config.Secret:
+			issues = append(issues, "the Velocity secret in the Paper configuration does not match the proxy's forwarding secret")
+		}
+		if enabled && onlineMode != config.OnlineMode {
+			issues = append(issues, fmt.Sprintf("Velocity online-mode is %t in the Paper configuration but %t on the proxy", onlineMode, config.OnlineMode))
+		}
+		if bungeecord {
+			issues = append(issues, "settings.bungeecord is on in spigot.yml, which conflicts with modern forwarding")
+		}
+	}
+	return issues
+}
+
+// paperVelocitySettings reads Paper's Velocity support settings from
+// config/paper-global.yml, or from paper.yml before Paper 1.19
+func paperVelocitySettings(dir string) (enabled, onlineMode bool, secret string) {
+	var settings map[string]interface{}
+	if doc := parseYAMLFile(filepath.Join(dir, "config", "paper-global.yml")); doc != nil {
+		settings, _ = yamlPath(doc, "proxies", "velocity").(map[string]interface{})
+	} else if doc := parseYAMLFile(filepath.Join(dir, "paper.yml")); doc != nil {
+		settings, _ = yamlPath(doc, "settings", "velocity-support").(map[string]interface{})
+	}
+	return yamlString(settings["enabled"]) == "true", yamlString(settings["online-mode"]) == "true", yamlString(settings["secret"])
+}
+
+// parseYAMLFile parses a YAML file, or returns nil when it cannot be read
+func parseYAMLFile(path string) map[string]interface{} {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	return parseYAML(string(data))
+}
+
+// yamlPath returns the value at a path of nested maps
+func yamlPath(doc map[string]interface{}, keys ...string) interface{} {
+	var value interface{} = doc
+	for _, key := range keys {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = m[key]
+	}
+	return value
+}
+
+// localAddresses returns the host name and interface addresses of this host
+func localAddresses() map[string]bool {
+	addresses := map[string]bool{"localhost": true}
+	if name, err := os.Hostname(); err == nil {
+		addresses[strings.ToLower(name)] = true
+	}
+	if addrs, err := net.InterfaceAddrs(); err == nil {
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok {
+				addresses[ipNet.IP.String()] = true
+			}
+		}
+	}
+	return addresses
+}
+
+// isLocalHost reports whether a backend host is this host
+func isLocalHost(host string, addresses map[string]bool) bool {
+	if ip := net.ParseIP(host); ip != nil && (ip.IsLoopback() || ip.IsUnspecified()) {
+		return true
+	}
+	return addresses[strings.ToLower(host)]
+}
@@ -0,0 +1,156 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"minecraft-server-controller/config"
+	"minecraft-server-controller/models"
+)
+
+// Notifier delivers alert notifications through one channel
+type Notifier interface {
+	Name() string
+	Notify(alert *models.Alert) error
+}
+
+// WebhookNotifier posts alerts as JSON to a URL
+type WebhookNotifier struct {
+	URL    string
+	Client *http.Client
+}
+
+// NewWebhookNotifier creates a webhook notifier with a sensible timeout
+func NewWebhookNotifier(url string) *WebhookNotifier {
+	return &WebhookNotifier{
+		URL:    url,
+		Client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Name returns the channel name
+func (n *WebhookNotifier) Name() string {
+	return "webhook"
+}
+
+// Notify posts the alert to the webhook URL
+func (n *WebhookNotifier) Notify(alert *models.Alert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+
+	resp, err := n.Client.Post(n.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to post webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// SMTPNotifier sends alerts as e-mail
+type SMTPNotifier struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+	To       []string
+}
+
+// Name returns the channel name
+func (n *SMTPNotifier) Name() string {
+	return "smtp"
+}
+
+// Notify sends the alert as a plain text e-mail. Authentication is only used
+// when a username is set; net/smtp refuses to send credentials over an
+// unencrypted connection to anything but localhost.
+func (n *SMTPNotifier) Notify(alert *models.Alert) error {
+	addr := net.JoinHostPort(n.Host, strconv.Itoa(n.Port))
+
+	var auth smtp.Auth
+	if n.Username != "" {
+		auth = smtp.PlainAuth("", n.Username, n.Password, n.Host)
+	}
+
+	subject := fmt.Sprintf("[%s] %s: %s", strings.ToUpper(alert.Severity), alert.Status, alert.Message)
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", n.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(n.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	fmt.Fprintf(&msg, "Rule: %s\r\n", alert.RuleID)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", alert.Subject)
+	fmt.Fprintf(&msg, "Severity: %s\r\n", alert.Severity)
+	fmt.Fprintf(&msg, "Status: %s\r\n", alert.Status)
+	fmt.Fprintf(&msg, "Value: %.2f\r\n", alert.Value)
+	fmt.Fprintf(&msg, "Fired at: %s\r\n", alert.FiredAt.Format(time.RFC1123))
+	if alert.ResolvedAt != nil {
+		fmt.Fprintf(&msg, "Resolved at: %s\r\n", alert.ResolvedAt.Format(time.RFC1123))
+	}
+	fmt.Fprintf(&msg, "\r\n%s\r\n", alert.Message)
+
+	if err := smtp.SendMail(addr, auth, n.From, n.To, msg.Bytes()); err != nil {
+		return fmt.Errorf("failed to send e-mail: %w", err)
+	}
+
+	return nil
+}
+
+// ConfiguredNotifiers returns a notifier for every configured channel
+func ConfiguredNotifiers() []Notifier {
+	settings := config.GetNotificationConfig()
+	notifiers := []Notifier{}
+
+	if settings.WebhookURL != "" {
+		notifiers = append(notifiers, NewWebhookNotifier(settings.WebhookURL))
+	}
+
+	if settings.SMTP.Host != "" && settings.SMTP.From != "" && len(settings.SMTP.To) > 0 {
+		port := settings.SMTP.Port
+		if port == 0 {
+			port = 25
+		}
+		notifiers = append(notifiers, &SMTPNotifier{
+			Host:     settings.SMTP.Host,
+			Port:     port,
+			Username: settings.SMTP.Username,
+			Password: settings.SMTP.Password,
+			From:     settings.SMTP.From,
+			To:       settings.SMTP.To,
+		})
+	}
+
+	return notifiers
+}
+
+// NotifyAll sends an alert through every configured channel. It returns how
+// many channels accepted it and the error of each channel that failed, keyed
+// by channel name.
+func NotifyAll(alert *models.Alert) (int, map[string]error) {
+	sent := 0
+	failures := make(map[string]error)
+	for _, notifier := range ConfiguredNotifiers() {
+		if err := notifier.Notify(alert); err != nil {
+			failures[notifier.Name()] = err
+			continue
+		}
+		sent++
+	}
+	return sent, failures
+}
@@ -0,0 +1,277 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"minecraft-server-controller/models"
+)
+
+// Kinds of lifecycle operations
+const (
+	OperationStart   = "start"
+	OperationStop    = "stop"
+	OperationRestart = "restart"
+)
+
+// States of a lifecycle operation
+const (
+	OperationRunning   = "running"
+	OperationSucceeded = "succeeded"
+	OperationFailed    = "failed"
+)
+
+// maxOperations is how many operations are remembered, oldest finished first
+const maxOperations = 200
+
+// ErrServerBusy is returned when another lifecycle operation of the server
+// is still running
+var ErrServerBusy = errors.New("another start, stop or restart of this server is still in progress")
+
+// Operation is a start, stop or restart running in the background, or a
+// bulk action on one server
+type Operation struct {
+	ID         string     `json:"id"`
+	Kind       string     `json:"kind"`
+	ServerID   uint       `json:"server_id"`
+	Server     string     `json:"server"`
+	State      string     `json:"state"`
+	Step       string     `json:"step"`
+	Error      string     `json:"error,omitempty"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	userID     uint
+}
+
+// Finished reports whether the operation succeeded or failed
+func (op *Operation) Finished() bool {
+	return op.State != OperationRunning
+}
+
+var (
+	operations     = make(map[string]*Operation)
+	operationOrder []string
+	operationMux   sync.Mutex
+
+	// operationListeners receive a copy of every operation update, keyed by
+	// the user whose operations they get
+	operationListeners = make(map[chan Operation]uint)
+
+	lifecycleLocks   = make(map[uint]*sync.Mutex)
+	lifecycleLockMux sync.Mutex
+)
+
+// lifecycleLock returns the lock serializing the starts, stops and restarts
+// of one server, so a slow stop only holds up its own server
+func lifecycleLock(serverID uint) *sync.Mutex {
+	lifecycleLockMux.Lock()
+	defer lifecycleLockMux.Unlock()
+
+	lock, exists := lifecycleLocks[serverID]
+	if !exists {
+		lock = &sync.Mutex{}
+		lifecycleLocks[serverID] = lock
+	}
+	return lock
+}
+
+// acquireLifecycleLock takes the lifecycle lock of a server for an operation
+// without waiting for another one, failing with ErrServerBusy. A stop
+// cancels a start that still waits for its dependencies and takes over once
+// the start gave up.
+func acquireLifecycleLock(serverID uint, kind string) (*sync.Mutex, error) {
+	lock := lifecycleLock(serverID)
+	if lock.TryLock() {
+		return lock, nil
+	}
+	if kind == OperationStop && cancelDependencyWait(serverID) {
+		lock.Lock()
+		return lock, nil
+	}
+	return nil, ErrServerBusy
+}
+
+// noProgress ignores the steps of a lifecycle action
+func noProgress(step string) {}
+
+// newOperationID creates a random operation ID
+func newOperationID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// StartOperation starts, stops or restarts a server in the background and
+// returns the operation tracking it. It fails right away with ErrServerBusy
+// when an operation of the server is already running.
+func StartOperation(kind string, server *models.Server) (*Operation, error) {
+	var run func(progress func(step string)) error
+	switch kind {
+	case OperationStart:
+		run = func(progress func(step string)) error {
+			return startServer(server, progress)
+		}
+	case OperationStop:
+		run = func(progress func(step string)) error {
+			return stopServer(server, stopReasonStop, progress)
+		}
+	case OperationRestart:
+		run = func(progress func(step string)) error {
+			return restartServer(server, progress)
+		}
+	default:
+		return nil, fmt.Errorf("unknown operation: %s", kind)
+	}
+
+	lock, err := acquireLifecycleLock(server.ID, kind)
+	if err != nil {
+		return nil, err
+	}
+
+	op := newOperation(kind, server)
+	go func() {
+		defer lock.Unlock()
+		finishOperation(op, "", run(op.progress))
+	}()
+
+	return op, nil
+}
+
+// newOperation remembers a queued operation of a server
+func newOperation(kind string, server *models.Server) *Operation {
+	op := &Operation{
+		ID:        newOperationID(),
+		Kind:      kind,
+		ServerID:  server.ID,
+		Server:    server.Name,
+		State:     OperationRunning,
+		Step:      "Queued",
+		StartedAt: time.Now().UTC(),
+		userID:    server.UserID,
+	}
+	addOperation(op)
+	return op
+}
+
+// progress records the current step of an operation
+func (op *Operation) progress(step string) {
+	updateOperation(op, func() { op.Step = step })
+}
+
+// finishOperation records how an operation ended; a message replaces the
+// final step of a successful one
+func finishOperation(op *Operation, message string, err error) {
+	updateOperation(op, func() {
+		now := time.Now().UTC()
+		op.FinishedAt = &now
+		if err != nil {
+			op.State = OperationFailed
+			op.Error = err.Error()
+			return
+		}
+		op.State = OperationSucceeded
+		op.Step = "Done"
+		if message != "" {
+			op.Step = message
+		}
+	})
+	if err != nil {
+		log.Printf("⚠️  Failed to %s server '%s': %v", op.Kind, op.Server, err)
+	}
+}
+
+// addOperation remembers a new operation, forgetting the oldest finished
+// ones beyond maxOperations
+func addOperation(op *Operation) {
+	operationMux.Lock()
+	operations[op.ID] = op
+	operationOrder = append(operationOrder, op.ID)
+	for i := 0; len(operationOrder) > maxOperations && i < len(operationOrder); {
+		if old := operations[operationOrder[i]]; old.Finished() {
+			delete(operations, old.ID)
+			operationOrder = append(operationOrder[:i], operationOrder[i+1:]...)
+			continue
+		}
+		i++
+	}
+	operationMux.Unlock()
+
+	notifyOperation(op)
+}
+
+// updateOperation changes an operation under the lock and sends the result
+// to the listeners
+func updateOperation(op *Operation, change func()) {
+	operationMux.Lock()
+	change()
+	operationMux.Unlock()
+
+	notifyOperation(op)
+}
+
+// notifyOperation sends a copy of an operation to the listeners of its
+// user. Slow listeners miss updates rather than hold up the operation.
+func notifyOperation(op *Operation) {
+	operationMux.Lock()
+	defer operationMux.Unlock()
+
+	for ch, userID := range operationListeners {
+		if userID != op.userID {
+			continue
+		}
+		select {
+		case ch <- *op:
+		default:
+		}
+	}
+}
+
+// GetOperation returns a copy of an operation of a user
+func GetOperation(id string, userID uint) (Operation, bool) {
+	operationMux.Lock()
+	defer operationMux.Unlock()
+
+	op, exists := operations[id]
+	if !exists || op.userID != userID {
+		return Operation{}, false
+	}
+	return *op, true
+}
+
+// ListOperations returns the operations of a user, newest first, optionally
+// only those of one server
+func ListOperations(userID uint, serverID uint) []Operation {
+	operationMux.Lock()
+	defer operationMux.Unlock()
+
+	list := []Operation{}
+	for i := len(operationOrder) - 1; i >= 0; i-- {
+		op := operations[operationOrder[i]]
+		if op.userID != userID || (serverID != 0 && op.ServerID != serverID) {
+			continue
+		}
+		list = append(list, *op)
+	}
+	return list
+}
+
+// SubscribeOperations returns a channel receiving the operation updates of
+// a user, and a function to stop receiving them
+func SubscribeOperations(userID uint) (<-chan Operation, func()) {
+	ch := make(chan Operation, 32)
+
+	operationMux.Lock()
+	operationListeners[ch] = userID
+	operationMux.Unlock()
+
+	return ch, func() {
+		operationMux.Lock()
+		delete(operationListeners, ch)
+		operationMux.Unlock()
+	}
+}
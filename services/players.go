@@ -0,0 +1,77 @@
+package services
+
+import (
+	"regexp"
+	"sort"
+	"time"
+
+	"minecraft-server-controller/models"
+)
+
+var (
+	// Vanilla/Bukkit style: "[12:00:00] [Server thread/INFO]: Steve joined the game"
+	playerJoinPattern  = regexp.MustCompile(`:\s([A-Za-z0-9_]{1,16}) joined the game`)
+	playerLeavePattern = regexp.MustCompile(`:\s([A-Za-z0-9_]{1,16}) left the game`)
+
+	// Velocity style: "[connected player] Steve (/127.0.0.1:51234) has connected"
+	proxyJoinPattern  = regexp.MustCompile(`\[connected player\] ([A-Za-z0-9_]{1,16}) \(.*\) has connected`)
+	proxyLeavePattern = regexp.MustCompile(`\[connected player\] ([A-Za-z0-9_]{1,16}) \(.*\) has disconnected`)
+)
+
+// trackPlayers updates the online player list from a console line.
+// It returns the player name and whether they joined (true) or left (false);
+// the name is empty when the line is not a join/leave message.
+func (sp *ServerProcess) trackPlayers(line string) (string, bool) {
+	var name string
+	joined := false
+
+	if m := playerJoinPattern.FindStringSubmatch(line); m != nil {
+		name, joined = m[1], true
+	} else if m := proxyJoinPattern.FindStringSubmatch(line); m != nil {
+		name, joined = m[1], true
+	} else if m := playerLeavePattern.FindStringSubmatch(line); m != nil {
+		name = m[1]
+	} else if m := proxyLeavePattern.FindStringSubmatch(line); m != nil {
+		name = m[1]
+	}
+
+	if name == "" {
+		return "", false
+	}
+
+	sp.PlayerMux.Lock()
+	if joined {
+		sp.Players[name] = time.Now()
+	} else {
+		delete(sp.Players, name)
+	}
+	sp.PlayerMux.Unlock()
+
+	return name, joined
+}
+
+// GetOnlinePlayers returns the names of players currently online, sorted
+func GetOnlinePlayers(server *models.Server) []string {
+	serverMux.Lock()
+	sp, exists := runningServers[server.ID]
+	serverMux.Unlock()
+
+	if !exists {
+		return []string{}
+	}
+
+	sp.PlayerMux.Lock()
+	defer sp.PlayerMux.Unlock()
+
+	players := make([]string, 0, len(sp.Players))
+	for name := range sp.Players {
+		players = append(players, name)
+	}
+	sort.Strings(players)
+	return players
+}
+
+// GetPlayerCount returns the number of players currently online
+func GetPlayerCount(server *models.Server) int {
+	return len(GetOnlinePlayers(server))
+}
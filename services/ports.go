@@ -0,0 +1,513 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"minecraft-server-controller/models"
+)
+
+// Port protocols
+const (
+	ProtocolTCP = "tcp"
+	ProtocolUDP = "udp"
+)
+
+// Default ports of the game and the proxies
+const (
+	DefaultServerPort = 25565
+	DefaultRCONPort   = 25575
+	DefaultProxyPort  = 25577
+)
+
+// ServerPort is a port a server listens on
+type ServerPort struct {
+	Port     int    `json:"port"`
+	Protocol string `json:"protocol"`
+	Source   string `json:"source"` // the setting the port comes from
+}
+
+// PortConflict is a port of a server that is already taken
+type PortConflict struct {
+	ServerPort
+	Owner   string `json:"owner"`   // the server using the port, empty when another process on the host holds it
+	Running bool   `json:"running"` // whether the owner is running; conflicts with stopped servers only matter once both run
+
+	ownerID uint // the ID of the owning server, 0 for the host
+}
+
+// Message describes the conflict for display
+func (c *PortConflict) Message() string {
+	switch {
+	case c.Owner == "":
+		return fmt.Sprintf("port %d/%s (%s) is already in use on the host", c.Port, c.Protocol, c.Source)
+	case c.Running:
+		return fmt.Sprintf("port %d/%s (%s) is used by running server '%s'", c.Port, c.Protocol, c.Source, c.Owner)
+	}
+	return fmt.Sprintf("port %d/%s (%s) is also used by server '%s'", c.Port, c.Protocol, c.Source, c.Owner)
+}
+
+var (
+	// velocityBindPattern matches the bind setting of velocity.toml
+	velocityBindPattern = regexp.MustCompile(`(?m)^\s*bind\s*=\s*"[^"]*:(\d+)"`)
+	// hoconPortPattern matches the port setting of BlueMap's webserver.conf
+	hoconPortPattern = regexp.MustCompile(`(?m)^\s*port\s*[:=]\s*(\d+)`)
+)
+
+// ServerPorts reads the ports a server listens on from server.properties,
+// the launch profile, proxy configuration and known plugins
+func ServerPorts(server *models.Server) []ServerPort {
+	dir := ServerWorkingDir(server)
+	ports := []ServerPort{}
+	add := func(port int, protocol, source string) {
+		if port > 0 && port <= 65535 {
+			ports = append(ports, ServerPort{Port: port, Protocol: protocol, Source: source})
+		}
+	}
+
+	switch server.Software {
+	case models.SoftwareVelocity:
+		port := DefaultProxyPort
+		if data, err := os.ReadFile(filepath.Join(dir, "velocity.toml")); err == nil {
+			if match := velocityBindPattern.FindSubmatch(data); match != nil {
+				port, _ = strconv.Atoi(string(match[1]))
+			}
+		}
+		add(port, ProtocolTCP, "velocity.toml bind")
+		return ports
+	case models.SoftwareBungeeCord:
+		for _, port := range bungeeListenerPorts(dir) {
+			add(port, ProtocolTCP, "config.yml listener")
+		}
+		return ports
+	}
+
+	props, err := ReadProperties(ServerPropertiesFile(dir))
+	if err != nil {
+		props = map[string]string{}
+	}
+
+	serverPort := propertyPort(props, "server-port", DefaultServerPort)
+	if server.UsesLaunchProfile() && server.LaunchProfile.Port > 0 {
+		add(server.LaunchProfile.Port, ProtocolTCP, "--port")
+	} else {
+		add(serverPort, ProtocolTCP, "server-port")
+	}
+	if props["enable-query"] == "true" {
+		add(propertyPort(props, "query.port", serverPort), ProtocolUDP, "query.port")
+	}
+	if props["enable-rcon"] == "true" {
+		add(propertyPort(props, "rcon.port", DefaultRCONPort), ProtocolTCP, "rcon.port")
+	}
+
+	for _, port := range pluginPorts(dir) {
+		add(port.Port, port.Protocol, port.Source)
+	}
+	return ports
+}
+
+// propertyPort returns a port from server.properties, or the default
+func propertyPort(props map[string]string, key string, fallback int) int {
+	if port, err := strconv.Atoi(strings.TrimSpace(props[key])); err == nil {
+		return port
+	}
+	return fallback
+}
+
+// bungeeListenerPorts reads the listener ports of a BungeeCord config.yml
+func bungeeListenerPorts(dir string) []int {
+	data, err := os.ReadFile(filepath.Join(dir, "config.yml"))
+	if err != nil {
+		return []int{DefaultProxyPort}
+	}
+
+	ports := []int{}
+	listeners, _ := parseYAML(string(data))["listeners"].([]interface{})
+	for _, listener := range listeners {
+		settings, _ := listener.(map[string]interface{})
+		host := yamlString(settings["host"])
+		if i := strings.LastIndex(host, ":"); i >= 0 {
+			if port, err := strconv.Atoi(host[i+1:]); err == nil {
+				ports = append(ports, port)
+			}
+		}
+	}
+	if len(ports) == 0 {
+		ports = append(ports, DefaultProxyPort)
+	}
+	return ports
+}
+
+// pluginPorts reads the ports of plugins that run their own listeners
+func pluginPorts(dir string) []ServerPort {
+	ports := []ServerPort{}
+
+	// Dynmap's web map
+	if data, err := os.ReadFile(filepath.Join(dir, "plugins", "dynmap", "configuration.txt")); err == nil {
+		doc := parseYAML(string(data))
+		if yamlString(doc["disable-webserver"]) != "true" {
+			port := 8123
+			if p, err := strconv.Atoi(yamlString(doc["webserver-port"])); err == nil {
+				port = p
+			}
+			ports = append(ports, ServerPort{Port: port, Protocol: ProtocolTCP, Source: "Dynmap web server"})
+		}
+	}
+
+	// BlueMap's web map
+	if data, err := os.ReadFile(filepath.Join(dir, "plugins", "BlueMap", "webserver.conf")); err == nil {
+		port := 8100
+		if match := hoconPortPattern.FindSubmatch(data); match != nil {
+			port, _ = strconv.Atoi(string(match[1]))
+		}
+		ports = append(ports, ServerPort{Port: port, Protocol: ProtocolTCP, Source: "BlueMap web server"})
+	}
+
+	// Geyser's Bedrock listener
+	for _, folder := range []string{"Geyser-Spigot", "Geyser-Velocity", "Geyser-BungeeCord"} {
+		data, err := os.ReadFile(filepath.Join(dir, "plugins", folder, "config.yml"))
+		if err != nil {
+			continue
+		}
+		bedrock, _ := parseYAML(string(data))["bedrock"].(map[string]interface{})
+		port := 19132
+		if p, err := strconv.Atoi(yamlString(bedrock["port"])); err == nil {
+			port = p
+		}
+		ports = append(ports, ServerPort{Port: port, Protocol: ProtocolUDP, Source: "Geyser Bedrock listener"})
+	}
+
+	return ports
+}
+
+// portKey identifies a port and protocol in the registry
+func portKey(port int, protocol string) string {
+	return strconv.Itoa(port) + "/" + protocol
+}
+
+// portOwner is a server holding a port in the registry
+type portOwner struct {
+	server  *models.Server
+	running bool
+}
+
+// buildPortRegistry maps every port of every registered server to the
+// servers using it. running tells which servers are running.
+func buildPortRegistry(running map[uint]bool) (map[string][]portOwner, error) {
+	servers, err := models.GetAllServers()
+	if err != nil {
+		return nil, err
+	}
+
+	registry := make(map[string][]portOwner)
+	for i := range servers {
+		server := &servers[i]
+		if server.Missing || server.Archived {
+			continue
+		}
+		for _, port := range ServerPorts(server) {
+			key := portKey(port.Port, port.Protocol)
+			registry[key] = append(registry[key], portOwner{server: server, running: running[server.ID]})
+		}
+	}
+	return registry, nil
+}
+
+// runningServerIDs returns the IDs of running servers. serverMux must be held.
+func runningServerIDs() map[uint]bool {
+	running := make(map[uint]bool, len(runningServers))
+	for id := range runningServers {
+		running[id] = true
+	}
+	return running
+}
+
+// portConflicts checks the ports of a server against the other servers and
+// the host. serverMux must be held.
+func portConflicts(server *models.Server) ([]PortConflict, error) {
+	return findPortConflicts(server, runningServerIDs())
+}
+
+// findPortConflicts checks the ports of a server against the other servers
+// and the host, given the servers that are running
+func findPortConflicts(server *models.Server, running map[uint]bool) ([]PortConflict, error) {
+	registry, err := buildPortRegistry(running)
+	if err != nil {
+		return nil, err
+	}
+
+	conflicts := []PortConflict{}
+	for _, port := range ServerPorts(server) {
+		takenByServer := false
+		for _, owner := range registry[portKey(port.Port, port.Protocol)] {
+			if owner.server.ID == server.ID {
+				continue
+			}
+			conflicts = append(conflicts, PortConflict{ServerPort: port, Owner: owner.server.Name, Running: owner.running, ownerID: owner.server.ID})
+			takenByServer = takenByServer || owner.running
+		}
+
+		// A running server of ours explains a bound port; otherwise ask the host
+		if !takenByServer && !running[server.ID] && !portAvailable(port.Port, port.Protocol) {
+			conflicts = append(conflicts, PortConflict{ServerPort: port, Running: true})
+		}
+	}
+	return conflicts, nil
+}
+
+// PortConflicts checks the ports of a server against the other servers and
+// the ports bound on the host
+func PortConflicts(server *models.Server) ([]PortConflict, error) {
+	serverMux.Lock()
+	defer serverMux.Unlock()
+	return portConflicts(server)
+}
+
+// checkStartPorts returns an error when a port of the server is held by a
+// running server or another process on the host. It reads the configuration
+// of every server and probes ports, so it runs without serverMux; the
+// conflicts with stopped servers it returns are checked again by
+// recheckStartPorts once serverMux is held.
+func checkStartPorts(server *models.Server) ([]PortConflict, error) {
+	serverMux.Lock()
+	running := runningServerIDs()
+	serverMux.Unlock()
+	if running[server.ID] {
+		// launchServer refuses the start under the lock
+		return nil, nil
+	}
+
+	conflicts, err := findPortConflicts(server, running)
+	if err != nil {
+		return nil, err
+	}
+	stopped := []PortConflict{}
+	for _, conflict := range conflicts {
+		if conflict.Running {
+			return nil, errors.New(conflict.Message() + "; assign a free port on the Manage page")
+		}
+		stopped = append(stopped, conflict)
+	}
+	return stopped, nil
+}
+
+// recheckStartPorts returns an error when a server sharing a port with the
+// starting server was started since checkStartPorts. serverMux must be held.
+func recheckStartPorts(conflicts []PortConflict) error {
+	for _, conflict := range conflicts {
+		if _, running := runningServers[conflict.ownerID]; running {
+			conflict.Running = true
+			return errors.New(conflict.Message() + "; assign a free port on the Manage page")
+		}
+	}
+	return nil
+}
+
+// portAvailable reports whether a port can be bound on the host
+func portAvailable(port int, protocol string) bool {
+	address := ":" + strconv.Itoa(port)
+	if protocol == ProtocolUDP {
+		conn, err := net.ListenPacket("udp", address)
+		if err != nil {
+			return false
+		}
+		conn.Close()
+		return true
+	}
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return false
+	}
+	listener.Close()
+	return true
+}
+
+// SuggestPort returns the first port from start up that no registered
+// server uses and that is free on the host
+func SuggestPort(start int) (int, error) {
+	serverMux.Lock()
+	defer serverMux.Unlock()
+	return suggestPort(start, 0, nil)
+}
+
+// suggestPort finds a free port, ignoring the ports of the excluded server
+// and skipping reserved ports. serverMux must be held.
+func suggestPort(start int, exclude uint, reserved map[int]bool) (int, error) {
+	registry, err := buildPortRegistry(runningServerIDs())
+	if err != nil {
+		return 0, err
+	}
+
+	for port := start; port <= 65535; port++ {
+		taken := reserved[port]
+		for _, protocol := range []string{ProtocolTCP, ProtocolUDP} {
+			for _, owner := range registry[portKey(port, protocol)] {
+				if owner.server.ID != exclude {
+					taken = true
+				}
+			}
+		}
+		if !taken && portAvailable(port, ProtocolTCP) && portAvailable(port, ProtocolUDP) {
+			return port, nil
+		}
+	}
+	return 0, errors.New("no free port found")
+}
+
+// AssignFreePort moves a stopped server to a free game port and moves every
+// other port of the server that conflicts, updating server.properties, the
+// launch profile and plugin configuration. It returns the ports assigned,
+// the game port first.
+func AssignFreePort(server *models.Server) ([]ServerPort, error) {
+	if models.IsProxySoftware(server.Software) {
+		return nil, errors.New("proxy ports are set in the proxy configuration")
+	}
+
+	serverMux.Lock()
+	defer serverMux.Unlock()
+
+	if _, running := runningServers[server.ID]; running {
+		return nil, errors.New("server must be stopped first")
+	}
+
+	conflicts, err := portConflicts(server)
+	if err != nil {
+		return nil, err
+	}
+	conflicting := make(map[string]bool)
+	for _, conflict := range conflicts {
+		conflicting[conflict.Source] = true
+	}
+
+	dir := ServerWorkingDir(server)
+	props, _ := ReadProperties(ServerPropertiesFile(dir))
+	// A query port that followed the server port moves along
+	queryFollows := props == nil || props["query.port"] == "" || props["query.port"] == props["server-port"]
+
+	// Ports that stay where they are must not be handed out again
+	current := ServerPorts(server)
+	reserved := make(map[int]bool)
+	for _, port := range current {
+		switch {
+		case port.Source == "server-port" || port.Source == "--port":
+		case port.Source == "query.port" && queryFollows:
+		case !conflicting[port.Source]:
+			reserved[port.Port] = true
+		}
+	}
+
+	port, err := suggestPort(DefaultServerPort, server.ID, reserved)
+	if err != nil {
+		return nil, err
+	}
+	reserved[port] = true
+	assigned := []ServerPort{{Port: port, Protocol: ProtocolTCP, Source: "server-port"}}
+
+	updates := map[string]string{"server-port": strconv.Itoa(port)}
+	if queryFollows {
+		updates["query.port"] = strconv.Itoa(port)
+	}
+	plugins := []ServerPort{}
+	for _, setting := range current {
+		if !conflicting[setting.Source] || setting.Source == "server-port" || setting.Source == "--port" {
+			continue
+		}
+		if setting.Source == "query.port" && queryFollows {
+			continue
+		}
+
+		free, err := suggestPort(setting.Port+1, server.ID, reserved)
+		if err != nil {
+			return nil, err
+		}
+		reserved[free] = true
+		moved := ServerPort{Port: free, Protocol: setting.Protocol, Source: setting.Source}
+		if setting.Source == "query.port" || setting.Source == "rcon.port" {
+			updates[setting.Source] = strconv.Itoa(free)
+		} else {
+			plugins = append(plugins, moved)
+		}
+		assigned = append(assigned, moved)
+	}
+
+	if err := UpdateProperties(ServerPropertiesFile(dir), updates); err != nil {
+		return nil, fmt.Errorf("failed to update server.properties: %w", err)
+	}
+	for _, plugin := range plugins {
+		if err := setPluginPort(dir, plugin.Source, plugin.Port); err != nil {
+			return nil, fmt.Errorf("failed to move the %s: %w", plugin.Source, err)
+		}
+	}
+
+	if server.UsesLaunchProfile() && server.LaunchProfile.Port > 0 {
+		assigned[0].Source = "--port"
+		profile := *server.LaunchProfile
+		profile.Port = port
+		if err := server.UpdateLaunchProfile(&profile, RenderLaunchProfile(&profile), server.Env); err != nil {
+			return nil, err
+		}
+	}
+
+	return assigned, nil
+}
+
+var (
+	// dynmapPortPattern matches the web server port of Dynmap's configuration.txt
+	dynmapPortPattern = regexp.MustCompile(`(?m)^webserver-port\s*:\s*(\d+)`)
+	// geyserPortPattern matches the port in the bedrock section of Geyser's config.yml
+	geyserPortPattern = regexp.MustCompile(`(?m)^bedrock:[^\n]*\n(?:[ \t]*\n|[ \t]+[^\n]*\n)*?[ \t]+port\s*:\s*(\d+)`)
+)
+
+// setPluginPort writes a port reported by pluginPorts back to the plugin's
+// configuration
+func setPluginPort(dir, source string, port int) error {
+	plugins := filepath.Join(dir, "plugins")
+	switch source {
+	case "Dynmap web server":
+		return replacePortSetting(filepath.Join(plugins, "dynmap", "configuration.txt"), dynmapPortPattern, port, "webserver-port: %d\n")
+	case "BlueMap web server":
+		return replacePortSetting(filepath.Join(plugins, "BlueMap", "webserver.conf"), hoconPortPattern, port, "port: %d\n")
+	case "Geyser Bedrock listener":
+		for _, folder := range []string{"Geyser-Spigot", "Geyser-Velocity", "Geyser-BungeeCord"} {
+			path := filepath.Join(plugins, folder, "config.yml")
+			if _, err := os.Stat(path); err != nil {
+				continue
+			}
+			if err := replacePortSetting(path, geyserPortPattern, port, ""); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown port setting %s", source)
+}
+
+// replacePortSetting replaces the port captured by pattern in a file. When
+// the file has no such setting, line is appended instead if given.
+func replacePortSetting(path string, pattern *regexp.Regexp, port int, line string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	if loc := pattern.FindSubmatchIndex(data); loc != nil {
+		updated := append([]byte{}, data[:loc[2]]...)
+		updated = append(updated, strconv.Itoa(port)...)
+		data = append(updated, data[loc[3]:]...)
+	} else if line != "" {
+		if len(data) > 0 && data[len(data)-1] != '\n' {
+			data = append(data, '\n')
+		}
+		data = append(data, fmt.Sprintf(line, port)...)
+	} else {
+		return fmt.Errorf("no port setting found in %s", filepath.Base(path))
+	}
+	return os.WriteFile(path, data, 0644)
+}
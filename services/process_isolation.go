@@ -0,0 +1,244 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"os/user"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"minecraft-server-controller/config"
+	"minecraft-server-controller/models"
+)
+
+// runAs is a resolved user and group a server runs as
+type runAs struct {
+	uid, gid uint32
+	groups   []uint32
+	name     string
+	home     string
+}
+
+// ValidateProcessOptions checks process options before they are stored
+func ValidateProcessOptions(options *models.ProcessOptions) error {
+	if options.User != "" || options.Group != "" {
+		if _, err := resolveRunAs(options); err != nil {
+			return err
+		}
+	}
+	if options.Umask != "" {
+		if _, err := parseUmask(options.Umask); err != nil {
+			return err
+		}
+	}
+	if err := checkNice(options.Nice); err != nil {
+		return err
+	}
+	switch options.IOClass {
+	case models.IOClassDefault, models.IOClassBestEffort, models.IOClassIdle:
+	default:
+		return errors.New("unknown I/O class: " + options.IOClass)
+	}
+	if options.IOLevel < 0 || options.IOLevel > 7 {
+		return errors.New("I/O priority must be between 0 and 7")
+	}
+	return nil
+}
+
+// checkNice checks a nice value against its range and the process isolation
+// settings of the controller
+func checkNice(nice int) error {
+	if nice < -20 || nice > 19 {
+		return errors.New("nice must be between -20 and 19")
+	}
+	if nice < 0 && !config.GetProcessIsolation().AllowNegativeNice {
+		return errors.New("negative nice values are not allowed by the controller configuration")
+	}
+	return nil
+}
+
+// resolveRunAs looks up the user and group of the process options and checks
+// them against the allowlists of the controller configuration. Running as
+// another user needs the controller to run as root.
+func resolveRunAs(options *models.ProcessOptions) (*runAs, error) {
+	if options.User == "" {
+		return nil, errors.New("a group can only be set together with a user")
+	}
+	isolation := config.GetProcessIsolation()
+
+	u, err := user.Lookup(options.User)
+	if err != nil {
+		if u, err = user.LookupId(options.User); err != nil {
+			return nil, fmt.Errorf("unknown user: %s", options.User)
+		}
+	}
+	if !allowlisted(isolation.RunAsUsers, u.Username, u.Uid) {
+		return nil, fmt.Errorf("user %s is not in the run-as allowlist of the controller configuration", u.Username)
+	}
+	uid, _ := strconv.ParseUint(u.Uid, 10, 32)
+	gid, _ := strconv.ParseUint(u.Gid, 10, 32)
+
+	if options.Group != "" {
+		g, err := user.LookupGroup(options.Group)
+		if err != nil {
+			if g, err = user.LookupGroupId(options.Group); err != nil {
+				return nil, fmt.Errorf("unknown group: %s", options.Group)
+			}
+		}
+		if g.Gid != u.Gid && !allowlisted(isolation.RunAsGroups, g.Name, g.Gid) {
+			return nil, fmt.Errorf("group %s is not in the run-as allowlist of the controller configuration", g.Name)
+		}
+		gid, _ = strconv.ParseUint(g.Gid, 10, 32)
+	}
+
+	if uid == 0 {
+		return nil, errors.New("servers cannot be set to run as root")
+	}
+	if gid == 0 {
+		return nil, errors.New("servers cannot be set to run with the root group")
+	}
+	if os.Geteuid() != 0 && uint64(os.Geteuid()) != uid {
+		return nil, errors.New("running servers as another user needs the controller to run as root")
+	}
+
+	r := &runAs{uid: uint32(uid), gid: uint32(gid), name: u.Username, home: u.HomeDir}
+	if ids, err := u.GroupIds(); err == nil {
+		for _, id := range ids {
+			n, err := strconv.ParseUint(id, 10, 32)
+			if err != nil || n == gid {
+				continue
+			}
+			if n == 0 {
+				return nil, fmt.Errorf("user %s is a member of the root group", u.Username)
+			}
+			r.groups = append(r.groups, uint32(n))
+		}
+	}
+	return r, nil
+}
+
+// allowlisted reports whether a user or group is in an allowlist by name or
+// by ID
+func allowlisted(allowlist []string, name, id string) bool {
+	for _, allowed := range allowlist {
+		if allowed == name || allowed == id {
+			return true
+		}
+	}
+	return false
+}
+
+// parseUmask parses an octal umask such as 007 or 0027
+func parseUmask(text string) (int, error) {
+	mask, err := strconv.ParseUint(text, 8, 32)
+	if err != nil || mask > 0777 {
+		return 0, fmt.Errorf("umask must be an octal value such as 007: %s", text)
+	}
+	return int(mask), nil
+}
+
+// applyProcessOptions sets up a server command to run in its own session
+// and process group, as its user and with its umask and priority. The umask and
+// priority are set by wrapping the command, since exec has no option for
+// them and they are inherited across exec.
+func applyProcessOptions(server *models.Server, cmd *exec.Cmd) error {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	// A session of its own makes the server the leader of a process group
+	// signals can reach the whole tree through, and marks its descendants
+	// even after they are orphaned
+	cmd.SysProcAttr.Setsid = true
+
+	options := server.ProcessOptions
+	if options.IsEmpty() {
+		return nil
+	}
+
+	if options.User != "" {
+		r, err := resolveRunAs(options)
+		if err != nil {
+			return err
+		}
+		cmd.SysProcAttr.Credential = &syscall.Credential{Uid: r.uid, Gid: r.gid, Groups: r.groups}
+		cmd.Env = setEnv(cmd.Env, "HOME", r.home)
+		cmd.Env = setEnv(cmd.Env, "USER", r.name)
+		cmd.Env = setEnv(cmd.Env, "LOGNAME", r.name)
+
+		if info, err := os.Stat(cmd.Dir); err == nil {
+			if stat, ok := info.Sys().(*syscall.Stat_t); ok && stat.Uid != r.uid {
+				log.Printf("⚠️  Server '%s' runs as %s but its folder is owned by uid %d", server.Name, r.name, stat.Uid)
+			}
+		}
+	}
+
+	prefix := []string{}
+	if options.Nice != 0 {
+		if err := checkNice(options.Nice); err != nil {
+			return err
+		}
+		nice, err := exec.LookPath("nice")
+		if err != nil {
+			return errors.New("nice is not installed")
+		}
+		prefix = append(prefix, nice, "-n", strconv.Itoa(options.Nice))
+	}
+	if options.IOClass != models.IOClassDefault {
+		ionice, err := exec.LookPath("ionice")
+		if err != nil {
+			return errors.New("ionice is not installed")
+		}
+		if options.IOClass == models.IOClassIdle {
+			prefix = append(prefix, ionice, "-c", "3")
+		} else {
+			prefix = append(prefix, ionice, "-c", "2", "-n", strconv.Itoa(options.IOLevel))
+		}
+	}
+	if options.Umask != "" {
+		mask, err := parseUmask(options.Umask)
+		if err != nil {
+			return err
+		}
+		// "$@" is the command; exec keeps the process ID
+		prefix = append([]string{shellPath, "-c", fmt.Sprintf("umask %03o && exec \"$@\"", mask), "server"}, prefix...)
+	}
+	if len(prefix) == 0 {
+		return nil
+	}
+
+	args := append(prefix, cmd.Args...)
+	cmd.Path = args[0]
+	cmd.Args = args
+	return nil
+}
+
+// filterEnvironment keeps the variables of env named in the allowlist; a
+// name ending in * matches a prefix
+func filterEnvironment(env, allowlist []string) []string {
+	kept := []string{}
+	for _, entry := range env {
+		name, _, _ := strings.Cut(entry, "=")
+		for _, allowed := range allowlist {
+			if prefix, found := strings.CutSuffix(allowed, "*"); (found && strings.HasPrefix(name, prefix)) || name == allowed {
+				kept = append(kept, entry)
+				break
+			}
+		}
+	}
+	return kept
+}
+
+// setEnv sets a variable in an environment list
+func setEnv(env []string, name, value string) []string {
+	for i, entry := range env {
+		if strings.HasPrefix(entry, name+"=") {
+			env[i] = name + "=" + value
+			return env
+		}
+	}
+	return append(env, name+"="+value)
+}
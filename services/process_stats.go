@@ -0,0 +1,292 @@
+package services
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// clockTicks is the kernel USER_HZ used by the time fields of /proc/[pid]/stat.
+// It is 100 on every mainstream Linux architecture.
+const clockTicks = 100
+
+// procStat holds the fields we need from /proc/[pid]/stat
+type procStat struct {
+	pid       int
+	ppid      int
+	comm      string
+	state     byte // R, S, D, Z, ...
+	session   int
+	utime     uint64 // clock ticks
+	stime     uint64 // clock ticks
+	cutime    uint64 // clock ticks of reaped children
+	cstime    uint64 // clock ticks of reaped children
+	threads   int
+	startTime uint64 // clock ticks since boot
+}
+
+// ProcessTreeStats holds resource usage summed over a process and all its descendants
+type ProcessTreeStats struct {
+	RootPID       int
+	JavaPID       int
+	Processes     int
+	MemoryKB      int64
+	CPUTicks      uint64
+	Threads       int
+	OpenFiles     int
+	ReadBytes     uint64
+	WriteBytes    uint64
+	UptimeSeconds float64
+}
+
+// readProcStat parses /proc/[pid]/stat
+func readProcStat(pid int) (*procStat, error) {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return nil, err
+	}
+
+	// The command name is wrapped in parentheses and may itself contain
+	// spaces or parentheses, so split around the last ')'
+	line := string(data)
+	open := strings.IndexByte(line, '(')
+	close := strings.LastIndexByte(line, ')')
+	if open < 0 || close < open {
+		return nil, fmt.Errorf("invalid /proc/%d/stat format", pid)
+	}
+
+	// fields[0] is field 3 (state) in proc(5) numbering
+	fields := strings.Fields(line[close+1:])
+	if len(fields) < 20 {
+		return nil, fmt.Errorf("invalid /proc/%d/stat format", pid)
+	}
+
+	stat := &procStat{
+		pid:  pid,
+		comm: line[open+1 : close],
+	}
+	stat.state = fields[0][0]
+	stat.ppid, _ = strconv.Atoi(fields[1])
+	stat.session, _ = strconv.Atoi(fields[3])
+	stat.utime, _ = strconv.ParseUint(fields[11], 10, 64)
+	stat.stime, _ = strconv.ParseUint(fields[12], 10, 64)
+	stat.cutime, _ = strconv.ParseUint(fields[13], 10, 64)
+	stat.cstime, _ = strconv.ParseUint(fields[14], 10, 64)
+	stat.threads, _ = strconv.Atoi(fields[17])
+	stat.startTime, _ = strconv.ParseUint(fields[19], 10, 64)
+
+	return stat, nil
+}
+
+// listProcesses reads the stat of every process currently visible in /proc
+func listProcesses() []*procStat {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil
+	}
+
+	procs := make([]*procStat, 0, len(entries))
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+
+		// Processes can exit between ReadDir and here
+		stat, err := readProcStat(pid)
+		if err != nil {
+			continue
+		}
+		procs = append(procs, stat)
+	}
+
+	return procs
+}
+
+// listProcessTree returns the root process followed by all of its descendants
+func listProcessTree(rootPID int) []*procStat {
+	var root *procStat
+	children := make(map[int][]*procStat)
+
+	for _, stat := range listProcesses() {
+		if stat.pid == rootPID {
+			root = stat
+		}
+		children[stat.ppid] = append(children[stat.ppid], stat)
+	}
+
+	if root == nil {
+		return nil
+	}
+
+	// Breadth-first walk from the root
+	tree := []*procStat{root}
+	for i := 0; i < len(tree); i++ {
+		tree = append(tree, children[tree[i].pid]...)
+	}
+
+	return tree
+}
+
+// getProcessTreeStats sums resource usage over a process tree
+func getProcessTreeStats(rootPID int) (*ProcessTreeStats, error) {
+	tree := listProcessTree(rootPID)
+	if len(tree) == 0 {
+		return nil, fmt.Errorf("process %d not found", rootPID)
+	}
+
+	stats := &ProcessTreeStats{
+		RootPID:   rootPID,
+		Processes: len(tree),
+	}
+
+	for _, proc := range tree {
+		// The JVM is usually a child or grandchild of a start script
+		if stats.JavaPID == 0 && proc.comm == "java" {
+			stats.JavaPID = proc.pid
+		}
+
+		// A child's time moves to its parent's cutime and cstime when it is
+		// reaped, so the sum does not drop when children exit
+		stats.CPUTicks += proc.utime + proc.stime + proc.cutime + proc.cstime
+		stats.Threads += proc.threads
+
+		if memoryKB, err := getProcessMemory(proc.pid); err == nil {
+			stats.MemoryKB += memoryKB
+		}
+
+		if fds, err := os.ReadDir(fmt.Sprintf("/proc/%d/fd", proc.pid)); err == nil {
+			stats.OpenFiles += len(fds)
+		}
+
+		// /proc/[pid]/io is only readable by the owner (or root)
+		if readBytes, writeBytes, err := getProcessIO(proc.pid); err == nil {
+			stats.ReadBytes += readBytes
+			stats.WriteBytes += writeBytes
+		}
+	}
+
+	if systemUptime, err := getSystemUptime(); err == nil {
+		started := float64(tree[0].startTime) / clockTicks
+		if systemUptime > started {
+			stats.UptimeSeconds = systemUptime - started
+		}
+	}
+
+	return stats, nil
+}
+
+// getProcessIO reads storage read/write byte counters from /proc/[pid]/io
+func getProcessIO(pid int) (uint64, uint64, error) {
+	file, err := os.Open(fmt.Sprintf("/proc/%d/io", pid))
+	if err != nil {
+		return 0, 0, err
+	}
+	defer file.Close()
+
+	var readBytes, writeBytes uint64
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+
+		value, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+
+		switch fields[0] {
+		case "read_bytes:":
+			readBytes = value
+		case "write_bytes:":
+			writeBytes = value
+		}
+	}
+
+	return readBytes, writeBytes, scanner.Err()
+}
+
+// getSystemUptime returns seconds since boot from /proc/uptime
+func getSystemUptime() (float64, error) {
+	data, err := os.ReadFile("/proc/uptime")
+	if err != nil {
+		return 0, err
+	}
+
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("invalid /proc/uptime format")
+	}
+
+	return strconv.ParseFloat(fields[0], 64)
+}
+
+// cpuPercent turns the cumulative CPU ticks of the process tree into a
+// usage percentage since the previous sample (100% = one full core)
+func (sp *ServerProcess) cpuPercent(stats *ProcessTreeStats) float64 {
+	sp.StatsMux.Lock()
+	defer sp.StatsMux.Unlock()
+
+	now := time.Now()
+
+	// First sample: fall back to the average over the process lifetime
+	if sp.cpuPrevTime.IsZero() {
+		if stats.UptimeSeconds > 0 {
+			sp.cpuLastPercent = float64(stats.CPUTicks) / clockTicks / stats.UptimeSeconds * 100
+		}
+		sp.cpuPrevTicks = stats.CPUTicks
+		sp.cpuPrevTime = now
+		return sp.cpuLastPercent
+	}
+
+	// Several pages poll at once; keep the last value until the window is
+	// long enough to give a meaningful delta
+	elapsed := now.Sub(sp.cpuPrevTime).Seconds()
+	if elapsed < 1 {
+		return sp.cpuLastPercent
+	}
+
+	// Ticks can still drop when a child is reaped outside the tree
+	if stats.CPUTicks >= sp.cpuPrevTicks {
+		sp.cpuLastPercent = float64(stats.CPUTicks-sp.cpuPrevTicks) / clockTicks / elapsed * 100
+	} else {
+		sp.cpuLastPercent = 0
+	}
+
+	sp.cpuPrevTicks = stats.CPUTicks
+	sp.cpuPrevTime = now
+	return sp.cpuLastPercent
+}
+
+// cpuSeconds returns the CPU time of the process tree, never less than an
+// earlier value, so it can be exported as a counter even when a child that
+// was reaped outside the tree takes its time with it
+func (sp *ServerProcess) cpuSeconds(stats *ProcessTreeStats) float64 {
+	sp.StatsMux.Lock()
+	defer sp.StatsMux.Unlock()
+
+	if stats.CPUTicks > sp.cpuTotalTicks {
+		sp.cpuTotalTicks = stats.CPUTicks
+	}
+	return float64(sp.cpuTotalTicks) / clockTicks
+}
+
+// ioBytes returns the bytes read and written by the process tree, never less
+// than earlier values, for the same reason as cpuSeconds
+func (sp *ServerProcess) ioBytes(stats *ProcessTreeStats) (read, written uint64) {
+	sp.StatsMux.Lock()
+	defer sp.StatsMux.Unlock()
+
+	if stats.ReadBytes > sp.ioReadBytes {
+		sp.ioReadBytes = stats.ReadBytes
+	}
+	if stats.WriteBytes > sp.ioWriteBytes {
+		sp.ioWriteBytes = stats.WriteBytes
+	}
+	return sp.ioReadBytes, sp.ioWriteBytes
+}
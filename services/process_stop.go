@@ -0,0 +1,255 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"minecraft-server-controller/config"
+	"minecraft-server-controller/models"
+)
+
+// killTimeout is how long to wait for a server to exit after SIGKILL
+const killTimeout = 10 * time.Second
+
+// leftoverTimeout is how long killed leftover processes get to disappear
+const leftoverTimeout = 5 * time.Second
+
+// countdownWarnings are the seconds before a stop at which players are
+// warned, besides the start of the countdown
+var countdownWarnings = []int{600, 300, 120, 60, 30, 10, 5, 4, 3, 2, 1}
+
+// Reasons a server is stopped, used in the countdown warnings
+const (
+	stopReasonStop     = "stopping"
+	stopReasonRestart  = "restarting"
+	stopReasonShutdown = "shutting down"
+)
+
+// stopProcess stops the server process with its stop command after the
+// countdown, escalating to SIGTERM and then SIGKILL for its process group
+// when it does not exit in time. monitorProcess reaps the process and
+// cleans up.
+func (sp *ServerProcess) stopProcess(reason string, progress func(step string)) error {
+	escalation := config.GetStopEscalation()
+	name := sp.Server.Name
+	settings, stopCommand := sp.stopSettings()
+
+	stopTimeout := escalation.StopTimeoutSeconds
+	if settings != nil {
+		if settings.TimeoutSeconds > 0 {
+			stopTimeout = settings.TimeoutSeconds
+		}
+		// The controller shutting down cannot wait for a countdown
+		if settings.CountdownSeconds > 0 && reason != stopReasonShutdown && sp.countdown(settings.CountdownSeconds, reason, progress) {
+			log.Printf("✅ Server '%s' stopped during the countdown", name)
+			return nil
+		}
+	}
+
+	// Send the stop command to the server
+	progress("Sending the stop command")
+	sp.stopping.Store(true)
+	if sp.Stdin != nil {
+		sp.Stdin.Write([]byte(stopCommand + "\n"))
+	}
+	if sp.waitExit(time.Duration(stopTimeout) * time.Second) {
+		log.Printf("✅ Server '%s' stopped gracefully", name)
+		return nil
+	}
+
+	log.Printf("⚠️  Server '%s' did not stop within %ds, sending SIGTERM", name, stopTimeout)
+	progress("Sending SIGTERM")
+	signalProcessGroup(sp.Cmd.Process, syscall.SIGTERM)
+	if sp.waitExit(time.Duration(escalation.TermTimeoutSeconds) * time.Second) {
+		log.Printf("✅ Server '%s' stopped after SIGTERM", name)
+		return nil
+	}
+
+	log.Printf("⚠️  Server '%s' did not exit within %ds of SIGTERM, forcing kill", name, escalation.TermTimeoutSeconds)
+	progress("Sending SIGKILL")
+	signalProcessGroup(sp.Cmd.Process, syscall.SIGKILL)
+	if sp.cgroup != "" {
+		// Also reaches processes that left the process group
+		os.WriteFile(filepath.Join(sp.cgroup, "cgroup.kill"), []byte("1"), 0644)
+	}
+	if sp.waitExit(killTimeout) {
+		return nil
+	}
+	return fmt.Errorf("server process %d did not exit after SIGKILL", sp.Cmd.Process.Pid)
+}
+
+// stopSettings returns the current stop settings and stop command of the
+// server, which can change while it runs
+func (sp *ServerProcess) stopSettings() (*models.StopSettings, string) {
+	serverMux.Lock()
+	defer serverMux.Unlock()
+	return sp.Server.StopSettings, sp.Server.StopCommand()
+}
+
+// ApplyStopSettings hands changed stop settings to the process of a running
+// server, so its next stop uses them
+func ApplyStopSettings(server *models.Server) {
+	serverMux.Lock()
+	defer serverMux.Unlock()
+
+	if sp, exists := runningServers[server.ID]; exists {
+		sp.Server.StopSettings = server.StopSettings
+	}
+}
+
+// countdown warns the players before the server stops, and reports whether
+// the server exited in the meantime
+func (sp *ServerProcess) countdown(seconds int, reason string, progress func(step string)) bool {
+	log.Printf("⏳ Server '%s' is %s in %ds", sp.Server.Name, reason, seconds)
+
+	remaining := seconds
+	warn := func() {
+		message := fmt.Sprintf("Server %s in %s", reason, formatCountdown(remaining))
+		progress(message)
+		sp.broadcast(message)
+	}
+	warn()
+	for _, at := range countdownWarnings {
+		if at >= remaining {
+			continue
+		}
+		if sp.waitExit(time.Duration(remaining-at) * time.Second) {
+			return true
+		}
+		remaining = at
+		warn()
+	}
+	return sp.waitExit(time.Duration(remaining) * time.Second)
+}
+
+// broadcast sends a message to all players with the software's broadcast
+// command; Velocity has none
+func (sp *ServerProcess) broadcast(message string) {
+	if sp.Stdin == nil {
+		return
+	}
+	switch sp.Server.Software {
+	case models.SoftwareVelocity:
+		return
+	case models.SoftwareBungeeCord:
+		sp.Stdin.Write([]byte("alert " + message + "\n"))
+	default:
+		sp.Stdin.Write([]byte("say " + message + "\n"))
+	}
+}
+
+// formatCountdown formats the seconds left as "2m", "1m30s" or "10s"
+func formatCountdown(seconds int) string {
+	switch {
+	case seconds >= 60 && seconds%60 == 0:
+		return fmt.Sprintf("%dm", seconds/60)
+	case seconds > 60:
+		return fmt.Sprintf("%dm%ds", seconds/60, seconds%60)
+	}
+	return fmt.Sprintf("%ds", seconds)
+}
+
+// waitPortsReleased waits until the ports of a stopped server can be bound
+// again, so a restart does not fail on them
+func waitPortsReleased(server *models.Server, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		free := true
+		for _, port := range ServerPorts(server) {
+			if !portAvailable(port.Port, port.Protocol) {
+				free = false
+				break
+			}
+		}
+		if free {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(250 * time.Millisecond)
+	}
+}
+
+// waitExit waits until monitorProcess saw the process exit and cleaned up
+func (sp *ServerProcess) waitExit(timeout time.Duration) bool {
+	select {
+	case <-sp.exited:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// signalProcessGroup signals the process group of a server, falling back to
+// the process itself
+func signalProcessGroup(process *os.Process, sig syscall.Signal) error {
+	if err := syscall.Kill(-process.Pid, sig); err != nil {
+		return process.Signal(sig)
+	}
+	return nil
+}
+
+// leftoverProcesses returns the processes of the server's session, or of its
+// cgroup, that are still alive after the server process exited
+func (sp *ServerProcess) leftoverProcesses() []int {
+	sid := sp.Cmd.Process.Pid
+	pids := []int{}
+	for _, stat := range listProcesses() {
+		// Zombies are dead already and only wait to be reaped
+		if stat.session == sid && stat.pid != sid && stat.state != 'Z' {
+			pids = append(pids, stat.pid)
+		}
+	}
+
+	if sp.cgroup != "" {
+		data, _ := os.ReadFile(filepath.Join(sp.cgroup, "cgroup.procs"))
+		for _, line := range strings.Fields(string(data)) {
+			if pid, err := strconv.Atoi(line); err == nil && !containsPID(pids, pid) {
+				pids = append(pids, pid)
+			}
+		}
+	}
+	return pids
+}
+
+// containsPID reports whether pids holds pid
+func containsPID(pids []int, pid int) bool {
+	for _, p := range pids {
+		if p == pid {
+			return true
+		}
+	}
+	return false
+}
+
+// killLeftovers kills descendants that outlived the server process, such as
+// a JVM started by a wrapper script, since they still hold the server's port
+// and world lock, and verifies they are gone
+func (sp *ServerProcess) killLeftovers() error {
+	pids := sp.leftoverProcesses()
+	if len(pids) == 0 {
+		return nil
+	}
+
+	log.Printf("⚠️  Killing %d leftover process(es) of server '%s': %v", len(pids), sp.Server.Name, pids)
+	for _, pid := range pids {
+		syscall.Kill(pid, syscall.SIGKILL)
+	}
+
+	deadline := time.Now().Add(leftoverTimeout)
+	for time.Now().Before(deadline) {
+		if pids = sp.leftoverProcesses(); len(pids) == 0 {
+			return nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return errors.New("processes still running: " + fmt.Sprint(pids))
+}
@@ -0,0 +1,157 @@
+package services
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ServerPropertiesFile returns the path of a server folder's server.properties
+func ServerPropertiesFile(folder string) string {
+	return filepath.Join(folder, "server.properties")
+}
+
+// ReadProperties parses a Java .properties file such as server.properties
+func ReadProperties(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	props := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if key, value, ok := parsePropertyLine(scanner.Text()); ok {
+			props[key] = value
+		}
+	}
+
+	return props, scanner.Err()
+}
+
+// parsePropertyLine splits a properties line into key and value. Comments
+// and blank lines return ok false.
+func parsePropertyLine(line string) (key, value string, ok bool) {
+	trimmed := strings.TrimLeft(line, " \t\f")
+	if trimmed == "" || trimmed[0] == '#' || trimmed[0] == '!' {
+		return "", "", false
+	}
+
+	// The key ends at the first unescaped '=', ':' or whitespace
+	end := len(trimmed)
+	for i := 0; i < len(trimmed); i++ {
+		if trimmed[i] == '\\' {
+			i++
+			continue
+		}
+		if trimmed[i] == '=' || trimmed[i] == ':' || trimmed[i] == ' ' || trimmed[i] == '\t' {
+			end = i
+			break
+		}
+	}
+
+	rest := strings.TrimLeft(trimmed[end:], " \t\f")
+	if rest != "" && (rest[0] == '=' || rest[0] == ':') {
+		rest = strings.TrimLeft(rest[1:], " \t\f")
+	}
+
+	return unescapeProperty(trimmed[:end]), unescapeProperty(rest), true
+}
+
+// unescapeProperty decodes backslash escapes, including \uXXXX
+func unescapeProperty(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 >= len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+
+		i++
+		switch s[i] {
+		case 't':
+			b.WriteByte('\t')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 'f':
+			b.WriteByte('\f')
+		case 'u':
+			if i+4 < len(s) {
+				if code, err := strconv.ParseUint(s[i+1:i+5], 16, 32); err == nil {
+					b.WriteRune(rune(code))
+					i += 4
+					continue
+				}
+			}
+			b.WriteByte('u')
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// escapePropertyKey escapes the characters that would end a key early
+func escapePropertyKey(key string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, "=", `\=`, ":", `\:`, " ", `\ `)
+	return replacer.Replace(key)
+}
+
+// escapePropertyValue escapes backslashes and line breaks in a value
+func escapePropertyValue(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, "\n", `\n`, "\r", `\r`)
+	return replacer.Replace(value)
+}
+
+// UpdateProperties sets keys in a .properties file, creating it if needed.
+// Existing lines, comments and order are kept; new keys are appended sorted.
+func UpdateProperties(path string, updates map[string]string) error {
+	lines := []string{}
+	if data, err := os.ReadFile(path); err == nil {
+		if content := strings.TrimRight(string(data), "\n"); content != "" {
+			lines = strings.Split(content, "\n")
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	written := make(map[string]bool)
+	for i, line := range lines {
+		key, _, ok := parsePropertyLine(line)
+		if !ok {
+			continue
+		}
+		if value, exists := updates[key]; exists {
+			lines[i] = escapePropertyKey(key) + "=" + escapePropertyValue(value)
+			written[key] = true
+		}
+	}
+
+	missing := []string{}
+	for key := range updates {
+		if !written[key] {
+			missing = append(missing, key)
+		}
+	}
+	sort.Strings(missing)
+	for _, key := range missing {
+		lines = append(lines, escapePropertyKey(key)+"="+escapePropertyValue(updates[key]))
+	}
+
+	// Write next to the original and rename, so a crash never leaves half a file
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
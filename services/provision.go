@@ -0,0 +1,166 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"minecraft-server-controller/config"
+	"minecraft-server-controller/models"
+)
+
+// serverNamePattern limits server names to characters that are safe as a
+// folder name and in URLs
+var serverNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]{0,63}$`)
+
+// Gamemodes accepted in server.properties
+var Gamemodes = []string{"survival", "creative", "adventure", "spectator"}
+
+// ProvisionOptions describes a server to create from the jar library
+type ProvisionOptions struct {
+	Name       string
+	JarID      uint
+	Port       int
+	MOTD       string
+	Seed       string
+	Gamemode   string
+	MemoryMB   int
+	AcceptEULA bool
+}
+
+// ValidateServerName checks that a name can be used as a server folder name
+func ValidateServerName(name string) error {
+	if !serverNamePattern.MatchString(name) {
+		return errors.New("name may only contain letters, digits, '.', '_' and '-' and must start with a letter or digit")
+	}
+	return nil
+}
+
+// Validate checks the options before anything is written to disk
+func (opts *ProvisionOptions) Validate() error {
+	if err := ValidateServerName(opts.Name); err != nil {
+		return err
+	}
+	if !opts.AcceptEULA {
+		return errors.New("the Minecraft EULA must be accepted")
+	}
+	if opts.Port < 1 || opts.Port > 65535 {
+		return errors.New("port must be between 1 and 65535")
+	}
+	if opts.MemoryMB < 512 {
+		return errors.New("memory must be at least 512 MB")
+	}
+
+	validGamemode := false
+	for _, gamemode := range Gamemodes {
+		if opts.Gamemode == gamemode {
+			validGamemode = true
+		}
+	}
+	if !validGamemode {
+		return errors.New("unknown gamemode")
+	}
+
+	if strings.ContainsAny(opts.MOTD, "\r\n") {
+		return errors.New("MOTD must be a single line")
+	}
+	return nil
+}
+
+// ProvisionServer creates a server folder under the server folder path from a
+// library jar, writes eula.txt and server.properties and registers the server
+func ProvisionServer(opts ProvisionOptions, userID uint) (*models.Server, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	serverPath := config.GetServerPath()
+	if serverPath == "" {
+		return nil, errors.New("server folder path is not configured")
+	}
+
+	if _, err := models.GetServerByName(opts.Name, userID); err == nil {
+		return nil, errors.New("a server with this name already exists")
+	}
+
+	jar, err := models.GetJarFileByID(opts.JarID)
+	if err != nil {
+		return nil, errors.New("jar not found in the library")
+	}
+
+	folder := filepath.Join(serverPath, opts.Name)
+	if err := os.Mkdir(folder, 0755); err != nil {
+		if os.IsExist(err) {
+			return nil, errors.New("a folder with this name already exists")
+		}
+		return nil, fmt.Errorf("failed to create server folder: %w", err)
+	}
+
+	server, err := provisionFolder(folder, jar, opts, userID)
+	if err != nil {
+		// Leave nothing half-created behind
+		os.RemoveAll(folder)
+		return nil, err
+	}
+
+	log.Printf("✅ Server '%s' provisioned from %s (%s)", server.Name, jar.DisplayName(), jar.ShortSHA())
+	return server, nil
+}
+
+// provisionFolder fills a fresh server folder and registers the server
+func provisionFolder(folder string, jar *models.JarFile, opts ProvisionOptions, userID uint) (*models.Server, error) {
+	if err := copyLibraryJar(jar, filepath.Join(folder, "server.jar")); err != nil {
+		return nil, err
+	}
+
+	eula := fmt.Sprintf("#By changing the setting below to TRUE you are indicating your agreement to our EULA (https://aka.ms/MinecraftEULA).\n#%s\neula=true\n",
+		time.Now().Format("Mon Jan 02 15:04:05 MST 2006"))
+	if err := os.WriteFile(filepath.Join(folder, "eula.txt"), []byte(eula), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write eula.txt: %w", err)
+	}
+
+	props := map[string]string{
+		"server-port": strconv.Itoa(opts.Port),
+		"query.port":  strconv.Itoa(opts.Port),
+		"motd":        opts.MOTD,
+		"level-seed":  opts.Seed,
+		"gamemode":    opts.Gamemode,
+	}
+	if err := UpdateProperties(ServerPropertiesFile(folder), props); err != nil {
+		return nil, fmt.Errorf("failed to write server.properties: %w", err)
+	}
+
+	software := DetectSoftware(folder, filepath.Join(folder, "server.jar"))
+
+	profile := DefaultLaunchProfile("server.jar", opts.MemoryMB)
+	profile.JavaPath = JavaForJar(filepath.Join(folder, "server.jar"))
+	if models.IsProxySoftware(software.Software) {
+		// Proxies have no GUI and reject unknown options
+		profile.NoGUI = false
+	}
+	command := RenderLaunchProfile(profile)
+
+	server, err := models.CreateServer(opts.Name, folder, command, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := server.UpdateLaunchProfile(profile, command, nil); err != nil {
+		server.Delete()
+		return nil, err
+	}
+	if err := server.SetSoftware(software.Software, software.Version); err != nil {
+		server.Delete()
+		return nil, err
+	}
+	if err := server.SetLibraryJar(jar.SHA256); err != nil {
+		server.Delete()
+		return nil, err
+	}
+	return server, nil
+}
@@ -0,0 +1,120 @@
+package services
+
+import (
+	"sync"
+	"time"
+
+	"minecraft-server-controller/models"
+)
+
+// ServerCounters holds lifecycle counters for a server since the controller started
+type ServerCounters struct {
+	Starts   int `json:"starts"`
+	Restarts int `json:"restarts"`
+	Crashes  int `json:"crashes"`
+}
+
+// ServerExit describes how a server process last ended
+type ServerExit struct {
+	Code     int       `json:"code"`
+	Expected bool      `json:"expected"` // a stop was requested
+	Crashed  bool      `json:"crashed"`  // unexpected and failed or killed
+	At       time.Time `json:"at"`
+}
+
+var (
+	serverCounters = make(map[uint]*ServerCounters)
+	lastExits      = make(map[uint]ServerExit)
+	crashTimes     = make(map[uint][]time.Time)
+	countersMux    sync.Mutex
+)
+
+// maxCrashHistory bounds the crash timestamps kept per server
+const maxCrashHistory = 50
+
+// countServerEvent applies an update to a server's counters
+func countServerEvent(serverID uint, update func(c *ServerCounters)) {
+	countersMux.Lock()
+	defer countersMux.Unlock()
+
+	counters, exists := serverCounters[serverID]
+	if !exists {
+		counters = &ServerCounters{}
+		serverCounters[serverID] = counters
+	}
+	update(counters)
+}
+
+// GetServerCounters returns a copy of a server's lifecycle counters
+func GetServerCounters(server *models.Server) ServerCounters {
+	countersMux.Lock()
+	defer countersMux.Unlock()
+
+	if counters, exists := serverCounters[server.ID]; exists {
+		return *counters
+	}
+	return ServerCounters{}
+}
+
+// recordServerExit remembers how a server process ended; crashes are
+// unexpected exits with a nonzero code or by SIGKILL, so an in-game /stop
+// is not one
+func recordServerExit(serverID uint, code int, expected, crashed bool) {
+	now := time.Now()
+
+	countersMux.Lock()
+	lastExits[serverID] = ServerExit{Code: code, Expected: expected, Crashed: crashed, At: now}
+	if crashed {
+		times := append(crashTimes[serverID], now)
+		if len(times) > maxCrashHistory {
+			times = times[len(times)-maxCrashHistory:]
+		}
+		crashTimes[serverID] = times
+	}
+	countersMux.Unlock()
+
+	if crashed {
+		countServerEvent(serverID, func(c *ServerCounters) { c.Crashes++ })
+	}
+}
+
+// clearServerExit forgets the last exit once a server is started again
+func clearServerExit(serverID uint) {
+	countersMux.Lock()
+	defer countersMux.Unlock()
+	delete(lastExits, serverID)
+}
+
+// GetLastExit returns how a server last exited, if it has exited since it was last started
+func GetLastExit(server *models.Server) (ServerExit, bool) {
+	countersMux.Lock()
+	defer countersMux.Unlock()
+
+	exit, exists := lastExits[server.ID]
+	return exit, exists
+}
+
+// RecentCrashCount returns the number of unexpected exits within the window
+func RecentCrashCount(server *models.Server, window time.Duration) int {
+	countersMux.Lock()
+	defer countersMux.Unlock()
+
+	count := 0
+	cutoff := time.Now().Add(-window)
+	for _, at := range crashTimes[server.ID] {
+		if at.After(cutoff) {
+			count++
+		}
+	}
+	return count
+}
+
+// forgetServerCounters drops the counters and exits of a deleted server
+func forgetServerCounters(serverID uint) {
+	countersMux.Lock()
+	defer countersMux.Unlock()
+
+	delete(serverCounters, serverID)
+	delete(lastExits, serverID)
+	delete(crashTimes, serverID)
+}
@@ -8,9 +8,12 @@ import (
 	"log"
 	"os"
 	"os/exec"
+	"regexp"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"minecraft-server-controller/models"
@@ -20,25 +23,72 @@ import (
 
 // ServerProcess holds the running server process information
 type ServerProcess struct {
-	Server  *models.Server
-	Cmd     *exec.Cmd
-	Stdin   io.WriteCloser
-	Stdout  io.ReadCloser
-	Stderr  io.ReadCloser
-	Logs    []string
-	LogMux  sync.Mutex
-	Clients []*websocket.Conn
+	Server    *models.Server
+	Cmd       *exec.Cmd
+	Stdin     io.WriteCloser
+	Stdout    io.ReadCloser
+	Stderr    io.ReadCloser
+	Logs      []string
+	LogMux    sync.Mutex
+	Clients   []*websocket.Conn
 	ClientMux sync.Mutex
+	StatsMux  sync.Mutex
+	Players   map[string]time.Time
+	PlayerMux sync.Mutex
+
+	// stopping is set once the stop command was sent, so the exit is expected
+	stopping atomic.Bool
+
+	// cgroup is the cgroup v2 directory holding the process tree, empty
+	// when the server runs without resource limits
+	cgroup string
+
+	// exited is closed once monitorProcess reaped the process and cleaned up
+	exited chan struct{}
+
+	// readyPatterns are the log patterns dependent servers wait for, and
+	// readyMatched those that matched since the start, guarded by LogMux
+	readyPatterns map[string]*regexp.Regexp
+	readyMatched  map[string]bool
+
+	// CPU sampling state for percentage calculation
+	cpuPrevTicks   uint64
+	cpuPrevTime    time.Time
+	cpuLastPercent float64
+	cpuTotalTicks  uint64
+
+	// High-water marks of the storage I/O of the process tree
+	ioReadBytes  uint64
+	ioWriteBytes uint64
+
+	// lastStats are the most recently collected stats, for readers that
+	// must not move the CPU sampling window
+	lastStats *ServerStats
 }
 
 // ServerStats holds server statistics
 type ServerStats struct {
-	MemoryMB float64 `json:"memory_mb"`
-	MemoryGB float64 `json:"memory_gb"`
-	PID      int     `json:"pid"`
-	IsRunning bool   `json:"is_running"`
+	MemoryMB   float64 `json:"memory_mb"`
+	MemoryGB   float64 `json:"memory_gb"`
+	PID        int     `json:"pid"`
+	IsRunning  bool    `json:"is_running"`
+	JavaPID    int     `json:"java_pid"`
+	Processes  int     `json:"processes"`
+	CPUPercent float64 `json:"cpu_percent"`
+	Threads    int     `json:"threads"`
+	OpenFiles  int     `json:"open_files"`
+	ReadBytes  uint64  `json:"read_bytes"`
+	WriteBytes uint64  `json:"write_bytes"`
+	Uptime     float64 `json:"uptime_seconds"`
+	CPUSeconds float64 `json:"cpu_seconds"`
 }
 
+// portReleaseTimeout is how long a restart waits for the ports of the old process
+const portReleaseTimeout = 30 * time.Second
+
+// errServerNotRunning is returned for actions that need a running server
+var errServerNotRunning = errors.New("server is not running")
+
 var (
 	runningServers = make(map[uint]*ServerProcess)
 	serverMux      sync.Mutex
@@ -46,6 +96,45 @@ var (
 
 // StartServer starts a Minecraft server
 func StartServer(server *models.Server) error {
+	lock := lifecycleLock(server.ID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	return startServer(server, noProgress)
+}
+
+// startServer waits for the dependencies of a server and starts it; its
+// lifecycle lock must be held
+func startServer(server *models.Server, progress func(step string)) error {
+	if IsServerRunning(server) {
+		return errors.New("server is already running")
+	}
+	if err := waitForDependencies(server, progress); err != nil {
+		return err
+	}
+
+	progress("Starting")
+	return launchServer(server)
+}
+
+// launchServer starts the server process
+func launchServer(server *models.Server) error {
+	if server.Archived {
+		return errors.New("server is archived")
+	}
+	if server.Missing {
+		return errors.New("server folder is missing")
+	}
+
+	// Refuse to start on a port another server or process already holds.
+	// Reading every server's configuration and probing ports is slow, so it
+	// happens before serverMux is taken; only the servers sharing a port are
+	// checked again under the lock.
+	shared, err := checkStartPorts(server)
+	if err != nil {
+		return err
+	}
+
 	serverMux.Lock()
 	defer serverMux.Unlock()
 
@@ -53,35 +142,47 @@ func StartServer(server *models.Server) error {
 	if _, exists := runningServers[server.ID]; exists {
 		return errors.New("server is already running")
 	}
-
-	// Parse startup command
-	parts := strings.Fields(server.StartupCommand)
-	if len(parts) == 0 {
-		return errors.New("invalid startup command")
+	if err := recheckStartPorts(shared); err != nil {
+		return err
 	}
 
-	// Create command
-	cmd := exec.Command(parts[0], parts[1:]...)
-	cmd.Dir = server.FolderPath
-
-	// Get stdin, stdout, stderr pipes
-	stdin, err := cmd.StdinPipe()
-	if err != nil {
-		return fmt.Errorf("failed to create stdin pipe: %w", err)
+	// Refuse or warn when the host lacks memory for the server
+	if err := checkStartMemory(server); err != nil {
+		return err
 	}
 
-	stdout, err := cmd.StdoutPipe()
+	// Parse startup command and create the process
+	cmd, stdin, stdout, stderr, err := newServerCommand(server)
 	if err != nil {
-		return fmt.Errorf("failed to create stdout pipe: %w", err)
+		return err
 	}
-
-	stderr, err := cmd.StderrPipe()
-	if err != nil {
-		return fmt.Errorf("failed to create stderr pipe: %w", err)
+	if warning := javaCompatibilityWarning(cmd.Args, cmd.Dir); warning != "" {
+		log.Printf("⚠️  Server '%s': %s", server.Name, warning)
 	}
 
+	// Place the process tree in a cgroup holding the server's resource limits
+	cgroup, cgroupFile := prepareServerCgroup(server, cmd)
+
 	// Start the process
-	if err := cmd.Start(); err != nil {
+	err = cmd.Start()
+	if cgroupFile != nil {
+		cgroupFile.Close()
+	}
+	if err != nil && cgroup != "" {
+		// Kernels without clone3 cgroup support refuse the start; run the
+		// server without its limits rather than not at all
+		log.Printf("⚠️  Server '%s' starts without its resource limits: %v", server.Name, err)
+		removeServerCgroup(server, cgroup)
+		cgroup = ""
+
+		// A failed start closes the pipes, so the retry needs a new command
+		cmd, stdin, stdout, stderr, err = newServerCommand(server)
+		if err != nil {
+			return err
+		}
+		err = cmd.Start()
+	}
+	if err != nil {
 		return fmt.Errorf("failed to start server: %w", err)
 	}
 
@@ -94,9 +195,17 @@ func StartServer(server *models.Server) error {
 		Stderr:  stderr,
 		Logs:    make([]string, 0),
 		Clients: make([]*websocket.Conn, 0),
+		Players: make(map[string]time.Time),
+		cgroup:  cgroup,
+		exited:  make(chan struct{}),
+
+		readyPatterns: dependentLogPatterns(server),
+		readyMatched:  make(map[string]bool),
 	}
 
 	runningServers[server.ID] = sp
+	countServerEvent(server.ID, func(c *ServerCounters) { c.Starts++ })
+	clearServerExit(server.ID)
 
 	// Update server status
 	server.SetStatus("online")
@@ -108,78 +217,124 @@ func StartServer(server *models.Server) error {
 	// Monitor process
 	go sp.monitorProcess()
 
+	PublishEvent(EventServerStarted, server, map[string]interface{}{"pid": cmd.Process.Pid})
+
 	log.Printf("✅ Server '%s' started successfully (PID: %d)", server.Name, cmd.Process.Pid)
 	return nil
 }
 
-// StopServer stops a running Minecraft server
-func StopServer(server *models.Server) error {
-	serverMux.Lock()
-	defer serverMux.Unlock()
+// newServerCommand builds the process of a server with its process options
+// and output pipes
+func newServerCommand(server *models.Server) (*exec.Cmd, io.WriteCloser, io.ReadCloser, io.ReadCloser, error) {
+	cmd, err := BuildServerCommand(server)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
 
-	sp, exists := runningServers[server.ID]
-	if !exists {
-		return errors.New("server is not running")
+	// Run as the server's user, in its own process group, with its priority
+	if err := applyProcessOptions(server, cmd); err != nil {
+		return nil, nil, nil, nil, err
 	}
 
-	log.Printf("⏹️  Stopping server '%s'...", server.Name)
+	// Get stdin, stdout, stderr pipes
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, nil, nil, nil, fmt.Errorf("failed to create stdin pipe: %w", err)
+	}
 
-	// Send stop command to server
-	if sp.Stdin != nil {
-		sp.Stdin.Write([]byte("stop\n"))
-		sp.Stdin.Write([]byte("end\n")) // Some servers use "end"
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, nil, nil, nil, fmt.Errorf("failed to create stdout pipe: %w", err)
 	}
 
-	// Wait for graceful shutdown (with timeout)
-	done := make(chan error, 1)
-	go func() {
-		done <- sp.Cmd.Wait()
-	}()
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, nil, nil, nil, fmt.Errorf("failed to create stderr pipe: %w", err)
+	}
+	return cmd, stdin, stdout, stderr, nil
+}
 
-	select {
-	case <-done:
-		// Process stopped gracefully
-		log.Printf("✅ Server '%s' stopped gracefully", server.Name)
-	case <-time.After(30 * time.Second):
-		// Force kill if not stopped after 30 seconds
-		log.Printf("⚠️  Server '%s' did not stop gracefully, forcing kill", server.Name)
-		if sp.Cmd.Process != nil {
-			sp.Cmd.Process.Kill()
-		}
+// StopServer stops a running Minecraft server and waits until it exited
+func StopServer(server *models.Server) error {
+	lock := lifecycleLock(server.ID)
+	cancelDependencyWait(server.ID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	return stopServer(server, stopReasonStop, noProgress)
+}
+
+// stopServer stops a server with its stop settings; reason is shown in the
+// countdown warnings. The lifecycle lock of the server must be held.
+func stopServer(server *models.Server, reason string, progress func(step string)) error {
+	serverMux.Lock()
+	sp, exists := runningServers[server.ID]
+	serverMux.Unlock()
+
+	if !exists {
+		return errServerNotRunning
 	}
 
-	// Clean up
-	delete(runningServers, server.ID)
-	server.SetStatus("offline")
+	log.Printf("⏹️  Stopping server '%s'...", server.Name)
 
-	// Close all WebSocket connections
-	sp.ClientMux.Lock()
-	for _, client := range sp.Clients {
-		client.WriteMessage(websocket.TextMessage, []byte("\n=== Server stopped ===\n"))
-		client.Close()
+	return sp.stopProcess(reason, progress)
+}
+
+// StopAllServers stops every running server without a countdown and waits
+// until they exited. Servers run in sessions of their own and would keep
+// running without the controller, so it is called on shutdown.
+func StopAllServers() {
+	serverMux.Lock()
+	processes := make([]*ServerProcess, 0, len(runningServers))
+	for _, sp := range runningServers {
+		processes = append(processes, sp)
 	}
-	sp.Clients = []*websocket.Conn{}
-	sp.ClientMux.Unlock()
+	serverMux.Unlock()
 
-	return nil
+	var wg sync.WaitGroup
+	for _, sp := range processes {
+		wg.Add(1)
+		go func(sp *ServerProcess) {
+			defer wg.Done()
+			log.Printf("⏹️  Stopping server '%s'...", sp.Server.Name)
+			if err := sp.stopProcess(stopReasonShutdown, noProgress); err != nil {
+				log.Printf("⚠️  Failed to stop server '%s': %v", sp.Server.Name, err)
+			}
+		}(sp)
+	}
+	wg.Wait()
 }
 
 // RestartServer restarts a Minecraft server
 func RestartServer(server *models.Server) error {
+	lock := lifecycleLock(server.ID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	return restartServer(server, noProgress)
+}
+
+// restartServer restarts a server; its lifecycle lock must be held
+func restartServer(server *models.Server, progress func(step string)) error {
+	countServerEvent(server.ID, func(c *ServerCounters) { c.Restarts++ })
+
 	// Stop the server
-	if err := StopServer(server); err != nil {
+	if err := stopServer(server, stopReasonRestart, progress); err != nil {
 		// If server is not running, just start it
-		if err.Error() == "server is not running" {
-			return StartServer(server)
+		if errors.Is(err, errServerNotRunning) {
+			return startServer(server, progress)
 		}
 		return err
 	}
 
-	// Wait a moment before restarting
-	time.Sleep(2 * time.Second)
+	// Wait for the old process to let go of its ports
+	progress("Waiting for the ports to be released")
+	if !waitPortsReleased(server, portReleaseTimeout) {
+		log.Printf("⚠️  Ports of server '%s' are still in use after %s", server.Name, portReleaseTimeout)
+	}
 
 	// Start the server
-	return StartServer(server)
+	return startServer(server, progress)
 }
 
 // SendCommand sends a command to the server console
@@ -196,6 +351,13 @@ func SendCommand(server *models.Server, command string) error {
 		return errors.New("server stdin is not available")
 	}
 
+	// Stopping from the console is a requested stop, not a crash
+	_, stopCommand := sp.stopSettings()
+	switch strings.ToLower(strings.TrimSpace(command)) {
+	case "stop", "end", "shutdown", strings.ToLower(stopCommand):
+		sp.stopping.Store(true)
+	}
+
 	// Write command to stdin
 	_, err := sp.Stdin.Write([]byte(command + "\n"))
 	if err != nil {
@@ -239,10 +401,12 @@ func GetServerStats(server *models.Server) (*ServerStats, error) {
 		}, nil
 	}
 
+	// Stats cover the whole process tree, since start scripts make the
+	// JVM a child or grandchild of the process we launched
 	pid := sp.Cmd.Process.Pid
-	memoryKB, err := getProcessMemory(pid)
+	treeStats, err := getProcessTreeStats(pid)
 	if err != nil {
-		log.Printf("⚠️  Failed to get memory for PID %d: %v", pid, err)
+		log.Printf("⚠️  Failed to get stats for PID %d: %v", pid, err)
 		return &ServerStats{
 			MemoryMB:  0,
 			MemoryGB:  0,
@@ -251,15 +415,52 @@ func GetServerStats(server *models.Server) (*ServerStats, error) {
 		}, nil
 	}
 
-	memoryMB := float64(memoryKB) / 1024.0
+	if sp.cgroup != "" {
+		applyCgroupStats(sp.cgroup, treeStats)
+	}
+
+	memoryMB := float64(treeStats.MemoryKB) / 1024.0
 	memoryGB := memoryMB / 1024.0
+	readBytes, writeBytes := sp.ioBytes(treeStats)
+
+	stats := &ServerStats{
+		MemoryMB:   memoryMB,
+		MemoryGB:   memoryGB,
+		PID:        pid,
+		IsRunning:  true,
+		JavaPID:    treeStats.JavaPID,
+		Processes:  treeStats.Processes,
+		CPUPercent: sp.cpuPercent(treeStats),
+		Threads:    treeStats.Threads,
+		OpenFiles:  treeStats.OpenFiles,
+		ReadBytes:  readBytes,
+		WriteBytes: writeBytes,
+		Uptime:     treeStats.UptimeSeconds,
+		CPUSeconds: sp.cpuSeconds(treeStats),
+	}
 
-	return &ServerStats{
-		MemoryMB:  memoryMB,
-		MemoryGB:  memoryGB,
-		PID:       pid,
-		IsRunning: true,
-	}, nil
+	sp.StatsMux.Lock()
+	sp.lastStats = stats
+	sp.StatsMux.Unlock()
+
+	return stats, nil
+}
+
+// GetLastServerStats returns the stats last collected by GetServerStats
+// without sampling the process tree again, or nil when the server is not
+// running or has not been sampled yet
+func GetLastServerStats(server *models.Server) *ServerStats {
+	serverMux.Lock()
+	sp, exists := runningServers[server.ID]
+	serverMux.Unlock()
+
+	if !exists {
+		return nil
+	}
+
+	sp.StatsMux.Lock()
+	defer sp.StatsMux.Unlock()
+	return sp.lastStats
 }
 
 // getProcessMemory reads memory usage from /proc/[pid]/status
@@ -359,6 +560,21 @@ func RemoveConsoleListener(server *models.Server, conn *websocket.Conn) {
 	}
 }
 
+// GetConsoleClientCount returns the number of WebSocket clients watching a server's console
+func GetConsoleClientCount(server *models.Server) int {
+	serverMux.Lock()
+	sp, exists := runningServers[server.ID]
+	serverMux.Unlock()
+
+	if !exists {
+		return 0
+	}
+
+	sp.ClientMux.Lock()
+	defer sp.ClientMux.Unlock()
+	return len(sp.Clients)
+}
+
 // readOutput reads from stdout/stderr and broadcasts to clients
 func (sp *ServerProcess) readOutput(reader io.ReadCloser, isError bool) {
 	scanner := bufio.NewScanner(reader)
@@ -368,9 +584,23 @@ func (sp *ServerProcess) readOutput(reader io.ReadCloser, isError bool) {
 		// Strip ANSI color codes
 		line = stripAnsiCodes(line)
 
+		// Keep track of who is online
+		if name, joined := sp.trackPlayers(line); name != "" {
+			event := EventPlayerLeft
+			if joined {
+				event = EventPlayerJoined
+			}
+			PublishEvent(event, sp.Server, map[string]interface{}{"player": name})
+		}
+
 		// Add to logs
 		sp.LogMux.Lock()
 		sp.Logs = append(sp.Logs, line)
+		for text, pattern := range sp.readyPatterns {
+			if !sp.readyMatched[text] && pattern.MatchString(line) {
+				sp.readyMatched[text] = true
+			}
+		}
 		// Keep only last 1000 lines
 		if len(sp.Logs) > 1000 {
 			sp.Logs = sp.Logs[len(sp.Logs)-1000:]
@@ -431,29 +661,74 @@ func stripAnsiCodes(text string) string {
 
 // monitorProcess monitors the server process and updates status
 func (sp *ServerProcess) monitorProcess() {
+	defer close(sp.exited)
+
 	// Wait for process to end
 	err := sp.Cmd.Wait()
 	
 	exitCode := 0
+	killed := false
 	if err != nil {
 		if exitErr, ok := err.(*exec.ExitError); ok {
 			exitCode = exitErr.ExitCode()
+			if status, ok := exitErr.Sys().(syscall.WaitStatus); ok {
+				killed = status.Signaled() && status.Signal() == syscall.SIGKILL
+			}
 		}
 	}
 
 	log.Printf("⚠️  Server '%s' process ended (exit code: %d)", sp.Server.Name, exitCode)
 
+	// Descendants that outlived the process still hold the port and world lock
+	if err := sp.killLeftovers(); err != nil {
+		log.Printf("⚠️  Leftover processes of server '%s' could not be killed: %v", sp.Server.Name, err)
+	}
+
+	expected := sp.stopping.Load()
+	crashed := !expected && (exitCode != 0 || killed)
+	recordServerExit(sp.Server.ID, exitCode, expected, crashed)
+
+	// Look for crash reports and JVM error logs left behind by the crash
+	var crash *models.CrashReport
+	if crashed {
+		since := time.Now()
+		if sp.Server.StartedAt != nil {
+			since = *sp.Server.StartedAt
+		}
+		sp.LogMux.Lock()
+		output := append([]string(nil), sp.Logs...)
+		sp.LogMux.Unlock()
+		crash = recordCrash(sp.Server, exitCode, killed, since, output)
+	}
+
+	event := EventServerStopped
+	data := map[string]interface{}{"exit_code": exitCode}
+	if crashed {
+		event = EventServerCrashed
+		if crash != nil {
+			data["crash"] = crash.Summary()
+		}
+	}
+	PublishEvent(event, sp.Server, data)
+
 	// Process has stopped - clean up
 	serverMux.Lock()
 	delete(runningServers, sp.Server.ID)
 	serverMux.Unlock()
 
+	if sp.cgroup != "" {
+		removeServerCgroup(sp.Server, sp.cgroup)
+	}
+
 	sp.Server.SetStatus("offline")
 
 	// Notify all WebSocket clients that server is offline
 	sp.ClientMux.Lock()
 	for _, client := range sp.Clients {
 		client.WriteMessage(websocket.TextMessage, []byte(fmt.Sprintf("\n=== Server stopped (exit code: %d) ===\n", exitCode)))
+		if crash != nil {
+			client.WriteMessage(websocket.TextMessage, []byte(fmt.Sprintf("=== Crash: %s (details: /server/%s/crashes/%d) ===\n", crash.Summary(), sp.Server.Name, crash.ID)))
+		}
 		client.Close()
 	}
 	sp.Clients = []*websocket.Conn{}
@@ -0,0 +1,124 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// pingProtocolVersion is sent in the handshake; -1 asks the server to report
+// its own version instead of checking ours
+const pingProtocolVersion = -1
+
+// maxPingResponse bounds the status JSON read from a server
+const maxPingResponse = 1 << 20
+
+// PingResponse is the status a server reports to a Server List Ping
+type PingResponse struct {
+	Version struct {
+		Name     string `json:"name"`
+		Protocol int    `json:"protocol"`
+	} `json:"version"`
+	Players struct {
+		Online int `json:"online"`
+		Max    int `json:"max"`
+	} `json:"players"`
+}
+
+// PingServer sends a Server List Ping to a server or proxy at host:port and
+// returns the status it reports
+func PingServer(address string, timeout time.Duration) (*PingResponse, error) {
+	host, portText, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.Atoi(portText)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.DialTimeout("tcp", address, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	// Handshake with next state 1 (status), then a status request
+	var handshake bytes.Buffer
+	writeVarInt(&handshake, 0x00)
+	writeVarInt(&handshake, pingProtocolVersion)
+	writeVarInt(&handshake, len(host))
+	handshake.WriteString(host)
+	binary.Write(&handshake, binary.BigEndian, uint16(port))
+	writeVarInt(&handshake, 1)
+
+	var request bytes.Buffer
+	writeVarInt(&request, handshake.Len())
+	request.Write(handshake.Bytes())
+	writeVarInt(&request, 1)
+	writeVarInt(&request, 0x00)
+	if _, err := conn.Write(request.Bytes()); err != nil {
+		return nil, err
+	}
+
+	reader := bufio.NewReader(conn)
+	if _, err := readVarInt(reader); err != nil { // packet length
+		return nil, err
+	}
+	if id, err := readVarInt(reader); err != nil {
+		return nil, err
+	} else if id != 0x00 {
+		return nil, errors.New("unexpected packet in ping response")
+	}
+	length, err := readVarInt(reader)
+	if err != nil {
+		return nil, err
+	}
+	if length < 0 || length > maxPingResponse {
+		return nil, errors.New("invalid ping response length")
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(reader, data); err != nil {
+		return nil, err
+	}
+
+	var status PingResponse
+	if err := json.Unmarshal(data, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// writeVarInt writes a protocol VarInt: 7 bits per byte, least significant
+// first, with the high bit set on all but the last byte
+func writeVarInt(buf *bytes.Buffer, value int) {
+	v := uint32(int32(value))
+	for v >= 0x80 {
+		buf.WriteByte(byte(v) | 0x80)
+		v >>= 7
+	}
+	buf.WriteByte(byte(v))
+}
+
+// readVarInt reads a protocol VarInt
+func readVarInt(r io.ByteReader) (int, error) {
+	var value uint32
+	for i := 0; i < 5; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		value |= uint32(b&0x7f) << (7 * i)
+		if b&0x80 == 0 {
+			return int(int32(value)), nil
+		}
+	}
+	return 0, errors.New("VarInt is too long")
+}
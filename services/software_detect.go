@@ -0,0 +1,401 @@
+package services
+
+import (
+	"archive/zip"
+	"bufio"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"minecraft-server-controller/models"
+)
+
+// mainClassSoftware maps the Main-Class of server jars to their software
+var mainClassSoftware = map[string]string{
+	"net.minecraft.server.Main":                                   models.SoftwareVanilla,
+	"net.minecraft.bundler.Main":                                  models.SoftwareVanilla,
+	"net.minecraft.server.MinecraftServer":                        models.SoftwareVanilla,
+	"io.papermc.paperclip.Main":                                   models.SoftwarePaper,
+	"io.papermc.paperclip.Paperclip":                              models.SoftwarePaper,
+	"com.destroystokyo.paperclip.Paperclip":                       models.SoftwarePaper,
+	"org.bukkit.craftbukkit.Main":                                 models.SoftwareSpigot,
+	"org.bukkit.craftbukkit.bootstrap.Main":                       models.SoftwareSpigot,
+	"net.fabricmc.loader.launch.server.FabricServerLauncher":      models.SoftwareFabric,
+	"net.fabricmc.loader.impl.launch.server.FabricServerLauncher": models.SoftwareFabric,
+	"net.fabricmc.installer.ServerLauncher":                       models.SoftwareFabric,
+	"net.minecraftforge.fml.relauncher.ServerLaunchWrapper":       models.SoftwareForge,
+	"com.velocitypowered.proxy.Velocity":                          models.SoftwareVelocity,
+	"net.md_5.bungee.Bootstrap":                                   models.SoftwareBungeeCord,
+}
+
+var (
+	// forgeArgsPattern matches the argument file a Forge or NeoForge installer's run.sh passes to java
+	forgeArgsPattern = regexp.MustCompile(`@libraries/net/(minecraftforge/forge|neoforged/neoforge|neoforged/forge)/([^/\s]+)/`)
+	// mcVersionPattern matches a Minecraft release version such as 1.20.4
+	mcVersionPattern = regexp.MustCompile(`\b1\.\d+(?:\.\d+)?\b`)
+	// spigotVersionPattern matches the Minecraft version in Spigot's Implementation-Version
+	spigotVersionPattern = regexp.MustCompile(`\(MC: ([0-9.]+)\)`)
+)
+
+// SoftwareInfo is the detected software of a server folder
+type SoftwareInfo struct {
+	Software string `json:"software"`
+	Version  string `json:"version"`
+}
+
+// DetectServerSoftware works out which software and Minecraft version a
+// server runs, from its folder layout and the jar its startup command starts
+func DetectServerSoftware(server *models.Server) SoftwareInfo {
+	return DetectSoftware(ServerWorkingDir(server), startupJar(server))
+}
+
+// startupJar returns the path of the jar the startup command starts, or ""
+// when it does not start one with -jar
+func startupJar(server *models.Server) string {
+	jar := ""
+	if args, err := SplitCommand(server.StartupCommand, server.Env); err == nil {
+		for i := 1; i+1 < len(args); i++ {
+			if args[i] == "-jar" {
+				jar = args[i+1]
+				break
+			}
+		}
+	}
+	if jar != "" && !filepath.IsAbs(jar) {
+		jar = filepath.Join(ServerWorkingDir(server), jar)
+	}
+	return jar
+}
+
+// DetectSoftware inspects a server folder and, when known, the jar that is
+// started. Loader layouts are checked before the jar, since Fabric and Forge
+// folders also hold the vanilla server jar.
+func DetectSoftware(dir, jar string) SoftwareInfo {
+	if _, err := os.Stat(filepath.Join(dir, "fabric-server-launch.jar")); err == nil {
+		return SoftwareInfo{Software: models.SoftwareFabric, Version: fabricVersion(dir)}
+	}
+
+	if info, ok := detectForgeScript(dir); ok {
+		return info
+	}
+
+	if jar != "" {
+		if info, ok := inspectServerJar(jar); ok {
+			if info.Software == models.SoftwareFabric && info.Version == "" {
+				info.Version = fabricVersion(dir)
+			}
+			return info
+		}
+	}
+
+	if info, ok := detectForgeLibraries(dir); ok {
+		return info
+	}
+
+	// Fall back to any recognisable jar in the folder, preferring non-vanilla
+	// jars over the vanilla jar that loaders download next to them
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return SoftwareInfo{}
+	}
+	names := []string{}
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".jar") {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+
+	var vanilla *SoftwareInfo
+	for _, name := range names {
+		info, ok := inspectServerJar(filepath.Join(dir, name))
+		if !ok {
+			continue
+		}
+		if info.Software != models.SoftwareVanilla {
+			return info
+		}
+		if vanilla == nil {
+			vanilla = &info
+		}
+	}
+	if vanilla != nil {
+		return *vanilla
+	}
+	return SoftwareInfo{}
+}
+
+// detectForgeScript reads the run.sh or run.bat written by Forge and
+// NeoForge installers for 1.17 and later
+func detectForgeScript(dir string) (SoftwareInfo, bool) {
+	for _, script := range []string{"run.sh", "run.bat"} {
+		data, err := os.ReadFile(filepath.Join(dir, script))
+		if err != nil {
+			continue
+		}
+		match := forgeArgsPattern.FindStringSubmatch(string(data))
+		if match == nil {
+			continue
+		}
+		return forgeInfo(match[1], match[2]), true
+	}
+	return SoftwareInfo{}, false
+}
+
+// detectForgeLibraries looks for the Forge or NeoForge library folders
+func detectForgeLibraries(dir string) (SoftwareInfo, bool) {
+	for _, artifact := range []string{"neoforged/neoforge", "neoforged/forge", "minecraftforge/forge"} {
+		entries, err := os.ReadDir(filepath.Join(dir, "libraries", "net", filepath.FromSlash(artifact)))
+		if err != nil {
+			continue
+		}
+		if version := newestVersionDir(entries); version != "" {
+			return forgeInfo(artifact, version), true
+		}
+	}
+	return SoftwareInfo{}, false
+}
+
+// forgeInfo derives the software and Minecraft version from a Forge library
+// version: "1.20.1-47.2.0" for Forge, "20.4.237" for NeoForge, where 20.4 is
+// Minecraft 1.20.4
+func forgeInfo(artifact, version string) SoftwareInfo {
+	if artifact == "neoforged/neoforge" {
+		parts := strings.Split(version, ".")
+		if len(parts) < 2 {
+			return SoftwareInfo{Software: models.SoftwareNeoForge}
+		}
+		mc := "1." + parts[0]
+		if parts[1] != "0" {
+			mc += "." + parts[1]
+		}
+		return SoftwareInfo{Software: models.SoftwareNeoForge, Version: mc}
+	}
+
+	software := models.SoftwareForge
+	if artifact == "neoforged/forge" {
+		// NeoForge for 1.20.1 kept Forge's version scheme
+		software = models.SoftwareNeoForge
+	}
+	mc, _, _ := strings.Cut(version, "-")
+	return SoftwareInfo{Software: software, Version: mc}
+}
+
+// fabricVersion returns the Minecraft version of a Fabric server from its
+// intermediary mappings or the vanilla jar the launcher starts
+func fabricVersion(dir string) string {
+	entries, err := os.ReadDir(filepath.Join(dir, "libraries", "net", "fabricmc", "intermediary"))
+	if err == nil {
+		if version := newestVersionDir(entries); version != "" {
+			return version
+		}
+	}
+	if info, ok := inspectServerJar(filepath.Join(dir, "server.jar")); ok {
+		return info.Version
+	}
+	return ""
+}
+
+// newestVersionDir returns the name of the directory with the highest
+// version, such as the newest of several library versions
+func newestVersionDir(entries []os.DirEntry) string {
+	newest := ""
+	for _, entry := range entries {
+		if entry.IsDir() && (newest == "" || compareVersions(entry.Name(), newest) > 0) {
+			newest = entry.Name()
+		}
+	}
+	return newest
+}
+
+// compareVersions compares versions such as "1.20.1-47.2.0" number by
+// number, so 1.10 is newer than 1.9
+func compareVersions(a, b string) int {
+	numbersA, numbersB := versionNumbers(a), versionNumbers(b)
+	for i := 0; i < len(numbersA) && i < len(numbersB); i++ {
+		if numbersA[i] != numbersB[i] {
+			if numbersA[i] < numbersB[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	if len(numbersA) != len(numbersB) {
+		if len(numbersA) < len(numbersB) {
+			return -1
+		}
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+// versionNumbers returns the numbers of a version in order
+func versionNumbers(version string) []int {
+	numbers := []int{}
+	for _, field := range strings.FieldsFunc(version, func(r rune) bool { return r < '0' || r > '9' }) {
+		n, _ := strconv.Atoi(field)
+		numbers = append(numbers, n)
+	}
+	return numbers
+}
+
+// inspectServerJar recognises a server jar from its manifest, version.json
+// and the versions.list of bundler jars such as Paper's and Purpur's
+func inspectServerJar(jarPath string) (SoftwareInfo, bool) {
+	archive, err := zip.OpenReader(jarPath)
+	if err != nil {
+		return SoftwareInfo{}, false
+	}
+	defer archive.Close()
+
+	manifest := readJarManifest(&archive.Reader)
+	info := SoftwareInfo{Software: mainClassSoftware[manifest["Main-Class"]]}
+
+	if entry := findZipEntry(&archive.Reader, "version.json"); entry != nil {
+		var version struct {
+			ID string `json:"id"`
+		}
+		if err := readZipJSON(entry, &version); err == nil {
+			info.Version = version.ID
+		}
+	}
+
+	// Bundler jars list the jar they unpack, e.g. "purpur-1.20.4"
+	if entry := findZipEntry(&archive.Reader, "META-INF/versions.list"); entry != nil {
+		if id := readVersionsList(entry); id != "" {
+			name, rest, _ := strings.Cut(id, "-")
+			if _, known := models.SoftwareNames[name]; known {
+				info.Software = name
+			}
+			if info.Version == "" {
+				info.Version = mcVersionPattern.FindString(rest)
+			}
+		}
+	}
+
+	if info.Software == "" {
+		return SoftwareInfo{}, false
+	}
+
+	if info.Version == "" {
+		switch {
+		case models.IsProxySoftware(info.Software):
+			// "3.3.0-SNAPSHOT (git-8a2b1c3d-b400)" is reported as 3.3.0-SNAPSHOT
+			if fields := strings.Fields(manifest["Implementation-Version"]); len(fields) > 0 {
+				info.Version = fields[0]
+			}
+		case spigotVersionPattern.MatchString(manifest["Implementation-Version"]):
+			info.Version = spigotVersionPattern.FindStringSubmatch(manifest["Implementation-Version"])[1]
+		default:
+			info.Version = mcVersionPattern.FindString(filepath.Base(jarPath))
+		}
+	}
+	return info, true
+}
+
+// readJarManifest returns the main attributes of a jar's manifest
+func readJarManifest(archive *zip.Reader) map[string]string {
+	attributes := make(map[string]string)
+	entry := findZipEntry(archive, "META-INF/MANIFEST.MF")
+	if entry == nil {
+		return attributes
+	}
+
+	file, err := entry.Open()
+	if err != nil {
+		return attributes
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	last := ""
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		// The main section ends at the first blank line
+		if line == "" {
+			break
+		}
+		// Lines are wrapped at 72 bytes, continuing after a single space
+		if strings.HasPrefix(line, " ") {
+			if last != "" {
+				attributes[last] += line[1:]
+			}
+			continue
+		}
+		if name, value, found := strings.Cut(line, ":"); found {
+			attributes[name] = strings.TrimPrefix(value, " ")
+			last = name
+		}
+	}
+	for name, value := range attributes {
+		attributes[name] = strings.TrimSpace(value)
+	}
+	return attributes
+}
+
+// readVersionsList returns the id of the first entry of a bundler's
+// versions.list, whose lines are "<sha256>\t<id>\t<path>"
+func readVersionsList(entry *zip.File) string {
+	file, err := entry.Open()
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, 64*1024))
+	if err != nil {
+		return ""
+	}
+	line, _, _ := strings.Cut(string(data), "\n")
+	fields := strings.Split(strings.TrimSpace(line), "\t")
+	if len(fields) < 2 {
+		return ""
+	}
+	return fields[1]
+}
+
+// RefreshServerSoftware detects a server's software and stores it when it
+// changed, along with the stamp of the files it was detected from
+func RefreshServerSoftware(server *models.Server) SoftwareInfo {
+	stamp := softwareStamp(server)
+	info := DetectServerSoftware(server)
+	if info.Software != server.Software || info.Version != server.MCVersion {
+		if err := server.SetSoftware(info.Software, info.Version); err != nil {
+			log.Printf("⚠️  Failed to store software of server '%s': %v", server.Name, err)
+		}
+	}
+	if stamp != server.SoftwareStamp {
+		if err := server.SetSoftwareStamp(stamp); err != nil {
+			log.Printf("⚠️  Failed to store software of server '%s': %v", server.Name, err)
+		}
+	}
+	return info
+}
+
+// RefreshStaleServerSoftware detects a server's software again only when
+// its jar changed since the last detection, so pages can call it on every load
+func RefreshStaleServerSoftware(server *models.Server) {
+	if softwareStamp(server) != server.SoftwareStamp {
+		RefreshServerSoftware(server)
+	}
+}
+
+// softwareStamp returns the modification time of the jar the startup
+// command starts or, for start scripts, of the folder, which changes when
+// jars are added or removed
+func softwareStamp(server *models.Server) int64 {
+	path := startupJar(server)
+	if path == "" {
+		path = ServerWorkingDir(server)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return info.ModTime().UnixNano()
+}
@@ -0,0 +1,363 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+
+	"minecraft-server-controller/config"
+	"minecraft-server-controller/models"
+)
+
+// Startup exec modes
+const (
+	ExecDirect = "direct" // run the first argument as the program
+	ExecShell  = "shell"  // run the first argument as a script with /bin/sh
+)
+
+// shellPath is the shell that runs scripts in the shell exec mode
+const shellPath = "/bin/sh"
+
+// envNamePattern matches valid environment variable names
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ValidateEnvName checks that name can be used as an environment variable
+func ValidateEnvName(name string) error {
+	if !envNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid variable name %q", name)
+	}
+	return nil
+}
+
+// SplitCommand splits a command line into arguments the way a POSIX shell
+// would: whitespace separates arguments, single quotes keep everything
+// literally, double quotes keep whitespace but allow \ escapes of " \ $ and
+// `, and a backslash outside quotes escapes the next character. ${VAR} and
+// $VAR are expanded from env outside single quotes; \$ keeps a literal dollar.
+func SplitCommand(command string, env map[string]string) ([]string, error) {
+	args := []string{}
+	var current strings.Builder
+	inArg := false
+
+	for i := 0; i < len(command); i++ {
+		c := command[i]
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+
+		case c == '\\':
+			if i+1 >= len(command) {
+				return nil, errors.New("command ends with a lone backslash")
+			}
+			i++
+			// A backslash-newline is a line continuation
+			if command[i] != '\n' {
+				current.WriteByte(command[i])
+				inArg = true
+			}
+
+		case c == '\'':
+			end := strings.IndexByte(command[i+1:], '\'')
+			if end < 0 {
+				return nil, errors.New("unterminated single quote")
+			}
+			current.WriteString(command[i+1 : i+1+end])
+			i += end + 1
+			inArg = true
+
+		case c == '"':
+			inArg = true
+			closed := false
+			for i++; i < len(command); i++ {
+				c = command[i]
+				if c == '"' {
+					closed = true
+					break
+				}
+				if c == '\\' && i+1 < len(command) && strings.IndexByte("\"\\$`\n", command[i+1]) >= 0 {
+					i++
+					if command[i] != '\n' {
+						current.WriteByte(command[i])
+					}
+					continue
+				}
+				if c == '$' {
+					value, consumed, err := expandVariable(command[i:], env)
+					if err != nil {
+						return nil, err
+					}
+					current.WriteString(value)
+					i += consumed - 1
+					continue
+				}
+				current.WriteByte(c)
+			}
+			if !closed {
+				return nil, errors.New("unterminated double quote")
+			}
+
+		case c == '$':
+			value, consumed, err := expandVariable(command[i:], env)
+			if err != nil {
+				return nil, err
+			}
+			current.WriteString(value)
+			i += consumed - 1
+			inArg = true
+
+		default:
+			current.WriteByte(c)
+			inArg = true
+		}
+	}
+
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
+
+// expandVariable expands the $VAR or ${VAR} at the start of s. It returns the
+// value and how many bytes of s were consumed. A $ not followed by a name is
+// kept as is.
+func expandVariable(s string, env map[string]string) (string, int, error) {
+	if len(s) < 2 {
+		return "$", 1, nil
+	}
+
+	var name string
+	consumed := 0
+	if s[1] == '{' {
+		end := strings.IndexByte(s, '}')
+		if end < 0 {
+			return "", 0, errors.New("unterminated ${")
+		}
+		name = s[2:end]
+		consumed = end + 1
+		if err := ValidateEnvName(name); err != nil {
+			return "", 0, err
+		}
+	} else {
+		end := 1
+		for end < len(s) && (s[end] == '_' || isAlnum(s[end])) {
+			end++
+		}
+		if end == 1 || (s[1] >= '0' && s[1] <= '9') {
+			return "$", 1, nil
+		}
+		name = s[1:end]
+		consumed = end
+	}
+
+	value, exists := env[name]
+	if !exists {
+		return "", 0, fmt.Errorf("undefined variable %s", name)
+	}
+	return value, consumed, nil
+}
+
+// isAlnum reports whether c is an ASCII letter or digit
+func isAlnum(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// commandArgs returns the argument vector of a startup command; in the shell
+// exec mode the command is handed to /bin/sh
+func commandArgs(command, execMode string, env map[string]string) ([]string, error) {
+	args, err := SplitCommand(command, env)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 0 {
+		return nil, errors.New("startup command cannot be empty")
+	}
+
+	if execMode == ExecShell {
+		return append([]string{shellPath}, args...), nil
+	}
+	return args, nil
+}
+
+// QuoteArg quotes an argument for a startup command when it contains
+// characters SplitCommand would otherwise interpret
+func QuoteArg(arg string) string {
+	if arg != "" && !strings.ContainsAny(arg, " \t\n'\"\\$") {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+// StartupCheck is the outcome of validating a startup configuration
+type StartupCheck struct {
+	Command  string   `json:"command"`
+	Args     []string `json:"args"`
+	Warnings []string `json:"warnings"`
+	Error    string   `json:"error,omitempty"`
+}
+
+// ValidateStartup checks a startup command before it is saved: that it
+// parses, that the program exists and is executable, and that files it
+// refers to are present
+func ValidateStartup(command, execMode string, env map[string]string, folder string) StartupCheck {
+	check := StartupCheck{Args: []string{}, Warnings: []string{}}
+
+	for name := range env {
+		if err := ValidateEnvName(name); err != nil {
+			check.Error = err.Error()
+			return check
+		}
+	}
+
+	if execMode != ExecDirect && execMode != ExecShell {
+		check.Error = "unknown exec mode"
+		return check
+	}
+
+	args, err := commandArgs(command, execMode, env)
+	if err != nil {
+		check.Error = err.Error()
+		return check
+	}
+	check.Args = args
+
+	program := args[0]
+	if execMode == ExecShell {
+		program = args[1]
+	}
+	if runtime.GOOS != "windows" && strings.HasSuffix(strings.ToLower(program), ".bat") {
+		check.Error = "batch files cannot run on this system"
+		return check
+	}
+
+	if execMode == ExecShell {
+		// The script only needs to be readable: /bin/sh runs it
+		if _, err := os.Stat(shellPath); err != nil {
+			check.Error = shellPath + " is not available"
+			return check
+		}
+		path := program
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(folder, path)
+		}
+		if _, err := os.Stat(path); err != nil {
+			check.Error = program + " does not exist"
+		}
+		return check
+	}
+
+	if strings.Contains(program, "/") {
+		path := program
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(folder, path)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			check.Error = program + " does not exist"
+			return check
+		}
+		if info.Mode().Perm()&0111 == 0 {
+			check.Error = program + " is not executable; run it via /bin/sh instead"
+			return check
+		}
+	} else if _, err := exec.LookPath(program); err != nil {
+		check.Error = program + " was not found in PATH"
+		return check
+	}
+
+	// The jar passed to java should exist
+	for i := 1; i+1 < len(args); i++ {
+		if args[i] == "-jar" {
+			jar := args[i+1]
+			if !filepath.IsAbs(jar) {
+				jar = filepath.Join(folder, jar)
+			}
+			if _, err := os.Stat(jar); err != nil {
+				check.Warnings = append(check.Warnings, args[i+1]+" does not exist in the server folder")
+			}
+		}
+	}
+
+	if warning := javaCompatibilityWarning(args, folder); warning != "" {
+		check.Warnings = append(check.Warnings, warning)
+	}
+
+	return check
+}
+
+// BuildServerCommand prepares the process of a server from its startup
+// command, exec mode and environment
+func BuildServerCommand(server *models.Server) (*exec.Cmd, error) {
+	args, err := commandArgs(server.StartupCommand, server.ExecMode, server.Env)
+	if err != nil {
+		return nil, fmt.Errorf("invalid startup command: %w", err)
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Dir = ServerWorkingDir(server)
+	cmd.Env = serverEnvironment(server)
+	return cmd, nil
+}
+
+// serverEnvironment returns the allowed part of the controller's
+// environment with the server's variables added, in a stable order
+func serverEnvironment(server *models.Server) []string {
+	env := filterEnvironment(os.Environ(), config.GetEnvAllowlist())
+
+	names := make([]string, 0, len(server.Env))
+	for name := range server.Env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		env = append(env, name+"="+server.Env[name])
+	}
+	return env
+}
+
+// ParseEnvLines parses KEY=VALUE lines; blank lines and # comments are skipped
+func ParseEnvLines(text string) (map[string]string, error) {
+	env := make(map[string]string)
+	for n, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		name, value, found := strings.Cut(line, "=")
+		name = strings.TrimSpace(name)
+		if !found {
+			return nil, fmt.Errorf("line %d: expected KEY=VALUE", n+1)
+		}
+		if err := ValidateEnvName(name); err != nil {
+			return nil, fmt.Errorf("line %d: %v", n+1, err)
+		}
+		env[name] = value
+	}
+	return env, nil
+}
+
+// FormatEnvLines renders an environment map as sorted KEY=VALUE lines
+func FormatEnvLines(env map[string]string) string {
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	lines := make([]string, 0, len(names))
+	for _, name := range names {
+		lines = append(lines, name+"="+env[name])
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,110 @@
+package services
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// systemSampleInterval is how often the collector samples /proc
+const systemSampleInterval = 2 * time.Second
+
+// SystemSnapshot holds the latest system statistics taken by the collector
+type SystemSnapshot struct {
+	Info       SystemInfo
+	CPUPercent float64
+	PerCore    []float64
+	Breakdown  CPUBreakdown
+	Load       LoadAverage
+	Memory     MemoryStats
+	Swap       SwapStats
+	Disk       DiskStats
+	UpdatedAt  time.Time
+}
+
+var (
+	systemSnapshot    SystemSnapshot
+	systemSnapshotMux sync.RWMutex
+)
+
+// StartSystemCollector starts the goroutine that samples system statistics
+// at a fixed interval. Readers get the cached snapshot instead of sampling
+// /proc themselves.
+func StartSystemCollector() {
+	go func() {
+		prevTotal, prevCores, err := readCPUStats()
+		if err != nil {
+			log.Printf("⚠️  Failed to read CPU stats: %v", err)
+		}
+
+		ticker := time.NewTicker(systemSampleInterval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			currTotal, currCores, err := readCPUStats()
+			if err != nil {
+				log.Printf("⚠️  Failed to read CPU stats: %v", err)
+				continue
+			}
+
+			snapshot := collectSystemSnapshot(prevTotal, currTotal, prevCores, currCores)
+
+			systemSnapshotMux.Lock()
+			systemSnapshot = snapshot
+			systemSnapshotMux.Unlock()
+
+			prevTotal, prevCores = currTotal, currCores
+		}
+	}()
+
+	log.Println("✅ System stats collector started")
+}
+
+// collectSystemSnapshot builds a snapshot from two CPU readings plus the
+// current memory, swap, load and disk figures
+func collectSystemSnapshot(prevTotal, currTotal *cpuStats, prevCores, currCores []*cpuStats) SystemSnapshot {
+	snapshot := SystemSnapshot{UpdatedAt: time.Now()}
+
+	if info, err := GetSystemInfo(); err == nil {
+		snapshot.Info = *info
+	}
+
+	if prevTotal != nil {
+		snapshot.CPUPercent = calculateCPUUsage(prevTotal, currTotal)
+		snapshot.Breakdown = calculateCPUBreakdown(prevTotal, currTotal)
+	}
+
+	// Cores can go offline between readings; only compare matching counts
+	snapshot.PerCore = make([]float64, len(currCores))
+	if len(prevCores) == len(currCores) {
+		for i := range currCores {
+			snapshot.PerCore[i] = calculateCPUUsage(prevCores[i], currCores[i])
+		}
+	}
+
+	if load, err := GetLoadAverage(); err == nil {
+		snapshot.Load = *load
+	}
+	if memStats, err := GetMemoryStats(); err == nil {
+		snapshot.Memory = *memStats
+	}
+	if swapStats, err := GetSwapStats(); err == nil {
+		snapshot.Swap = *swapStats
+	}
+	if diskStats, err := GetDiskStats(); err == nil {
+		snapshot.Disk = *diskStats
+	}
+
+	return snapshot
+}
+
+// GetSystemSnapshot returns a copy of the latest system statistics.
+// UpdatedAt is zero until the first sample has been taken.
+func GetSystemSnapshot() SystemSnapshot {
+	systemSnapshotMux.RLock()
+	defer systemSnapshotMux.RUnlock()
+
+	snapshot := systemSnapshot
+	snapshot.PerCore = append([]float64(nil), systemSnapshot.PerCore...)
+	return snapshot
+}
@@ -6,8 +6,8 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
-	"time"
 )
 
 // SystemInfo holds system information
@@ -33,6 +33,33 @@ type DiskStats struct {
 	UsedPercent float64 // percentage
 }
 
+// SwapStats holds swap statistics
+type SwapStats struct {
+	Total       uint64  // bytes
+	Used        uint64  // bytes
+	Free        uint64  // bytes
+	UsedPercent float64 // percentage
+}
+
+// LoadAverage holds the system load averages
+type LoadAverage struct {
+	Load1  float64
+	Load5  float64
+	Load15 float64
+}
+
+// CPUBreakdown holds the share of CPU time spent in each mode (percentages)
+type CPUBreakdown struct {
+	User    float64
+	Nice    float64
+	System  float64
+	Idle    float64
+	IOWait  float64
+	IRQ     float64
+	SoftIRQ float64
+	Steal   float64
+}
+
 // CPUStats holds CPU timing statistics
 type cpuStats struct {
 	user    uint64
@@ -45,8 +72,23 @@ type cpuStats struct {
 	steal   uint64
 }
 
-// GetSystemInfo returns system information (CPU model, cores, speed)
+var (
+	systemInfoOnce   sync.Once
+	cachedSystemInfo *SystemInfo
+	systemInfoErr    error
+)
+
+// GetSystemInfo returns system information (CPU model, cores, speed).
+// /proc/cpuinfo is only parsed once; the hardware does not change.
 func GetSystemInfo() (*SystemInfo, error) {
+	systemInfoOnce.Do(func() {
+		cachedSystemInfo, systemInfoErr = readSystemInfo()
+	})
+	return cachedSystemInfo, systemInfoErr
+}
+
+// readSystemInfo parses /proc/cpuinfo
+func readSystemInfo() (*SystemInfo, error) {
 	info := &SystemInfo{
 		CPUModel: "Unknown",
 		CPUCores: 0,
@@ -101,11 +143,8 @@ func GetSystemInfo() (*SystemInfo, error) {
 	return info, nil
 }
 
-// GetMemoryStats returns current memory statistics
-func GetMemoryStats() (*MemoryStats, error) {
-	stats := &MemoryStats{}
-
-	// Read /proc/meminfo
+// readMemInfo reads /proc/meminfo into a map of byte values
+func readMemInfo() (map[string]uint64, error) {
 	file, err := os.Open("/proc/meminfo")
 	if err != nil {
 		return nil, err
@@ -129,6 +168,18 @@ func GetMemoryStats() (*MemoryStats, error) {
 		}
 	}
 
+	return memInfo, scanner.Err()
+}
+
+// GetMemoryStats returns current memory statistics
+func GetMemoryStats() (*MemoryStats, error) {
+	stats := &MemoryStats{}
+
+	memInfo, err := readMemInfo()
+	if err != nil {
+		return nil, err
+	}
+
 	// Calculate memory statistics
 	stats.Total = memInfo["MemTotal"]
 	available := memInfo["MemAvailable"]
@@ -142,9 +193,50 @@ func GetMemoryStats() (*MemoryStats, error) {
 	return stats, nil
 }
 
-// GetDiskStats returns disk usage statistics for root partition
+// GetSwapStats returns current swap statistics
+func GetSwapStats() (*SwapStats, error) {
+	memInfo, err := readMemInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &SwapStats{
+		Total: memInfo["SwapTotal"],
+		Free:  memInfo["SwapFree"],
+	}
+	stats.Used = stats.Total - stats.Free
+
+	if stats.Total > 0 {
+		stats.UsedPercent = (float64(stats.Used) / float64(stats.Total)) * 100
+	}
+
+	return stats, nil
+}
+
+// GetLoadAverage returns the 1, 5 and 15 minute load averages from /proc/loadavg
+func GetLoadAverage() (*LoadAverage, error) {
+	data, err := os.ReadFile("/proc/loadavg")
+	if err != nil {
+		return nil, err
+	}
+
+	fields := strings.Fields(string(data))
+	if len(fields) < 3 {
+		return nil, fmt.Errorf("invalid /proc/loadavg format")
+	}
+
+	load := &LoadAverage{}
+	load.Load1, _ = strconv.ParseFloat(fields[0], 64)
+	load.Load5, _ = strconv.ParseFloat(fields[1], 64)
+	load.Load15, _ = strconv.ParseFloat(fields[2], 64)
+
+	return load, nil
+}
+
+// GetDiskStats returns disk usage statistics for the partition holding the
+// server folder, or the root partition when no server folder is configured
 func GetDiskStats() (*DiskStats, error) {
-	return getDiskStatsActual("/")
+	return getDiskStatsActual(DiskStatsPath())
 }
 
 // getDiskStatsActual uses syscall.Statfs for accurate disk statistics
@@ -169,81 +261,126 @@ func getDiskStatsActual(path string) (*DiskStats, error) {
 	return stats, nil
 }
 
-// GetCPUUsage returns current CPU usage percentage
+// GetCPUUsage returns the CPU usage percentage from the latest background sample
 func GetCPUUsage() (float64, error) {
-	// Read CPU stats twice with a small interval
-	stats1, err := readCPUStats()
-	if err != nil {
-		return 0, err
+	snapshot := GetSystemSnapshot()
+	if snapshot.UpdatedAt.IsZero() {
+		return 0, fmt.Errorf("no CPU sample collected yet")
 	}
-
-	// Wait 100ms
-	time.Sleep(100 * time.Millisecond)
-
-	stats2, err := readCPUStats()
-	if err != nil {
-		return 0, err
-	}
-
-	// Calculate CPU usage
-	return calculateCPUUsage(stats1, stats2), nil
+	return snapshot.CPUPercent, nil
 }
 
-// readCPUStats reads CPU statistics from /proc/stat
-func readCPUStats() (*cpuStats, error) {
+// readCPUStats reads the aggregate and per-core CPU statistics from /proc/stat
+func readCPUStats() (*cpuStats, []*cpuStats, error) {
 	file, err := os.Open("/proc/stat")
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer file.Close()
 
+	var total *cpuStats
+	cores := []*cpuStats{}
+
 	scanner := bufio.NewScanner(file)
-	if !scanner.Scan() {
-		return nil, fmt.Errorf("failed to read /proc/stat")
-	}
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
 
-	line := scanner.Text()
-	fields := strings.Fields(line)
+		// CPU lines come first: "cpu" followed by "cpu0", "cpu1", ...
+		if len(fields) == 0 || !strings.HasPrefix(fields[0], "cpu") {
+			break
+		}
 
-	if len(fields) < 8 || fields[0] != "cpu" {
-		return nil, fmt.Errorf("invalid /proc/stat format")
-	}
+		if len(fields) < 8 {
+			return nil, nil, fmt.Errorf("invalid /proc/stat format")
+		}
 
-	stats := &cpuStats{}
-	stats.user, _ = strconv.ParseUint(fields[1], 10, 64)
-	stats.nice, _ = strconv.ParseUint(fields[2], 10, 64)
-	stats.system, _ = strconv.ParseUint(fields[3], 10, 64)
-	stats.idle, _ = strconv.ParseUint(fields[4], 10, 64)
-	stats.iowait, _ = strconv.ParseUint(fields[5], 10, 64)
-	stats.irq, _ = strconv.ParseUint(fields[6], 10, 64)
-	stats.softirq, _ = strconv.ParseUint(fields[7], 10, 64)
+		stats := &cpuStats{}
+		stats.user, _ = strconv.ParseUint(fields[1], 10, 64)
+		stats.nice, _ = strconv.ParseUint(fields[2], 10, 64)
+		stats.system, _ = strconv.ParseUint(fields[3], 10, 64)
+		stats.idle, _ = strconv.ParseUint(fields[4], 10, 64)
+		stats.iowait, _ = strconv.ParseUint(fields[5], 10, 64)
+		stats.irq, _ = strconv.ParseUint(fields[6], 10, 64)
+		stats.softirq, _ = strconv.ParseUint(fields[7], 10, 64)
+
+		if len(fields) >= 9 {
+			stats.steal, _ = strconv.ParseUint(fields[8], 10, 64)
+		}
 
-	if len(fields) >= 9 {
-		stats.steal, _ = strconv.ParseUint(fields[8], 10, 64)
+		if fields[0] == "cpu" {
+			total = stats
+		} else {
+			cores = append(cores, stats)
+		}
 	}
 
-	return stats, nil
+	if total == nil {
+		return nil, nil, fmt.Errorf("failed to read /proc/stat")
+	}
+
+	return total, cores, nil
 }
 
 // calculateCPUUsage calculates CPU usage percentage from two stat readings
 func calculateCPUUsage(prev, curr *cpuStats) float64 {
-	prevIdle := prev.idle + prev.iowait
-	currIdle := curr.idle + curr.iowait
+	idled := tickDelta(prev.idle, curr.idle) + tickDelta(prev.iowait, curr.iowait)
+	nonIdled := tickDelta(prev.user, curr.user) + tickDelta(prev.nice, curr.nice) +
+		tickDelta(prev.system, curr.system) + tickDelta(prev.irq, curr.irq) +
+		tickDelta(prev.softirq, curr.softirq) + tickDelta(prev.steal, curr.steal)
 
-	prevNonIdle := prev.user + prev.nice + prev.system + prev.irq + prev.softirq + prev.steal
-	currNonIdle := curr.user + curr.nice + curr.system + curr.irq + curr.softirq + curr.steal
+	totald := idled + nonIdled
+	if totald == 0 {
+		return 0
+	}
 
-	prevTotal := prevIdle + prevNonIdle
-	currTotal := currIdle + currNonIdle
+	cpuUsage := float64(nonIdled) / float64(totald) * 100.0
 
-	totald := currTotal - prevTotal
-	idled := currIdle - prevIdle
+	return cpuUsage
+}
 
-	if totald == 0 {
+// tickDelta returns the ticks between two readings of a /proc/stat field.
+// iowait, and steal on some hypervisors, can go backwards, which counts as
+// no time instead of wrapping around.
+func tickDelta(prev, curr uint64) uint64 {
+	if curr < prev {
 		return 0
 	}
+	return curr - prev
+}
 
-	cpuUsage := (float64(totald) - float64(idled)) / float64(totald) * 100.0
+// calculateCPUBreakdown splits the time between two stat readings by mode
+func calculateCPUBreakdown(prev, curr *cpuStats) CPUBreakdown {
+	deltas := []uint64{
+		tickDelta(prev.user, curr.user),
+		tickDelta(prev.nice, curr.nice),
+		tickDelta(prev.system, curr.system),
+		tickDelta(prev.idle, curr.idle),
+		tickDelta(prev.iowait, curr.iowait),
+		tickDelta(prev.irq, curr.irq),
+		tickDelta(prev.softirq, curr.softirq),
+		tickDelta(prev.steal, curr.steal),
+	}
 
-	return cpuUsage
-}
\ No newline at end of file
+	var total uint64
+	for _, d := range deltas {
+		total += d
+	}
+	if total == 0 {
+		return CPUBreakdown{}
+	}
+
+	percent := func(d uint64) float64 {
+		return float64(d) / float64(total) * 100.0
+	}
+
+	return CPUBreakdown{
+		User:    percent(deltas[0]),
+		Nice:    percent(deltas[1]),
+		System:  percent(deltas[2]),
+		Idle:    percent(deltas[3]),
+		IOWait:  percent(deltas[4]),
+		IRQ:     percent(deltas[5]),
+		SoftIRQ: percent(deltas[6]),
+		Steal:   percent(deltas[7]),
+	}
+}
@@ -0,0 +1,244 @@
+package services
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"minecraft-server-controller/models"
+)
+
+// webhookRetryDelays are the waits before each retry of a failed delivery
+var webhookRetryDelays = []time.Duration{
+	10 * time.Second,
+	time.Minute,
+	5 * time.Minute,
+	15 * time.Minute,
+}
+
+// webhookClient is shared by all deliveries
+var webhookClient = &http.Client{Timeout: 10 * time.Second}
+
+// Webhook request headers
+const (
+	WebhookEventHeader     = "X-MCSC-Event"
+	WebhookDeliveryHeader  = "X-MCSC-Delivery"
+	WebhookSignatureHeader = "X-MCSC-Signature"
+)
+
+// webhookPayload is the JSON body of a generic webhook
+type webhookPayload struct {
+	ID string `json:"id"`
+	Event
+}
+
+// discordEmbed is the subset of a Discord embed the formatter uses
+type discordEmbed struct {
+	Title       string              `json:"title"`
+	Description string              `json:"description,omitempty"`
+	Color       int                 `json:"color"`
+	Timestamp   string              `json:"timestamp"`
+	Fields      []discordEmbedField `json:"fields,omitempty"`
+}
+
+// discordEmbedField is one name/value row of a Discord embed
+type discordEmbedField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline"`
+}
+
+// discordEventStyle holds the title and color of an event type in Discord
+var discordEventStyle = map[string]struct {
+	title string
+	color int
+}{
+	EventServerStarted:   {"Server started", 0x22c55e},
+	EventServerStopped:   {"Server stopped", 0x94a3b8},
+	EventServerCrashed:   {"Server crashed", 0xef4444},
+	EventPlayerJoined:    {"Player joined", 0x3b82f6},
+	EventPlayerLeft:      {"Player left", 0x64748b},
+	EventBackupFinished:  {"Backup finished", 0x8b5cf6},
+	EventCommandExecuted: {"Command executed", 0xeab308},
+}
+
+// deliverEvent sends an event to every enabled webhook of the server owner
+// that is subscribed to it
+func deliverEvent(event Event) {
+	webhooks, err := models.GetEnabledWebhooks(event.userID)
+	if err != nil {
+		log.Printf("⚠️  Failed to load webhooks: %v", err)
+		return
+	}
+
+	for i := range webhooks {
+		if webhooks[i].Subscribes(event.Type) {
+			deliverWebhook(&webhooks[i], event, newDeliveryID(), 1)
+		}
+	}
+}
+
+// deliverWebhook makes one delivery attempt and schedules a retry when it
+// fails with an error worth retrying
+func deliverWebhook(webhook *models.Webhook, event Event, deliveryID string, attempt int) {
+	retry, err := attemptWebhook(webhook, event, deliveryID, attempt)
+	if err == nil {
+		return
+	}
+
+	if !retry || attempt > len(webhookRetryDelays) {
+		log.Printf("⚠️  Webhook '%s' gave up on %s after %d attempt(s): %v", webhook.Name, event.Type, attempt, err)
+		return
+	}
+
+	time.AfterFunc(webhookRetryDelays[attempt-1], func() {
+		// Pick up edits and deletions made in the meantime
+		current, err := models.GetWebhookByID(webhook.ID, webhook.UserID)
+		if err != nil || !current.Enabled {
+			return
+		}
+		deliverWebhook(current, event, deliveryID, attempt+1)
+	})
+}
+
+// attemptWebhook posts an event to a webhook once and records the attempt in
+// the delivery log. retry reports whether a failure may succeed later
+// (network errors, 429 and 5xx responses).
+func attemptWebhook(webhook *models.Webhook, event Event, deliveryID string, attempt int) (retry bool, err error) {
+	delivery := &models.WebhookDelivery{
+		WebhookID:  webhook.ID,
+		DeliveryID: deliveryID,
+		Event:      event.Type,
+		Attempt:    attempt,
+	}
+	defer func() {
+		if err != nil {
+			delivery.Error = err.Error()
+		}
+		if recordErr := models.RecordWebhookDelivery(delivery); recordErr != nil {
+			log.Printf("⚠️  Failed to record webhook delivery: %v", recordErr)
+		}
+	}()
+
+	body, err := webhookBody(webhook, event, deliveryID)
+	if err != nil {
+		return false, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "minecraft-server-controller")
+	req.Header.Set(WebhookEventHeader, event.Type)
+	req.Header.Set(WebhookDeliveryHeader, deliveryID)
+	if webhook.Secret != "" {
+		req.Header.Set(WebhookSignatureHeader, SignWebhookBody(webhook.Secret, body))
+	}
+
+	started := time.Now()
+	resp, err := webhookClient.Do(req)
+	delivery.DurationMs = time.Since(started).Milliseconds()
+	if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+
+	delivery.StatusCode = resp.StatusCode
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		delivery.Success = true
+		return false, nil
+	}
+
+	retry = resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retry, fmt.Errorf("endpoint returned status %d", resp.StatusCode)
+}
+
+// webhookBody renders an event in the webhook's payload format
+func webhookBody(webhook *models.Webhook, event Event, deliveryID string) ([]byte, error) {
+	if webhook.Format == models.WebhookFormatDiscord {
+		return json.Marshal(map[string]interface{}{
+			"embeds": []discordEmbed{formatDiscordEmbed(event)},
+		})
+	}
+	return json.Marshal(webhookPayload{ID: deliveryID, Event: event})
+}
+
+// formatDiscordEmbed turns an event into a Discord embed
+func formatDiscordEmbed(event Event) discordEmbed {
+	style, ok := discordEventStyle[event.Type]
+	if !ok {
+		style.title = event.Type
+		style.color = 0x64748b
+	}
+
+	embed := discordEmbed{
+		Title:       style.title,
+		Description: fmt.Sprintf("Server **%s**", event.Server),
+		Color:       style.color,
+		Timestamp:   event.Time.Format(time.RFC3339),
+	}
+
+	keys := make([]string, 0, len(event.Data))
+	for key := range event.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		embed.Fields = append(embed.Fields, discordEmbedField{
+			Name:   strings.ReplaceAll(key, "_", " "),
+			Value:  fmt.Sprint(event.Data[key]),
+			Inline: true,
+		})
+	}
+
+	return embed
+}
+
+// SignWebhookBody returns the signature header value of a body:
+// "sha256=" followed by the hex HMAC-SHA256 of the body keyed with the secret
+func SignWebhookBody(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// GenerateWebhookSecret creates a random webhook signing secret
+func GenerateWebhookSecret() string {
+	b := make([]byte, 24)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// newDeliveryID creates a random ID shared by the attempts of one delivery
+func newDeliveryID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// SendTestWebhook delivers a test event to a webhook once, without retries
+func SendTestWebhook(webhook *models.Webhook, server *models.Server) error {
+	event := Event{
+		Type: "webhook.test",
+		Time: time.Now().UTC(),
+		Data: map[string]interface{}{"message": "Test delivery from Minecraft Server Controller"},
+	}
+	if server != nil {
+		event.ServerID = server.ID
+		event.Server = server.Name
+	}
+
+	_, err := attemptWebhook(webhook, event, newDeliveryID(), 1)
+	return err
+}